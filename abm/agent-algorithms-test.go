@@ -14,6 +14,7 @@ import (
 
 func TestCPPComparitorSort(t *testing.T) {
 	rand.Seed(0)
+	rng := calc.NewRNG(0)
 	predator := vpTesterAgent(0.0, 0.0)
 	predator.vsr = 1.0
 	predator.τ = colour.RGB{Red: 0.71, Green: 0.1, Blue: 0.39}
@@ -21,8 +22,8 @@ func TestCPPComparitorSort(t *testing.T) {
 	prey := []ColourPolymorphicPrey{}
 
 	for i := 1; i <= 10; i++ {
-		agentA := cpPreyTesterAgent(calc.RandFloatIn(-1, 1), calc.RandFloatIn(-1, 1))
-		agentB := cpPreyTesterAgent(calc.RandFloatIn(-1, 1), calc.RandFloatIn(-1, 1))
+		agentA := cpPreyTesterAgent(calc.RandFloatIn(-1, 1, rng), calc.RandFloatIn(-1, 1, rng))
+		agentB := cpPreyTesterAgent(calc.RandFloatIn(-1, 1, rng), calc.RandFloatIn(-1, 1, rng))
 		agentA.colouration = colour.RGB{Red: rand.Float64(), Green: rand.Float64(), Blue: rand.Float64()}
 		agentB.colouration = colour.RGB{Red: rand.Float64(), Green: rand.Float64(), Blue: rand.Float64()}
 		prey = append(prey, agentA)
@@ -60,6 +61,7 @@ func TestCPPComparitorSort(t *testing.T) {
 
 func TestOptimalAttackVectorSort(t *testing.T) {
 	rand.Seed(0)
+	rng := calc.NewRNG(0)
 	predator := vpTesterAgent(0.0, 0.0)
 	predator.vsr = 1.0
 	predator.τ = colour.RGB{Red: 0.71, Green: 0.1, Blue: 0.39}
@@ -67,8 +69,8 @@ func TestOptimalAttackVectorSort(t *testing.T) {
 	prey := []ColourPolymorphicPrey{}
 
 	for i := 1; i <= 10; i++ {
-		agentA := cpPreyTesterAgent(calc.RandFloatIn(-1, 1), calc.RandFloatIn(-1, 1))
-		agentB := cpPreyTesterAgent(calc.RandFloatIn(-1, 1), calc.RandFloatIn(-1, 1))
+		agentA := cpPreyTesterAgent(calc.RandFloatIn(-1, 1, rng), calc.RandFloatIn(-1, 1, rng))
+		agentB := cpPreyTesterAgent(calc.RandFloatIn(-1, 1, rng), calc.RandFloatIn(-1, 1, rng))
 		agentA.colouration = colour.RGB{Red: rand.Float64(), Green: rand.Float64(), Blue: rand.Float64()}
 		agentB.colouration = colour.RGB{Red: rand.Float64(), Green: rand.Float64(), Blue: rand.Float64()}
 		prey = append(prey, agentA)
@@ -82,7 +84,7 @@ func TestOptimalAttackVectorSort(t *testing.T) {
 		𝛘 := colour.RGBDistance(predator.τ, prey[i].colouration)
 		δ, _ := geometry.VectorDistance(predator.pos, prey[i].pos)
 		// fmt.Printf("%v\t%v\t%v\t%p\n", i, 𝛘, δ, &prey[i])
-		a := visualRecognition{δ, 𝛘, f, c, &prey[i]}
+		a := visualRecognition{δ, 𝛘, f, c, 1, &prey[i]}
 		optimals = append(optimals, a)
 	}
 
@@ -3,6 +3,8 @@ package abm
 import (
 	"math"
 
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
 	"github.com/benjamin-rood/abm-cp/geometry"
 )
 
@@ -12,6 +14,18 @@ func visualSignalStrength(c float64) func(float64) float64 {
 	}
 }
 
+/*
+crypsis gives the degree, in [0,w], to which a prey agent of colouration col
+(and UV reflectance uv) is camouflaged against the substrate colour bg, with
+contrast as perceived through see: perfect background matching (no contrast)
+gives w, and maximal contrast gives 0. The weight w ∈ [0,1] sets how much
+camouflage can reduce the visibility of prey. Substrates reflect no UV.
+*/
+func crypsis(col colour.RGB, uv float64, bg colour.RGB, w float64, see perception) float64 {
+	contrast := see(col, uv, bg, 0)
+	return calc.ClampFloatIn(w, 0, 1) * (1 - contrast)
+}
+
 /*
 type cd struct {
 	comp func(float64) float64
@@ -47,15 +61,21 @@ type visualRecognition struct {
 	𝛘    float64 //	colour sorting value - colour distance/difference between vp.imprimt and cpPrey.colouration
 	comp func(float64) float64
 	rat  float64 //	value to rationalise the return from comp with
+	vis  float64 //	visibility of the prey against its local background, in [0,1]
 	*ColourPolymorphicPrey
 }
 
+// strength of the visual signal, accounting for the visibility of the prey.
+func (v visualRecognition) strength() float64 {
+	return v.comp(v.𝛘) * v.vis
+}
+
 type byVisualSignalStrength []visualRecognition
 
 func (vss byVisualSignalStrength) Len() int      { return len(vss) }
 func (vss byVisualSignalStrength) Swap(i, j int) { vss[i], vss[j] = vss[j], vss[i] }
 func (vss byVisualSignalStrength) Less(i, j int) bool {
-	return !(vss[i].comp(vss[i].𝛘) < vss[j].comp(vss[j].𝛘)) // As we want to sort Higher -> Lower values
+	return !(vss[i].strength() < vss[j].strength()) // As we want to sort Higher -> Lower values
 }
 
 type byOptimalAttackVector []visualRecognition
@@ -63,7 +83,7 @@ type byOptimalAttackVector []visualRecognition
 func (opt byOptimalAttackVector) Len() int      { return len(opt) }
 func (opt byOptimalAttackVector) Swap(i, j int) { opt[i], opt[j] = opt[j], opt[i] }
 func (opt byOptimalAttackVector) Less(i, j int) bool {
-	return !((opt[i].comp(opt[i].𝛘) - opt[i].δ) < (opt[j].comp(opt[j].𝛘) - opt[j].δ)) // As we want to sort Higher -> Lower values
+	return !((opt[i].strength() - opt[i].δ) < (opt[j].strength() - opt[j].δ)) // As we want to sort Higher -> Lower values
 }
 
 // byProximity implements sort.Interface for slice of *ColourPolymorphicPrey
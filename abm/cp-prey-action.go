@@ -1,9 +1,16 @@
 package abm
 
-import "github.com/benjamin-rood/abm-cp/calc"
+import (
+  "math/rand"
+
+  "github.com/benjamin-rood/abm-cp/calc"
+  "github.com/benjamin-rood/abm-cp/geometry"
+)
 
 // Action = Rule Based Behaviour that each cpPrey agent engages in once per turn, counts as the agent's action for that turn/phase.
-func (c *ColourPolymorphicPrey) Action(conditions ConditionParams, popSize int) (newpop []ColourPolymorphicPrey) {
+// pop is the population at the start of the phase, of which the agent is pop[me], and must not be modified.
+// With abm-cp-prey-genetics, a fertile agent only conceives with a sire found in pop (using index, when given).
+func (c *ColourPolymorphicPrey) Action(conditions ConditionParams, turn int, pop []ColourPolymorphicPrey, me int, index *geometry.Grid, rng *rand.Rand) (newpop []ColourPolymorphicPrey) {
   newkids := []ColourPolymorphicPrey{}
   jump := ""
   // BEGIN
@@ -12,17 +19,24 @@ func (c *ColourPolymorphicPrey) Action(conditions ConditionParams, popSize int)
   case "DEATH":
     goto End
   case "SPAWN":
-    progeny := c.Birth(conditions) //	max spawn size, mutation factor
+    progeny := c.Birth(conditions, turn, rng) //	max spawn size, mutation factor
     newkids = append(newkids, progeny...)
   case "FERTILE":
-    if popSize <= conditions.CpPreyPopulationCap {
-      c.Reproduction(conditions.CpPreyReproductionChance, conditions.CpPreyGestation)
+    if len(pop) <= conditions.CpPreyPopulationCap {
+      if conditions.CpPreyGenetics == nil {
+        c.Reproduction(conditions.CpPreyReproductionChance, conditions.CpPreyGestation, rng)
+      } else if mate := c.MateSearch(pop, me, conditions.Environment, index, rng); mate != nil {
+        //	the sire bears no cost, so may father any number of progeny in a turn.
+        if c.Reproduction(conditions.CpPreyReproductionChance, conditions.CpPreyGestation, rng) {
+          c.sire = mate.genotype
+        }
+      }
     }
     fallthrough
   case "EXPLORE":
-    𝚯 := calc.RandFloatIn(-conditions.CpPreyTurn, conditions.CpPreyTurn)
+    𝚯 := calc.RandFloatIn(-conditions.CpPreyTurn, conditions.CpPreyTurn, rng)
     c.Turn(𝚯)
-    c.Move()
+    c.Move(conditions.Environment)
   }
 
   newpop = append(newpop, *c)
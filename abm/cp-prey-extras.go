@@ -3,6 +3,9 @@ package abm
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
+
+	"github.com/benjamin-rood/abm-cp/calc"
 )
 
 // String returns a clear textual presentation the internal values of the CP Prey agent
@@ -20,25 +23,26 @@ func (c *ColourPolymorphicPrey) String() string {
 	buffer.WriteString(fmt.Sprintf("fertility=%v\n", c.fertility))
 	buffer.WriteString(fmt.Sprintf("gravid=%v\n", c.gravid))
 	buffer.WriteString(fmt.Sprintf("colouration=%v\n", c.colouration))
+	buffer.WriteString(fmt.Sprintf("uv=%v\n", c.uv))
 	return buffer.String()
 }
 
 // extra CP Prey functions for testing/benchmarking:
 
 func cpPreyTesterAgent(xPos float64, yPos float64) (tester ColourPolymorphicPrey) {
-	tester = cpPreyTestPop(1)[0]
+	tester = cpPreyTestPop(1, calc.NewRNG(tRNGSeedVal))[0]
 	tester.pos[x] = xPos
 	tester.pos[y] = yPos
 	return
 }
 
 func newCpPreyTesterAgent(xPos float64, yPos float64) *ColourPolymorphicPrey {
-	tester := cpPreyTestPop(1)[0]
+	tester := cpPreyTestPop(1, calc.NewRNG(tRNGSeedVal))[0]
 	tester.pos[x] = xPos
 	tester.pos[y] = yPos
 	return &tester
 }
 
-func cpPreyTestPop(size int) []ColourPolymorphicPrey {
-	return GenerateCpPreyPopulation(size, 0, 0, TestConditionParams, testStamp)
+func cpPreyTestPop(size int, rng *rand.Rand) []ColourPolymorphicPrey {
+	return GenerateCpPreyPopulation(size, 0, 0, TestConditionParams, testStamp, rng)
 }
@@ -30,6 +30,10 @@ type ColourPolymorphicPrey struct {
 	fertility   int        //	counter for interval between birth and sex
 	gravid      bool       //	i.e. pregnant
 	colouration colour.RGB //	colour
+	uv          float64    //	UV reflectance, invisible to predators without UV-sensitive vision
+	predator    string     //	UUID of the Visual Predator which ate it, if eaten
+	genotype    Genotype   //	deciding colouration, with abm-cp-prey-genetics
+	sire        Genotype   //	of the father of the progeny being carried, with abm-cp-prey-genetics
 }
 
 // UUID is just a getter method for the unexported uuid field, which absolutely must not change after agent creation.
@@ -37,9 +41,15 @@ func (c *ColourPolymorphicPrey) UUID() string {
 	return c.uuid
 }
 
+// eaten reports whether a Visual Predator has caught the agent, which is
+// then removed from the population at the beginning of the next turn.
+func (c *ColourPolymorphicPrey) eaten() bool {
+	return c.predator != ""
+}
+
 // MarshalJSON implements json.Marshaler interface on a CP Prey object
 func (c ColourPolymorphicPrey) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	fields := map[string]interface{}{
 		"description":  c.description,
 		"pos":          c.pos,
 		"speed":        c.movS,
@@ -50,7 +60,12 @@ func (c ColourPolymorphicPrey) MarshalJSON() ([]byte, error) {
 		"hunger":       c.hunger,
 		"fertility":    c.fertility,
 		"colouration":  c.colouration,
-	})
+		"uv":           c.uv,
+	}
+	if c.genotype != nil {
+		fields["genotype"] = c.genotype
+	}
+	return json.Marshal(fields)
 }
 
 // GetDrawInfo exports the data set needed for agent visualisation.
@@ -63,16 +78,16 @@ func (c ColourPolymorphicPrey) GetDrawInfo() (ar render.AgentRender) {
 }
 
 // GenerateCpPreyPopulation will create `size` number of Colour Polymorphic Prey agents
-func GenerateCpPreyPopulation(size int, start int, mt int, conditions ConditionParams, timestamp string) []ColourPolymorphicPrey {
+func GenerateCpPreyPopulation(size int, start int, mt int, conditions ConditionParams, timestamp string, rng *rand.Rand) []ColourPolymorphicPrey {
 	pop := []ColourPolymorphicPrey{}
 	for i := 0; i < size; i++ {
 		agent := ColourPolymorphicPrey{}
-		agent.uuid = uuid()
+		agent.uuid = uuid(rng)
 		agent.description = AgentDescription{AgentType: "CP Prey", AgentNum: start + i, ParentUUID: "", CreatedMT: mt, CreatedAT: timestamp}
-		agent.pos = geometry.RandVector(conditions.Bounds)
+		agent.pos = conditions.RandPosition(rng)
 		if conditions.CpPreyAgeing {
 			if conditions.RandomAges {
-				agent.lifespan = calc.RandIntIn(int(float64(conditions.CpPreyLifespan)*0.7), int(float64(conditions.CpPreyLifespan)*1.3))
+				agent.lifespan = calc.RandIntIn(int(float64(conditions.CpPreyLifespan)*0.7), int(float64(conditions.CpPreyLifespan)*1.3), rng)
 			} else {
 				agent.lifespan = conditions.CpPreyLifespan
 			}
@@ -81,28 +96,38 @@ func GenerateCpPreyPopulation(size int, start int, mt int, conditions ConditionP
 		}
 		agent.movS = conditions.CpPreyS
 		agent.movA = conditions.CpPreyA
-		agent.𝚯 = rand.Float64() * (2 * math.Pi)
+		agent.𝚯 = rng.Float64() * (2 * math.Pi)
 		agent.dir = geometry.UnitVector(agent.𝚯)
 		agent.tr = conditions.CpPreyTurn
 		agent.sr = conditions.CpPreySr
 		agent.hunger = 0
 		agent.fertility = 1
 		agent.gravid = false
-		agent.colouration = colour.RandRGB()
+		agent.colouration = colour.RandRGB(rng)
+		if conditions.CpPreyGenetics != nil {
+			agent.genotype = conditions.CpPreyGenetics.founder(rng)
+			agent.colouration = conditions.CpPreyGenetics.phenotype(agent.genotype)
+		}
+		if conditions.CpPreyUV {
+			agent.uv = rng.Float64()
+		}
 		pop = append(pop, agent)
 	}
 	return pop
 }
 
-func cpPreySpawn(size int, parent ColourPolymorphicPrey, conditions ConditionParams, timestamp string) []ColourPolymorphicPrey {
+// cpPreySpawn leaves the progeny unnumbered: being born concurrently, they
+// are numbered by the model once the CP Prey phase is complete.
+func cpPreySpawn(size int, mt int, parent ColourPolymorphicPrey, conditions ConditionParams, timestamp string, rng *rand.Rand) []ColourPolymorphicPrey {
 	pop := []ColourPolymorphicPrey{}
 	for i := 0; i < size; i++ {
 		agent := parent
-		agent.uuid = uuid()
+		agent.uuid = uuid(rng)
+		agent.description = AgentDescription{AgentType: "CP Prey", ParentUUID: parent.uuid, CreatedMT: mt, CreatedAT: timestamp}
 		agent.pos = parent.pos
 		if conditions.CpPreyAgeing {
 			if conditions.RandomAges {
-				agent.lifespan = calc.RandIntIn(int(float64(conditions.CpPreyLifespan)*0.7), int(float64(conditions.CpPreyLifespan)*1.3))
+				agent.lifespan = calc.RandIntIn(int(float64(conditions.CpPreyLifespan)*0.7), int(float64(conditions.CpPreyLifespan)*1.3), rng)
 			} else {
 				agent.lifespan = conditions.CpPreyLifespan
 			}
@@ -111,7 +136,7 @@ func cpPreySpawn(size int, parent ColourPolymorphicPrey, conditions ConditionPar
 		}
 		agent.movS = parent.movS
 		agent.movA = parent.movA
-		agent.𝚯 = rand.Float64() * (2 * math.Pi)
+		agent.𝚯 = rng.Float64() * (2 * math.Pi)
 		agent.dir = geometry.UnitVector(agent.𝚯)
 		agent.tr = parent.tr
 		agent.sr = parent.sr
@@ -119,6 +144,8 @@ func cpPreySpawn(size int, parent ColourPolymorphicPrey, conditions ConditionPar
 		agent.fertility = 1
 		agent.gravid = false
 		agent.colouration = parent.colouration
+		agent.uv = parent.uv
+		agent.sire = nil
 		pop = append(pop, agent)
 	}
 	return pop
@@ -135,7 +162,8 @@ func (c *ColourPolymorphicPrey) Turn(𝚯 float64) {
 // Move implements agent Mover interface method for ColourPolymorphicPrey:
 // updates the agent's position according to its direction (heading) and
 // velocity (speed*acceleration) if it doesn't encounter any errors.
-func (c *ColourPolymorphicPrey) Move() error {
+// The environment boundary mode decides what happens at its edges.
+func (c *ColourPolymorphicPrey) Move(env Environment) error {
 	var posOffset, newPos geometry.Vector
 	var err error
 	posOffset, err = geometry.VecScalarMultiply(c.dir, c.movS*c.movA)
@@ -146,38 +174,50 @@ func (c *ColourPolymorphicPrey) Move() error {
 	if err != nil {
 		return errors.New("agent move failed: " + err.Error())
 	}
-	newPos[x] = calc.WrapFloatIn(newPos[x], -1.0, 1.0)
-	newPos[y] = calc.WrapFloatIn(newPos[y], -1.0, 1.0)
+	newPos, 𝚯 := env.Confine(newPos, c.𝚯)
+	if 𝚯 != c.𝚯 { //	bounced off the boundary.
+		c.Turn(𝚯 - c.𝚯)
+	}
 	c.pos = newPos
 	return nil
 }
 
-// MateSearch implements Breeder interface method for ColourPolymorphicPrey:
-// NEEDS BETTER HANDLING THAN JUST PUSHING THE ERROR UP!
-func (c *ColourPolymorphicPrey) MateSearch(pop []ColourPolymorphicPrey, skip int) (mate *ColourPolymorphicPrey, err error) {
-	mate = nil
-	err = nil
-	dist := 0.0
-	for i := 0; i < len(pop); i++ {
-		if i == skip {
+// MateSearch picks a sire at random from the CP Prey within search range of
+// the agent (pop[skip]) which haven't been eaten, or nil if there are none.
+// With an index over the positions of pop, only the sectors in range are searched.
+func (c *ColourPolymorphicPrey) MateSearch(pop []ColourPolymorphicPrey, skip int, env Environment, index *geometry.Grid, rng *rand.Rand) *ColourPolymorphicPrey {
+	var candidates []int
+	if index != nil {
+		candidates = index.Within(c.pos, c.sr)
+	} else {
+		candidates = make([]int, len(pop))
+		for i := range pop {
+			candidates[i] = i
+		}
+	}
+	var mates []int
+	for _, i := range candidates {
+		if i == skip || pop[i].eaten() {
 			continue
 		}
-		dist, err = geometry.VectorDistance(c.pos, pop[i].pos)
+		dist, err := env.Distance(c.pos, pop[i].pos)
 		if err != nil {
-			return
+			continue
 		}
 		if dist <= c.sr {
-			mate = &pop[i]
-			return
+			mates = append(mates, i)
 		}
 	}
-	return
+	if len(mates) == 0 {
+		return nil
+	}
+	return &pop[mates[rng.Intn(len(mates))]]
 }
 
 // Reproduction implements Breeder interface method - ASEXUAL (self-reproduction) ColourPolymorphicPrey:
-func (c *ColourPolymorphicPrey) Reproduction(chance float64, gestation int) bool {
+func (c *ColourPolymorphicPrey) Reproduction(chance float64, gestation int, rng *rand.Rand) bool {
 	c.hunger++ //	energy cost
-	ω := rand.Float64()
+	ω := rng.Float64()
 	if ω <= chance {
 		c.gravid = true
 		c.fertility = -gestation
@@ -188,14 +228,14 @@ func (c *ColourPolymorphicPrey) Reproduction(chance float64, gestation int) bool
 }
 
 // Copulation implemets Breeder interface method for ColourPolymorphicPrey:
-func (c *ColourPolymorphicPrey) copulation(mate *ColourPolymorphicPrey, chance float64, gestation int, sexualCost int) bool {
+func (c *ColourPolymorphicPrey) copulation(mate *ColourPolymorphicPrey, chance float64, gestation int, sexualCost int, rng *rand.Rand) bool {
 	if mate == nil {
 		return false
 	}
 	if mate.fertility < sexualCost { //	mate must be sufficiently fertile also
 		return false
 	}
-	ω := rand.Float64()
+	ω := rng.Float64()
 	mate.fertility = -sexualCost // it takes two to tango, buddy!
 	if ω <= chance {
 		c.gravid = true
@@ -207,25 +247,38 @@ func (c *ColourPolymorphicPrey) copulation(mate *ColourPolymorphicPrey, chance f
 }
 
 // Birth implemets Breeder interface method for ColourPolymorphicPrey:
-func (c *ColourPolymorphicPrey) Birth(conditions ConditionParams) []ColourPolymorphicPrey {
+func (c *ColourPolymorphicPrey) Birth(conditions ConditionParams, turn int, rng *rand.Rand) []ColourPolymorphicPrey {
 	n := 1
 	if conditions.CpPreySpawnSize > 1 {
-		n = rand.Intn(conditions.CpPreySpawnSize) + 1 //	i.e. range [1, b]
+		n = rng.Intn(conditions.CpPreySpawnSize) + 1 //	i.e. range [1, b]
 	}
 	timestamp := fmt.Sprintf("%s", time.Now())
-	progeny := cpPreySpawn(n, *c, conditions, timestamp)
+	progeny := cpPreySpawn(n, turn, *c, conditions, timestamp, rng)
 	for i := 0; i < len(progeny); i++ {
-		progeny[i].mutation(conditions.CpPreyMutationFactor)
-		progeny[i].pos, _ = geometry.FuzzifyVector(c.pos, c.movS)
+		if g := conditions.CpPreyGenetics; g != nil && c.genotype != nil && c.sire != nil {
+			progeny[i].genotype = g.offspring(c.genotype, c.sire, rng)
+			progeny[i].colouration = g.phenotype(progeny[i].genotype)
+		}
+		progeny[i].mutation(conditions.CpPreyMutationFactor, conditions.CpPreyUV, rng)
+		progeny[i].pos, _ = conditions.FuzzyPosition(c.pos, c.movS, rng)
 	}
 	c.hunger++ //	energy cost
 	c.gravid = false
+	c.sire = nil
 	return progeny
 }
 
-// For now, mutation only affects colouration, but could be extended to affect any other parameter.
-func (c *ColourPolymorphicPrey) mutation(Mf float64) {
-	c.colouration = colour.RandRGBClamped(c.colouration, Mf)
+// For now, mutation only affects colouration (including UV reflectance, if uv is set),
+// but could be extended to affect any other parameter. The colouration of an
+// agent with a genotype is left to it, having mutated at each locus instead.
+func (c *ColourPolymorphicPrey) mutation(Mf float64, uv bool, rng *rand.Rand) {
+	if c.genotype == nil {
+		c.colouration = colour.RandRGBClamped(c.colouration, Mf, rng)
+	}
+	if uv {
+		diff := rng.NormFloat64() * Mf
+		c.uv = calc.ClampFloatIn(c.uv+calc.RandFloatIn(-diff, diff, rng), 0.0, 1.0)
+	}
 }
 
 // Age decrements the lifespan of an agent,
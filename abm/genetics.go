@@ -0,0 +1,221 @@
+package abm
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// dRecombination is the chance of a crossover between adjacent loci when
+// none is given, i.e. they are unlinked.
+const dRecombination = 0.5
+
+// allelesFile is the CSV time series of allele frequencies written into the log directory.
+const allelesFile = "alleles.csv" //	one row per allele per logged turn
+
+/*
+Genetics is a diploid, multi-locus model of CP Prey colouration. Every
+agent carries two alleles at each of the Loci, one inherited from each
+parent, and its colouration is Base plus the effect of each locus: that of
+the more dominant of its two alleles, or the mean of both when they are
+equally dominant (codominant). Channels are clamped to [0, 1].
+
+A mother conceives with a sire, another CP Prey within her search range
+(abm-cp-prey-sr), and each of her progeny inherits a gamete from each
+parent: one of the two haplotypes of the parent, switching between them
+with the Recombination chance of each locus, and with each allele mutating
+into another of its locus with the locus' Mutation chance. The founding
+population draws its alleles by their Frequency.
+*/
+type Genetics struct {
+	Base colour.RGB `json:"base"` //	colouration before the effect of any locus
+	Loci []Locus    `json:"loci"`
+}
+
+// Locus is a gene affecting colouration, with its possible Alleles.
+type Locus struct {
+	Name          string   `json:"name"`
+	Alleles       []Allele `json:"alleles"`
+	Mutation      float64  `json:"mutation"`      //	chance per gamete that the allele mutates into another of the locus
+	Recombination *float64 `json:"recombination"` //	chance of a crossover between the locus and the one before; 0 is complete linkage. Default = null (dRecombination, unlinked)
+}
+
+// Allele is a variant of a Locus.
+type Allele struct {
+	Name      string     `json:"name"`
+	Effect    [3]float64 `json:"effect"`    //	added to the red, green and blue channels of the colouration
+	Dominance int        `json:"dominance"` //	over the alleles of lower dominance; equals are codominant
+	Frequency float64    `json:"frequency"` //	in the founding population, relative to the others of the locus. Default = 0 (all equal)
+}
+
+// Genotype gives the pair of alleles (by index) at each locus, the first
+// inherited from the mother and the second from the sire.
+type Genotype [][2]int
+
+// founder draws a genotype for a member of the founding population.
+func (g *Genetics) founder(rng *rand.Rand) Genotype {
+	gt := make(Genotype, len(g.Loci))
+	for i, l := range g.Loci {
+		gt[i] = [2]int{l.founder(rng), l.founder(rng)}
+	}
+	return gt
+}
+
+// founder draws an allele by the relative frequencies of the locus.
+func (l Locus) founder(rng *rand.Rand) int {
+	total := 0.0
+	for _, a := range l.Alleles {
+		total += a.Frequency
+	}
+	if total == 0 {
+		return rng.Intn(len(l.Alleles))
+	}
+	u := rng.Float64() * total
+	for i, a := range l.Alleles {
+		if u < a.Frequency {
+			return i
+		}
+		u -= a.Frequency
+	}
+	return len(l.Alleles) - 1
+}
+
+// phenotype gives the colouration of genotype gt.
+func (g *Genetics) phenotype(gt Genotype) colour.RGB {
+	c := [3]float64{g.Base.Red, g.Base.Green, g.Base.Blue}
+	for i, l := range g.Loci {
+		a, b := l.Alleles[gt[i][0]], l.Alleles[gt[i][1]]
+		for ch := range c {
+			switch {
+			case a.Dominance > b.Dominance:
+				c[ch] += a.Effect[ch]
+			case b.Dominance > a.Dominance:
+				c[ch] += b.Effect[ch]
+			default:
+				c[ch] += (a.Effect[ch] + b.Effect[ch]) / 2
+			}
+		}
+	}
+	return colour.RGB{
+		Red:   calc.ClampFloatIn(c[0], 0, 1),
+		Green: calc.ClampFloatIn(c[1], 0, 1),
+		Blue:  calc.ClampFloatIn(c[2], 0, 1),
+	}
+}
+
+// gamete gives the alleles a parent of genotype gt passes on to one of its progeny.
+func (g *Genetics) gamete(gt Genotype, rng *rand.Rand) []int {
+	gamete := make([]int, len(g.Loci))
+	h := rng.Intn(2) //	the haplotype being copied
+	for i, l := range g.Loci {
+		r := dRecombination
+		if l.Recombination != nil {
+			r = *l.Recombination
+		}
+		if i > 0 && rng.Float64() < r {
+			h = 1 - h
+		}
+		gamete[i] = gt[i][h]
+		if len(l.Alleles) > 1 && rng.Float64() < l.Mutation {
+			other := rng.Intn(len(l.Alleles) - 1)
+			if other >= gamete[i] {
+				other++
+			}
+			gamete[i] = other
+		}
+	}
+	return gamete
+}
+
+// offspring gives the genotype of a progeny of mother and sire.
+func (g *Genetics) offspring(mother Genotype, sire Genotype, rng *rand.Rand) Genotype {
+	m, s := g.gamete(mother, rng), g.gamete(sire, rng)
+	gt := make(Genotype, len(g.Loci))
+	for i := range gt {
+		gt[i] = [2]int{m[i], s[i]}
+	}
+	return gt
+}
+
+// String gives the genotype by allele names, e.g. "A/a B/B", or by index without Genetics.
+func (g *Genetics) String(gt Genotype) string {
+	loci := make([]string, len(gt))
+	for i, pair := range gt {
+		name := func(a int) string {
+			if g != nil && i < len(g.Loci) && a < len(g.Loci[i].Alleles) && g.Loci[i].Alleles[a].Name != "" {
+				return g.Loci[i].Alleles[a].Name
+			}
+			return strconv.Itoa(a)
+		}
+		loci[i] = name(pair[0]) + "/" + name(pair[1])
+	}
+	return strings.Join(loci, " ")
+}
+
+// alleleCounts gives the number of copies of each allele of each locus among the genotypes.
+func (g *Genetics) alleleCounts(genotypes []Genotype) [][]int {
+	counts := make([][]int, len(g.Loci))
+	for i, l := range g.Loci {
+		counts[i] = make([]int, len(l.Alleles))
+	}
+	for _, gt := range genotypes {
+		for i := range counts {
+			if i < len(gt) {
+				counts[i][gt[i][0]]++
+				counts[i][gt[i][1]]++
+			}
+		}
+	}
+	return counts
+}
+
+// validate checks the loci, alleles and chances of the genetics.
+func (g *Genetics) validate(v *validator, prefix string) {
+	v.colour(prefix+"base", g.Base)
+	v.check(len(g.Loci) > 0, prefix+"loci", len(g.Loci), "at least one locus")
+	for i, l := range g.Loci {
+		p := fmt.Sprintf("%sloci[%d].", prefix, i)
+		v.check(len(l.Alleles) > 0, p+"alleles", len(l.Alleles), "at least one allele")
+		v.chance(p+"mutation", l.Mutation)
+		if r := l.Recombination; r != nil {
+			v.check(*r >= 0 && *r <= 0.5, p+"recombination", *r, "in [0, 0.5]")
+		}
+		for j, a := range l.Alleles {
+			v.nonNegativeFloat(fmt.Sprintf("%salleles[%d].frequency", p, j), a.Frequency)
+		}
+	}
+}
+
+var allelesHeader = []string{"turn", "locus", "allele", "count", "frequency"}
+
+// alleleRows gives the long-format rows of the allele frequencies of a
+// logged turn from their counts (see alleleCounts), one per allele of each locus.
+func alleleRows(turn int, g *Genetics, alleles [][]int) [][]string {
+	var rows [][]string
+	for i, counts := range alleles {
+		locus := g.Loci[i].Name
+		if locus == "" {
+			locus = strconv.Itoa(i)
+		}
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		for j, n := range counts {
+			allele := g.Loci[i].Alleles[j].Name
+			if allele == "" {
+				allele = strconv.Itoa(j)
+			}
+			frequency := 0.0
+			if total > 0 {
+				frequency = float64(n) / float64(total)
+			}
+			rows = append(rows, []string{strconv.Itoa(turn), locus, allele, strconv.Itoa(n), ftoa(frequency)})
+		}
+	}
+	return rows
+}
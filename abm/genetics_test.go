@@ -0,0 +1,169 @@
+package abm
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// tGenetics has a dominant dark allele over a pale one at locus "d", and two
+// codominant alleles adding green and blue at locus "g".
+var tGenetics = Genetics{
+	Base: colour.RGB{Red: 0.5, Green: 0.5, Blue: 0.5},
+	Loci: []Locus{
+		{Name: "d", Alleles: []Allele{
+			{Name: "D", Effect: [3]float64{-0.4, -0.4, -0.4}, Dominance: 1},
+			{Name: "d", Effect: [3]float64{0.4, 0.4, 0.4}},
+		}},
+		{Name: "g", Alleles: []Allele{
+			{Name: "G", Effect: [3]float64{0, 0.2, 0}},
+			{Name: "B", Effect: [3]float64{0, 0, 0.2}},
+		}},
+	},
+}
+
+func TestPhenotype(t *testing.T) {
+	for _, tc := range []struct {
+		gt   Genotype
+		want colour.RGB
+	}{
+		{Genotype{{0, 1}, {0, 0}}, colour.RGB{Red: 0.1, Green: 0.3, Blue: 0.1}},
+		{Genotype{{1, 1}, {0, 1}}, colour.RGB{Red: 0.9, Green: 1, Blue: 1}},
+		{Genotype{{1, 0}, {1, 1}}, colour.RGB{Red: 0.1, Green: 0.1, Blue: 0.3}},
+	} {
+		got := tGenetics.phenotype(tc.gt)
+		if math.Abs(got.Red-tc.want.Red)+math.Abs(got.Green-tc.want.Green)+math.Abs(got.Blue-tc.want.Blue) > 1e-9 {
+			t.Errorf("%s: phenotype %+v, want %+v", tGenetics.String(tc.gt), got, tc.want)
+		}
+	}
+}
+
+func TestInheritance(t *testing.T) {
+	rng := calc.NewRNG(1)
+	const n = 20000
+	g := tGenetics
+	g.Loci = append([]Locus(nil), g.Loci...)
+	r := 0.1
+	g.Loci[1].Recombination = &r
+	heterozygote := Genotype{{0, 1}, {0, 1}} //	D-G on one haplotype, d-B on the other
+	dd, recombinant := 0, 0
+	for i := 0; i < n; i++ {
+		child := g.offspring(heterozygote, heterozygote, rng)
+		if child[0] == [2]int{1, 1} {
+			dd++
+		}
+		if child[0][0] != child[1][0] { //	the maternal gamete
+			recombinant++
+		}
+	}
+	if f := float64(dd) / n; math.Abs(f-0.25) > 0.02 {
+		t.Errorf("dd in %v of the progeny of Dd x Dd, want 0.25", f)
+	}
+	if f := float64(recombinant) / n; math.Abs(f-0.1) > 0.02 {
+		t.Errorf("%v recombinant gametes, want 0.1", f)
+	}
+
+	r = 0 //	complete linkage
+	for i := 0; i < 1000; i++ {
+		if gamete := g.gamete(heterozygote, rng); gamete[0] != gamete[1] {
+			t.Fatalf("recombinant gamete %v with complete linkage", gamete)
+		}
+	}
+
+	g.Loci[0].Mutation = 1
+	for i := 0; i < 100; i++ {
+		if gamete := g.gamete(Genotype{{0, 0}, {0, 0}}, rng); gamete[0] != 1 {
+			t.Fatalf("gamete %v of DD didn't mutate", gamete)
+		}
+	}
+}
+
+func TestValidateGenetics(t *testing.T) {
+	c := TestConditionParams
+	r := 0.6
+	c.CpPreyGenetics = &Genetics{Loci: []Locus{
+		{Alleles: []Allele{{Frequency: -1}}, Recombination: &r},
+		{Mutation: 2},
+	}}
+	c.Protocol = []ProtocolAction{{Turn: 5, Action: ActionAddCpPrey, Count: 1, Colour: &colour.RGB{}}}
+	invalid, ok := c.Validate().(ValidationError)
+	if !ok {
+		t.Fatalf("Validate() == %v, want a ValidationError", invalid)
+	}
+	var keys []string
+	for _, fe := range invalid {
+		keys = append(keys, fe.Key)
+	}
+	want := []string{
+		"abm-cp-prey-genetics.loci[0].recombination",
+		"abm-cp-prey-genetics.loci[0].alleles[0].frequency",
+		"abm-cp-prey-genetics.loci[1].alleles",
+		"abm-cp-prey-genetics.loci[1].mutation",
+		"abm-protocol[0].colour",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("invalid keys %q, want %q", keys, want)
+	}
+}
+
+func TestGeneticsRun(t *testing.T) {
+	m := testModel(t, tFixedDuration)
+	m.CpPreyGenetics = &tGenetics
+	runBatch(t, m)
+	if len(m.popCpPrey) == 0 {
+		t.Fatal("cpPrey extinct")
+	}
+	for _, c := range m.popCpPrey {
+		if len(c.genotype) != 2 || c.colouration != tGenetics.phenotype(c.genotype) {
+			t.Fatalf("cpPrey %s: colouration %+v of genotype %v", c.uuid, c.colouration, c.genotype)
+		}
+	}
+
+	rows := readLog(t, m, allelesFile)
+	summaries := readLog(t, m, summaryFile)
+	if len(rows)-1 != 4*(len(summaries)-1) {
+		t.Fatalf("%s: %d rows for %d logged turns", allelesFile, len(rows)-1, len(summaries)-1)
+	}
+	total := map[string]float64{}
+	copies := map[string]int{} //	of the alleles of locus "d", by turn
+	for _, row := range rows[1:] {
+		f, _ := strconv.ParseFloat(row[4], 64)
+		total[row[0]+row[1]] += f
+		if row[1] == "d" {
+			n, _ := strconv.Atoi(row[3])
+			copies[row[0]] += n
+		}
+	}
+	for key, f := range total {
+		if math.Abs(f-1) > 1e-6 && f != 0 {
+			t.Errorf("%s: allele frequencies sum to %v", key, f)
+		}
+	}
+	for _, row := range summaries[1:] { //	two copies in each living CP Prey
+		if pop, _ := strconv.Atoi(row[1]); copies[row[0]] != 2*pop {
+			t.Errorf("turn %s: %d copies of the alleles of d for %d cpPrey", row[0], copies[row[0]], pop)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := m.Checkpoint(&buf); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := RestoreModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resumed.CpPreyGenetics, m.CpPreyGenetics) {
+		t.Errorf("genetics restored as %+v", resumed.CpPreyGenetics)
+	}
+	for i := range m.popCpPrey {
+		if !reflect.DeepEqual(m.popCpPrey[i].state(), resumed.popCpPrey[i].state()) {
+			t.Fatalf("cpPrey %d restored as %+v, want %+v", i, resumed.popCpPrey[i].state(), m.popCpPrey[i].state())
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package abm
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// Causes of agent death.
+const (
+	CauseEaten   = "eaten"   //	CP Prey caught by a Visual Predator
+	CauseAged    = "aged"    //	end of lifespan
+	CauseStarved = "starved" //	Visual Predator past its starvation point
+	CauseRemoved = "removed" //	taken out of the model by a protocol action
+)
+
+// Files of the genealogy written into the log directory when LogLineage is set.
+const (
+	lineageFile       = "lineage.csv"    //	edge list of both species
+	lineageNewickFile = "lineage_%s.nwk" //	one Newick forest per species
+)
+
+// LineageRecord is the life history of a single agent.
+type LineageRecord struct {
+	UUID     string     `json:"uuid"`
+	Species  string     `json:"species"` //	"cpPrey" or "vp", as in render.AgentRender
+	AgentNum int        `json:"agent-num"`
+	Parent   string     `json:"parent,omitempty"` //	UUID, none for the founding population
+	Born     int        `json:"born"`             //	turn
+	Died     int        `json:"died"`             //	turn, or -1 while alive
+	Cause    string     `json:"cause,omitempty"`  //	of death
+	Colour   colour.RGB `json:"colour"`           //	at birth: colouration of CP Prey, imprinted target τ of predators
+}
+
+/*
+Lineage is the genealogy of every agent of a model, from the founding
+populations on, kept when ConditionParams.LogLineage is set. A nil
+*Lineage records nothing. Its fields are exported only so that it can be
+checkpointed.
+*/
+type Lineage struct {
+	Agents map[string]*LineageRecord `json:"agents"` //	by UUID
+}
+
+func newLineage() *Lineage {
+	return &Lineage{Agents: make(map[string]*LineageRecord)}
+}
+
+func (l *Lineage) born(r LineageRecord) {
+	if l == nil {
+		return
+	}
+	r.Died = -1
+	l.Agents[r.UUID] = &r
+}
+
+func (l *Lineage) cpPreyBorn(c ColourPolymorphicPrey, turn int) {
+	l.born(LineageRecord{UUID: c.uuid, Species: "cpPrey", AgentNum: c.description.AgentNum, Parent: c.description.ParentUUID, Born: turn, Colour: c.colouration})
+}
+
+func (l *Lineage) vpBorn(vp VisualPredator, turn int) {
+	l.born(LineageRecord{UUID: vp.uuid, Species: "vp", AgentNum: vp.description.AgentNum, Parent: vp.description.ParentUUID, Born: turn, Colour: vp.τ})
+}
+
+// died records the death of an agent, unless its death is already known.
+func (l *Lineage) died(uuid string, turn int, cause string) {
+	if l == nil {
+		return
+	}
+	if r, ok := l.Agents[uuid]; ok && r.Died < 0 {
+		r.Died, r.Cause = turn, cause
+	}
+}
+
+// records gives the records of species ("" for all), ordered by species and agent number.
+func (l *Lineage) records(species string) []*LineageRecord {
+	var rs []*LineageRecord
+	for _, r := range l.Agents {
+		if species == "" || r.Species == species {
+			rs = append(rs, r)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Species != rs[j].Species {
+			return rs[i].Species < rs[j].Species
+		}
+		return rs[i].AgentNum < rs[j].AgentNum
+	})
+	return rs
+}
+
+var lineageHeader = []string{
+	"parent_uuid", "uuid", "species", "agent_num", "birth_turn", "death_turn", "cause", "red", "green", "blue",
+}
+
+// WriteEdgeList writes the genealogy as CSV, one parent–child edge per agent.
+// Founders have no parent_uuid, and agents still alive no death_turn.
+func (l *Lineage) WriteEdgeList(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(lineageHeader)
+	for _, r := range l.records("") {
+		died := ""
+		if r.Died >= 0 {
+			died = strconv.Itoa(r.Died)
+		}
+		cw.Write([]string{
+			r.Parent, r.UUID, r.Species, strconv.Itoa(r.AgentNum), strconv.Itoa(r.Born), died, r.Cause,
+			ftoa(r.Colour.Red), ftoa(r.Colour.Green), ftoa(r.Colour.Blue),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+/*
+WriteNewick writes the genealogy of species as a Newick tree, labelled by
+UUID. Every parent is the internal node of its offspring, with branch
+lengths in turns between the births of parent and child; the founders
+(and any agent whose parent is unknown) are joined at a root at turn 0.
+*/
+func (l *Lineage) WriteNewick(w io.Writer, species string) error {
+	rs := l.records(species)
+	offspring := make(map[string][]*LineageRecord)
+	var founders []*LineageRecord
+	for _, r := range rs {
+		if p, ok := l.Agents[r.Parent]; ok && p.Species == species {
+			offspring[r.Parent] = append(offspring[r.Parent], r)
+		} else {
+			founders = append(founders, r)
+		}
+	}
+	var b strings.Builder
+	var subtree func(r *LineageRecord, from int)
+	subtree = func(r *LineageRecord, from int) {
+		if children := offspring[r.UUID]; len(children) > 0 {
+			b.WriteByte('(')
+			for i, c := range children {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				subtree(c, r.Born)
+			}
+			b.WriteByte(')')
+		}
+		fmt.Fprintf(&b, "%s:%d", r.UUID, r.Born-from)
+	}
+	b.WriteByte('(')
+	for i, r := range founders {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		subtree(r, 0)
+	}
+	b.WriteString(");\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLineage exports the genealogy into the log directory, replacing any earlier export.
+func (m *Model) writeLineage() error {
+	if m.lineage == nil || !m.Logging {
+		return nil
+	}
+	err := os.MkdirAll(m.LogPath, 0777)
+	if err != nil {
+		return err
+	}
+	write := func(name string, export func(io.Writer) error) error {
+		f, err := os.Create(filepath.Join(m.LogPath, name))
+		if err != nil {
+			return err
+		}
+		err = export(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	err = write(lineageFile, m.lineage.WriteEdgeList)
+	if err != nil {
+		return err
+	}
+	for _, species := range []string{"cpPrey", "vp"} {
+		species := species
+		err = write(fmt.Sprintf(lineageNewickFile, species), func(w io.Writer) error { return m.lineage.WriteNewick(w, species) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startLineage begins the genealogy (when LogLineage is set) with the current populations as founders.
+func (m *Model) startLineage() {
+	m.lineage = nil
+	if !m.LogLineage {
+		return
+	}
+	m.lineage = newLineage()
+	for _, c := range m.popCpPrey {
+		m.lineage.cpPreyBorn(c, m.Turn)
+	}
+	for _, vp := range m.popVisualPredator {
+		m.lineage.vpBorn(vp, m.Turn)
+	}
+}
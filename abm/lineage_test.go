@@ -0,0 +1,72 @@
+package abm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLineage(t *testing.T) {
+	m := testModel(t, 20)
+	m.CpPreyAgeing = true
+	m.CpPreyLifespan = 6
+	m.LogLineage = true
+	summary := runBatch(t, m)
+
+	rows := readLog(t, m, lineageFile)[1:]
+	if want := summary.CpPreyCreated + summary.VpCreated; len(rows) != want {
+		t.Fatalf("%s: %d agents, want %d created", lineageFile, len(rows), want)
+	}
+	born := map[string]int{}
+	for _, row := range rows {
+		born[row[1]], _ = strconv.Atoi(row[4])
+	}
+	causes := map[string]int{}
+	for _, row := range rows {
+		parent, death, cause := row[0], row[5], row[6]
+		if pb, ok := born[parent]; parent != "" && (!ok || pb > born[row[1]]) {
+			t.Errorf("%s born on turn %d of parent %q born on turn %d", row[1], born[row[1]], parent, pb)
+		}
+		if (death == "") != (cause == "") {
+			t.Errorf("%s: died on turn %q of %q", row[1], death, cause)
+		}
+		causes[cause]++
+	}
+	if causes[CauseAged] == 0 {
+		t.Errorf("no prey died of old age: %v", causes)
+	}
+	for _, c := range m.popCpPrey {
+		if alive := c.lifespan > 0; alive != (m.lineage.Agents[c.uuid].Died < 0) { //	prey eaten on the last turn are yet to be removed.
+			t.Errorf("cpPrey %s: alive == %v, lineage record %+v", c.uuid, alive, *m.lineage.Agents[c.uuid])
+		}
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(m.LogPath, "lineage_cpPrey.nwk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newick := string(raw)
+	if strings.Count(newick, "(") != strings.Count(newick, ")") || !strings.HasSuffix(newick, ";\n") {
+		t.Errorf("malformed Newick tree: %.80s...", newick)
+	}
+}
+
+func TestLineageNewick(t *testing.T) {
+	l := newLineage()
+	l.born(LineageRecord{UUID: "a", Species: "cpPrey", AgentNum: 0})
+	l.born(LineageRecord{UUID: "b", Species: "cpPrey", AgentNum: 1})
+	l.born(LineageRecord{UUID: "c", Species: "cpPrey", AgentNum: 2, Parent: "a", Born: 3})
+	l.born(LineageRecord{UUID: "d", Species: "cpPrey", AgentNum: 3, Parent: "c", Born: 5})
+	l.born(LineageRecord{UUID: "e", Species: "cpPrey", AgentNum: 4, Parent: "a", Born: 7})
+	l.born(LineageRecord{UUID: "v", Species: "vp", AgentNum: 0})
+	var buf bytes.Buffer
+	if err := l.WriteNewick(&buf, "cpPrey"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "(((d:2)c:3,e:7)a:0,b:0);\n"; buf.String() != want {
+		t.Errorf("WriteNewick == %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,363 @@
+package abm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+const (
+	checkpointVersion = 1
+	checkpointFile    = "checkpoint.json"
+	checkpointDir     = "checkpoints"
+)
+
+// checkpoint is the serialised form of a Model between turns.
+type checkpoint struct {
+	Version         int              `json:"version"`
+	Timestamp       string           `json:"timestamp"`
+	Timeframe       Timeframe        `json:"timeframe"`
+	Environment     Environment      `json:"environment"`
+	ConditionParams ConditionParams  `json:"conditions"`
+	Stats           statsState       `json:"stats"`
+	Seed            int64            `json:"rng-seed"`
+	RNGState        uint64           `json:"rng-state"`
+	CpPrey          []cpPreyState    `json:"cp-prey"`
+	Vp              []vpState        `json:"vp"`
+	Apostatic       *stats.Apostatic `json:"apostatic,omitempty"` //	selection measured so far in the current window
+	Lineage         *Lineage         `json:"lineage,omitempty"`   //	when LogLineage is set
+	Updates         []Intervention   `json:"updates,omitempty"`   //	made by Update, waiting for the next turn
+}
+
+type statsState struct {
+	NumCpPreyCreated int `json:"cp-prey-created"`
+	NumCpPreyEaten   int `json:"cp-prey-eaten"`
+	NumCpPreyDeath   int `json:"cp-prey-deaths"`
+	NumVpCreated     int `json:"vp-created"`
+	NumVpDeath       int `json:"vp-deaths"`
+}
+
+// cpPreyState mirrors every field of ColourPolymorphicPrey.
+type cpPreyState struct {
+	UUID        string           `json:"uuid"`
+	Description AgentDescription `json:"description"`
+	Pos         geometry.Vector  `json:"pos"`
+	MovS        float64          `json:"speed"`
+	MovA        float64          `json:"acceleration"`
+	Heading     float64          `json:"heading"`
+	Dir         geometry.Vector  `json:"dir"`
+	Tr          float64          `json:"turn-rate"`
+	Sr          float64          `json:"search-range"`
+	Lifespan    int              `json:"lifespan"`
+	Hunger      int              `json:"hunger"`
+	Fertility   int              `json:"fertility"`
+	Gravid      bool             `json:"gravid"`
+	Colouration colour.RGB       `json:"colouration"`
+	UV          float64          `json:"uv"`
+	Predator    string           `json:"eaten-by,omitempty"`
+	Genotype    Genotype         `json:"genotype,omitempty"`
+	Sire        Genotype         `json:"sire,omitempty"`
+}
+
+// vpState mirrors every field of VisualPredator.
+type vpState struct {
+	UUID          string           `json:"uuid"`
+	Description   AgentDescription `json:"description"`
+	Pos           geometry.Vector  `json:"pos"`
+	MovS          float64          `json:"speed"`
+	MovA          float64          `json:"acceleration"`
+	Tr            float64          `json:"turn-rate"`
+	Dir           geometry.Vector  `json:"dir"`
+	Heading       float64          `json:"heading"`
+	Lifespan      int              `json:"lifespan"`
+	Hunger        int              `json:"hunger"`
+	AttackSuccess bool             `json:"attack-success"`
+	Fertility     int              `json:"fertility"`
+	Gravid        bool             `json:"gravid"`
+	Vsr           float64          `json:"search-range"`
+	Tolerance     float64          `json:"𝛄"`
+	Imprint       colour.RGB       `json:"τ"`
+	ImprintUV     float64          `json:"τuv"`
+	ImprintFactor float64          `json:"ετ"`
+	Vision        string           `json:"vision"`
+}
+
+func (c *ColourPolymorphicPrey) state() cpPreyState {
+	return cpPreyState{
+		UUID:        c.uuid,
+		Description: c.description,
+		Pos:         c.pos,
+		MovS:        c.movS,
+		MovA:        c.movA,
+		Heading:     c.𝚯,
+		Dir:         c.dir,
+		Tr:          c.tr,
+		Sr:          c.sr,
+		Lifespan:    c.lifespan,
+		Hunger:      c.hunger,
+		Fertility:   c.fertility,
+		Gravid:      c.gravid,
+		Colouration: c.colouration,
+		UV:          c.uv,
+		Predator:    c.predator,
+		Genotype:    c.genotype,
+		Sire:        c.sire,
+	}
+}
+
+func (s cpPreyState) agent() ColourPolymorphicPrey {
+	return ColourPolymorphicPrey{
+		uuid:        s.UUID,
+		description: s.Description,
+		pos:         s.Pos,
+		movS:        s.MovS,
+		movA:        s.MovA,
+		𝚯:           s.Heading,
+		dir:         s.Dir,
+		tr:          s.Tr,
+		sr:          s.Sr,
+		lifespan:    s.Lifespan,
+		hunger:      s.Hunger,
+		fertility:   s.Fertility,
+		gravid:      s.Gravid,
+		colouration: s.Colouration,
+		uv:          s.UV,
+		predator:    s.Predator,
+		genotype:    s.Genotype,
+		sire:        s.Sire,
+	}
+}
+
+func (vp *VisualPredator) state() vpState {
+	return vpState{
+		UUID:          vp.uuid,
+		Description:   vp.description,
+		Pos:           vp.pos,
+		MovS:          vp.movS,
+		MovA:          vp.movA,
+		Tr:            vp.tr,
+		Dir:           vp.dir,
+		Heading:       vp.𝚯,
+		Lifespan:      vp.lifespan,
+		Hunger:        vp.hunger,
+		AttackSuccess: vp.attackSuccess,
+		Fertility:     vp.fertility,
+		Gravid:        vp.gravid,
+		Vsr:           vp.vsr,
+		Tolerance:     vp.𝛄,
+		Imprint:       vp.τ,
+		ImprintUV:     vp.τuv,
+		ImprintFactor: vp.ετ,
+		Vision:        vp.vision,
+	}
+}
+
+func (s vpState) agent() VisualPredator {
+	return VisualPredator{
+		uuid:          s.UUID,
+		description:   s.Description,
+		pos:           s.Pos,
+		movS:          s.MovS,
+		movA:          s.MovA,
+		tr:            s.Tr,
+		dir:           s.Dir,
+		𝚯:             s.Heading,
+		lifespan:      s.Lifespan,
+		hunger:        s.Hunger,
+		attackSuccess: s.AttackSuccess,
+		fertility:     s.Fertility,
+		gravid:        s.Gravid,
+		vsr:           s.Vsr,
+		𝛄:             s.Tolerance,
+		τ:             s.Imprint,
+		τuv:           s.ImprintUV,
+		ετ:            s.ImprintFactor,
+		vision:        s.Vision,
+	}
+}
+
+// Checkpoint writes the complete state of a suspended (or stopped) Model to w,
+// such that RestoreModel can continue it exactly where it left off.
+func (m *Model) Checkpoint(w io.Writer) error {
+	if m.running {
+		return errors.New("Model: Checkpoint() failed: model must be suspended first")
+	}
+	return m.checkpoint(w)
+}
+
+// checkpoint must only be called between turns.
+func (m *Model) checkpoint(w io.Writer) error {
+	if m.rngSrc == nil {
+		return errors.New("Model: checkpoint failed: model has not been started")
+	}
+	cp := checkpoint{
+		Version:         checkpointVersion,
+		Timestamp:       m.timestamp,
+		Timeframe:       m.Timeframe,
+		Environment:     m.Environment,
+		ConditionParams: m.ConditionParams,
+		Stats: statsState{
+			NumCpPreyCreated: m.numCpPreyCreated,
+			NumCpPreyEaten:   m.numCpPreyEaten,
+			NumCpPreyDeath:   m.numCpPreyDeath,
+			NumVpCreated:     m.numVpCreated,
+			NumVpDeath:       m.numVpDeath,
+		},
+		Seed:      m.seed,
+		RNGState:  m.rngSrc.State(),
+		Apostatic: m.apostatic,
+		Lineage:   m.lineage,
+	}
+	m.updateMu.Lock()
+	cp.Updates = append([]Intervention(nil), m.updates...)
+	m.updateMu.Unlock()
+	for i := range m.popCpPrey {
+		cp.CpPrey = append(cp.CpPrey, m.popCpPrey[i].state())
+	}
+	for i := range m.popVisualPredator {
+		cp.Vp = append(cp.Vp, m.popVisualPredator[i].state())
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cp)
+}
+
+// RestoreModel creates a suspended Model from a checkpoint written by
+// Model.Checkpoint. Call Resume (or RunBatch) to continue it.
+func RestoreModel(r io.Reader) (*Model, error) {
+	m := NewModel()
+	err := m.restore(r)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// restore overwrites the state of m (which must not be running) from a checkpoint,
+// keeping its communication channels intact.
+func (m *Model) restore(r io.Reader) error {
+	if m.running {
+		return errors.New("Model: restore failed: model currently running")
+	}
+	var cp checkpoint
+	err := json.NewDecoder(r).Decode(&cp)
+	if err != nil {
+		return fmt.Errorf("Model: restore failed: %s", err)
+	}
+	if cp.Version != checkpointVersion {
+		return fmt.Errorf("Model: restore failed: unsupported checkpoint version %d", cp.Version)
+	}
+	err = cp.ConditionParams.Validate()
+	if err != nil {
+		return err
+	}
+	m.timestamp = cp.Timestamp
+	m.Timeframe = cp.Timeframe
+	m.Environment = cp.Environment
+	m.ConditionParams = cp.ConditionParams
+	m.numCpPreyCreated = cp.Stats.NumCpPreyCreated
+	m.numCpPreyEaten = cp.Stats.NumCpPreyEaten
+	m.numCpPreyDeath = cp.Stats.NumCpPreyDeath
+	m.numVpCreated = cp.Stats.NumVpCreated
+	m.numVpDeath = cp.Stats.NumVpDeath
+	m.apostatic = cp.Apostatic //	nil (a new window) for a checkpoint written without one
+	m.lineage = cp.Lineage
+	m.updateMu.Lock()
+	m.updates = cp.Updates
+	m.updateMu.Unlock()
+	m.seed = cp.Seed
+	m.rngSrc = calc.NewSource(int64(cp.RNGState))
+	m.rng = rand.New(m.rngSrc)
+	m.popCpPrey = nil
+	for _, s := range cp.CpPrey {
+		m.popCpPrey = append(m.popCpPrey, s.agent())
+	}
+	m.popVisualPredator = nil
+	for _, s := range cp.Vp {
+		m.popVisualPredator = append(m.popVisualPredator, s.agent())
+	}
+	m.resumed = true
+	return nil
+}
+
+// autoCheckpoint writes a checkpoint into the log path every CheckpointFreq turns.
+// The previous checkpoint is only replaced once the new one is complete.
+func (m *Model) autoCheckpoint() error {
+	if m.CheckpointFreq <= 0 || m.Turn%m.CheckpointFreq != 0 {
+		return nil
+	}
+	return m.writeCheckpoint(filepath.Join(m.LogPath, checkpointFile))
+}
+
+func (m *Model) writeCheckpoint(filename string) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0777)
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = m.checkpoint(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// SessionCheckpointPath is where the checkpoint of a suspended web session is kept,
+// so that it can be resumed by name after a dropped connection or server restart.
+func SessionCheckpointPath(session string) string {
+	return path.Join(os.Getenv("HOME")+os.Getenv("HOMEPATH"), abmlogPath, checkpointDir, session+".json")
+}
+
+// validSession reports whether session can name a checkpoint: it must not be
+// empty, nor lead out of the checkpoint directory by a path separator or "..".
+func validSession(session string) bool {
+	return session != "" && !strings.ContainsAny(session, `/\`) && !strings.Contains(session, "..")
+}
+
+// SaveSession suspends the model (if running) and writes its checkpoint to SessionCheckpointPath.
+func (m *Model) SaveSession() error {
+	if m.rngSrc == nil {
+		return nil //	never started, nothing to save.
+	}
+	if m.running {
+		err := m.Suspend()
+		if err != nil {
+			return err
+		}
+	}
+	if !validSession(m.SessionIdentifier) {
+		return fmt.Errorf("Model: SaveSession() failed: invalid session name %q", m.SessionIdentifier)
+	}
+	return m.writeCheckpoint(SessionCheckpointPath(m.SessionIdentifier))
+}
+
+// LoadSession restores the model from the checkpoint saved for session by SaveSession.
+func (m *Model) LoadSession(session string) error {
+	if !validSession(session) {
+		return fmt.Errorf("Model: LoadSession() failed: invalid session name %q", session)
+	}
+	f, err := os.Open(SessionCheckpointPath(session))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.restore(f)
+}
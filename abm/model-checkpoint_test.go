@@ -0,0 +1,91 @@
+package abm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abm-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	model := func(duration int) *Model {
+		m := NewModel()
+		m.ConditionParams = TestConditionParams
+		m.Logging = false
+		m.FixedDuration = duration
+		m.ApostaticWindow = 7 //	the checkpoint falls mid-window
+		m.OutputDir = dir
+		return m
+	}
+
+	straight := model(20)
+	if _, err := straight.RunBatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := model(10)
+	if _, err := first.RunBatch(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := first.Checkpoint(&buf); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := RestoreModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Turn != 10 {
+		t.Fatalf("restored at turn %d, want 10", resumed.Turn)
+	}
+	resumed.FixedDuration = 20
+	resumed.OutputDir = dir
+	if _, err := resumed.RunBatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	if straight.Stats != resumed.Stats {
+		t.Errorf("stats differ:\nstraight = %+v\nresumed  = %+v", straight.Stats, resumed.Stats)
+	}
+	if !reflect.DeepEqual(straight.apostatic, resumed.apostatic) {
+		t.Errorf("apostatic selection differs:\nstraight = %+v\nresumed  = %+v", straight.apostatic, resumed.apostatic)
+	}
+	if len(straight.popCpPrey) != len(resumed.popCpPrey) || len(straight.popVisualPredator) != len(resumed.popVisualPredator) {
+		t.Fatalf("population sizes differ: %d/%d vs %d/%d", len(straight.popCpPrey), len(straight.popVisualPredator), len(resumed.popCpPrey), len(resumed.popVisualPredator))
+	}
+	for i := range straight.popCpPrey {
+		a, b := straight.popCpPrey[i].state(), resumed.popCpPrey[i].state()
+		a.Description.CreatedAT, b.Description.CreatedAT = "", "" //	wall-clock time
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("cpPrey %d differs:\nstraight = %+v\nresumed  = %+v", i, a, b)
+		}
+	}
+	for i := range straight.popVisualPredator {
+		a, b := straight.popVisualPredator[i].state(), resumed.popVisualPredator[i].state()
+		a.Description.CreatedAT, b.Description.CreatedAT = "", ""
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("vp %d differs:\nstraight = %+v\nresumed  = %+v", i, a, b)
+		}
+	}
+}
+
+func TestSessionName(t *testing.T) {
+	m := testModel(t, 2)
+	runBatch(t, m)
+	for _, session := range []string{"", "..", "../../x", "a/b", `a\b`} {
+		if err := m.LoadSession(session); err == nil {
+			t.Errorf("LoadSession(%q): no error", session)
+		}
+		m.SessionIdentifier = session
+		if err := m.SaveSession(); err == nil {
+			t.Errorf("SaveSession() of %q: no error", session)
+		}
+	}
+}
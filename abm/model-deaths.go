@@ -0,0 +1,148 @@
+package abm
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+)
+
+// deathsFile is the CSV log of every death, written into the log directory.
+const deathsFile = "deaths.csv"
+
+// DeathEvent records the death of an agent: where, when, why, and what it
+// looked like. Colour is the colouration of CP Prey, and the imprinted
+// target τ of predators.
+type DeathEvent struct {
+	Turn     int             `json:"turn"`
+	UUID     string          `json:"uuid"`
+	Species  string          `json:"species"` //	"cpPrey" or "vp"
+	Cause    string          `json:"cause"`   //	CauseEaten, CauseAged or CauseStarved
+	Pos      geometry.Vector `json:"pos"`
+	Colour   colour.RGB      `json:"colour"`
+	UV       float64         `json:"uv"`
+	Predator string          `json:"predator,omitempty"` //	UUID of the Visual Predator, for CauseEaten
+}
+
+func cpPreyDeath(c ColourPolymorphicPrey, turn int, cause string) DeathEvent {
+	return DeathEvent{Turn: turn, UUID: c.uuid, Species: "cpPrey", Cause: cause, Pos: c.pos, Colour: c.colouration, UV: c.uv, Predator: c.predator}
+}
+
+func vpDeath(vp VisualPredator, turn int, cause string) DeathEvent {
+	return DeathEvent{Turn: turn, UUID: vp.uuid, Species: "vp", Cause: cause, Pos: vp.pos, Colour: vp.τ, UV: vp.τuv}
+}
+
+// died accounts for a death during the turn in progress. It must only be
+// called from the RUN process, outside of any concurrent agent actions.
+func (m *Model) died(e DeathEvent) {
+	switch e.Species {
+	case "cpPrey":
+		m.numCpPreyDeath++
+		if e.Cause == CauseEaten {
+			m.numCpPreyEaten++
+		}
+	case "vp":
+		m.numVpDeath++
+	}
+	m.lineage.died(e.UUID, e.Turn, e.Cause)
+	m.turnDeaths = append(m.turnDeaths, e)
+}
+
+/*
+deathsPublish hands the deaths of the turn just completed to the LOG
+process (when logging) and to every subscriber, in the order they
+happened. Delivery blocks the model until each subscriber has received
+every event, so that none are lost.
+*/
+func (m *Model) deathsPublish() {
+	events := m.turnDeaths
+	m.turnDeaths = nil
+	if len(events) == 0 {
+		return
+	}
+	if m.Logging {
+		m.rStatsMu.Lock()
+		m.deathQueue = append(m.deathQueue, events...)
+		m.rStatsMu.Unlock()
+	}
+	m.deathSubs.publish(events)
+}
+
+// deathsTake returns the deaths queued for logging, in order, emptying the queue.
+func (m *Model) deathsTake() []DeathEvent {
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	queue := m.deathQueue
+	m.deathQueue = nil
+	return queue
+}
+
+/*
+SubscribeDeaths returns a channel on which every subsequent DeathEvent of
+the model is delivered, at the end of the turn it happened in, and a
+function which ends the subscription (after which the channel is closed).
+A subscriber must keep receiving until it unsubscribes: the model waits
+for it, so that the events are complete.
+*/
+func (m *Model) SubscribeDeaths() (<-chan DeathEvent, func()) {
+	return m.deathSubs.subscribe()
+}
+
+type deathSubscriber struct {
+	events chan DeathEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// deathSubscriptions are the current subscribers to the DeathEvents of a model.
+type deathSubscriptions struct {
+	mu   sync.Mutex
+	subs map[*deathSubscriber]bool
+}
+
+func (ds *deathSubscriptions) subscribe() (<-chan DeathEvent, func()) {
+	s := &deathSubscriber{events: make(chan DeathEvent), done: make(chan struct{})}
+	ds.mu.Lock()
+	if ds.subs == nil {
+		ds.subs = make(map[*deathSubscriber]bool)
+	}
+	ds.subs[s] = true
+	ds.mu.Unlock()
+	unsubscribe := func() {
+		s.once.Do(func() {
+			close(s.done) //	releases any delivery in progress, which holds the lock.
+			ds.mu.Lock()
+			delete(ds.subs, s)
+			close(s.events)
+			ds.mu.Unlock()
+		})
+	}
+	return s.events, unsubscribe
+}
+
+func (ds *deathSubscriptions) publish(events []DeathEvent) {
+	defer ds.mu.Unlock()
+	ds.mu.Lock()
+	for s := range ds.subs {
+	Deliver:
+		for _, e := range events {
+			select {
+			case s.events <- e:
+			case <-s.done:
+				break Deliver
+			}
+		}
+	}
+}
+
+var deathsHeader = []string{
+	"turn", "species", "uuid", "cause", "x", "y", "red", "green", "blue", "uv", "predator_uuid",
+}
+
+func (e DeathEvent) row() []string {
+	return []string{
+		strconv.Itoa(e.Turn), e.Species, e.UUID, e.Cause, ftoa(e.Pos[x]), ftoa(e.Pos[y]),
+		ftoa(e.Colour.Red), ftoa(e.Colour.Green), ftoa(e.Colour.Blue), ftoa(e.UV), e.Predator,
+	}
+}
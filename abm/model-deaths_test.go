@@ -0,0 +1,50 @@
+package abm
+
+import (
+	"testing"
+)
+
+func TestDeathEvents(t *testing.T) {
+	m := testModel(t, 20)
+	m.CpPreyAgeing = true
+	m.CpPreyLifespan = 6
+	m.CollectSeries = true
+
+	events, unsubscribe := m.SubscribeDeaths()
+	received := make(chan []DeathEvent)
+	go func() {
+		var all []DeathEvent
+		for e := range events {
+			all = append(all, e)
+		}
+		received <- all
+	}()
+	summary := runBatch(t, m)
+	unsubscribe()
+	deaths := <-received
+
+	causes := map[string]int{}
+	for _, e := range deaths {
+		causes[e.Species+" "+e.Cause]++
+		if (e.Cause == CauseEaten) != (e.Predator != "") {
+			t.Errorf("%s %s: %s by %q", e.Species, e.UUID, e.Cause, e.Predator)
+		}
+	}
+	if causes["cpPrey eaten"] != summary.CpPreyEaten || causes["cpPrey aged"]+summary.CpPreyEaten != summary.CpPreyDeaths {
+		t.Errorf("deaths %v, want %d eaten of %d CP Prey deaths", causes, summary.CpPreyEaten, summary.CpPreyDeaths)
+	}
+	if summary.CpPreyEaten == 0 || causes["cpPrey aged"] == 0 {
+		t.Errorf("expected CP Prey to be both eaten and die of old age: %v", causes)
+	}
+	if summary.CpPreyCreated-summary.CpPreyDeaths != summary.CpPreyPopulation {
+		t.Errorf("%d CP Prey created, %d died, but %d alive", summary.CpPreyCreated, summary.CpPreyDeaths, summary.CpPreyPopulation)
+	}
+	for _, s := range summary.Series {
+		if s.CpPreyCreated-s.CpPreyDeaths != s.CpPreyPopulation {
+			t.Errorf("turn %d: %d CP Prey created, %d died, but %d summarised", s.Turn, s.CpPreyCreated, s.CpPreyDeaths, s.CpPreyPopulation)
+		}
+	}
+	if rows := readLog(t, m, deathsFile); len(rows)-1 != len(deaths) {
+		t.Errorf("%s: %d rows for %d deaths", deathsFile, len(rows)-1, len(deaths))
+	}
+}
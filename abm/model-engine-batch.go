@@ -0,0 +1,167 @@
+package abm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+// Possible outcomes of a headless batch run.
+const (
+	BatchComplete        = "complete"        // ran for the full FixedDuration
+	BatchCpPreyExtinct   = "cp-prey-extinct" // CP Prey population reached zero
+	BatchPredatorExtinct = "vp-extinct"      // Visual Predator population reached zero
+	batchSummaryFile     = "batch_summary.json"
+)
+
+// BatchSummary is the final report of a headless batch run.
+type BatchSummary struct {
+	SessionIdentifier string        `json:"session-identifier"`
+	Outcome           string        `json:"outcome"`
+	Turns             int           `json:"turns"`
+	CpPreyPopulation  int           `json:"cp-prey-pop"`
+	VpPopulation      int           `json:"vp-pop"`
+	CpPreyCreated     int           `json:"cp-prey-created"`
+	CpPreyEaten       int           `json:"cp-prey-eaten"`
+	CpPreyDeaths      int           `json:"cp-prey-deaths"`
+	VpCreated         int           `json:"vp-created"`
+	VpDeaths          int           `json:"vp-deaths"`
+	Seed              int64         `json:"rng-seed"`
+	LogPath           string        `json:"log-path"`
+	Started           time.Time     `json:"started"`
+	Finished          time.Time     `json:"finished"`
+	Final             TurnSummary   `json:"final"`                 // the populations and their traits when the run ended
+	Apostatic         *float64      `json:"apostatic-coefficient"` // mean coefficient of apostatic selection over the run's windows (see stats.ApostaticWindow), null when no window defined one
+	MorphCounts       []int         `json:"cp-prey-morph-counts"`  // of the final CP Prey population in each morph class of MorphBins (see stats.Morphs)
+	Series            []TurnSummary `json:"-"`                     // every LogFreq-th turn, when the Model's CollectSeries is set
+}
+
+// RunBatch runs the model to completion without any client attached:
+// it blocks until FixedDuration is reached (when LimitDuration is set)
+// or one of the agent populations becomes extinct, then writes a
+// BatchSummary into the log directory. Conditions which would never end
+// (see ValidateHeadless) are rejected. Visualisation is always disabled.
+// A Model restored from a checkpoint continues from where it left off.
+// Errors during the run, e.g. writing a log or checkpoint, don't stop it,
+// but the first of them is returned along with the summary.
+func (m *Model) RunBatch() (BatchSummary, error) {
+	summary := BatchSummary{}
+	if m.running {
+		return summary, errors.New("Model: RunBatch() failed: model already running")
+	}
+	if err := m.ConditionParams.ValidateHeadless(); err != nil {
+		return summary, err
+	}
+	if m.resumed {
+		m.Environment = m.ConditionParams.Environment //	substrate included.
+	} else if err := m.setupEnvironment(); err != nil {
+		return summary, err
+	}
+	m.running = true
+	m.Visualise = false // nobody is listening on the render channel.
+	m.setLogPath()
+
+	if !m.resumed {
+		m.seedRNG()
+	}
+
+	summary.SessionIdentifier = m.SessionIdentifier
+	summary.Seed = m.seed
+	summary.LogPath = m.LogPath
+	summary.Started = time.Now()
+
+	errsDone := make(chan struct{})
+	collected := m.errCollector(errsDone)
+	var logging sync.WaitGroup
+	if m.Logging {
+		logging.Add(1)
+		go func() {
+			defer logging.Done()
+			m.log(m.e)
+		}()
+		time.Sleep(pause) //	give LOG the chance to register for turn broadcasts.
+	}
+
+	if !m.resumed {
+		timestamp := fmt.Sprintf("%s", time.Now())
+		m.popCpPrey = GenerateCpPreyPopulation(m.CpPreyPopulationStart, m.numCpPreyCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
+		m.numCpPreyCreated += m.CpPreyPopulationStart
+		m.popVisualPredator = GenerateVPredatorPopulation(m.VpPopulationStart, m.numVpCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
+		m.numVpCreated += m.VpPopulationStart
+		m.apostatic = stats.NewApostatic(m.MorphBins, m.ApostaticWindow)
+		m.startLineage()
+	}
+
+	for {
+		if m.LimitDuration && m.Turn >= m.FixedDuration {
+			summary.Outcome = BatchComplete
+			break
+		}
+		if len(m.popCpPrey) == 0 {
+			summary.Outcome = BatchCpPreyExtinct
+			break
+		}
+		if len(m.popVisualPredator) == 0 {
+			summary.Outcome = BatchPredatorExtinct
+			break
+		}
+		m.turn(m.e)
+		if err := m.autoCheckpoint(); err != nil {
+			m.e <- err
+		}
+	}
+
+	close(m.halt)
+	logging.Wait()
+	m.running = false
+	if err := m.writeLineage(); err != nil {
+		m.e <- err
+	}
+	close(errsDone)
+	errs := <-collected
+
+	summary.Turns = m.Turn
+	summary.Final = m.summarise()
+	summary.CpPreyPopulation = summary.Final.CpPreyPopulation //	the eaten are yet to be removed.
+	summary.VpPopulation = len(m.popVisualPredator)
+	summary.CpPreyCreated = m.numCpPreyCreated
+	summary.CpPreyEaten = m.numCpPreyEaten
+	summary.CpPreyDeaths = m.numCpPreyDeath
+	summary.VpCreated = m.numVpCreated
+	summary.VpDeaths = m.numVpDeath
+	if c, ok := m.apostatic.MeanCoefficient(); ok {
+		summary.Apostatic = &c
+	}
+	summary.MorphCounts = m.morphCounts()
+	summary.Series = m.series
+	summary.Finished = time.Now()
+
+	close(m.Quit)
+	if err := summary.write(m.LogPath); err != nil {
+		return summary, err
+	}
+	if len(errs) > 0 {
+		return summary, fmt.Errorf("Model: RunBatch(): %d errors during the run, the first: %w", len(errs), errs[0])
+	}
+	return summary, nil
+}
+
+// write saves the summary as JSON into dir.
+func (s BatchSummary) write(dir string) error {
+	msg, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, batchSummaryFile), msg, 0666)
+}
@@ -0,0 +1,242 @@
+package abm
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abm-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	m.LogAgentsCSV = true
+	m.ApostaticWindow = 5
+	m.OutputDir = dir
+	m.CollectSeries = true
+	summary, err := m.RunBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Outcome == BatchComplete && summary.Turns != tFixedDuration {
+		t.Errorf("completed after %d turns, want %d", summary.Turns, tFixedDuration)
+	}
+	if summary.LogPath != dir {
+		t.Errorf("LogPath = %q, want %q", summary.LogPath, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, batchSummaryFile)); err != nil {
+		t.Errorf("batch summary not written: %v", err)
+	}
+	records, _ := filepath.Glob(filepath.Join(dir, "*_cpPrey_pop_record.dat"))
+	if len(records) == 0 {
+		t.Errorf("no turn logs written")
+	}
+	manifests, _ := filepath.Glob(filepath.Join(dir, "*_manifest.json"))
+	if len(manifests) != len(records) {
+		t.Fatalf("%d turn manifests written for %d turn logs", len(manifests), len(records))
+	}
+	summaries := readCSV(t, filepath.Join(dir, summaryFile))
+	if len(summaries) != len(manifests)+1 || len(summaries[0]) != len(summaryHeader) {
+		t.Errorf("%s: %d rows for %d logged turns", summaryFile, len(summaries)-1, len(manifests))
+	}
+	if len(summary.Series) != len(summaries)-1 {
+		t.Errorf("%d turns in the series, %d in %s", len(summary.Series), len(summaries)-1, summaryFile)
+	}
+	agents := readCSV(t, filepath.Join(dir, agentsFile))
+	numRecords := 0
+	for _, file := range manifests {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest turnManifest
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(file) != fmt.Sprintf("%08d_manifest.json", manifest.Turn) {
+			t.Errorf("%s: manifest for turn %d", file, manifest.Turn)
+		}
+		for _, record := range []string{manifest.CpPrey, manifest.Vp} {
+			if _, err := os.Stat(filepath.Join(dir, record)); err != nil {
+				t.Errorf("turn %d: record not written: %v", manifest.Turn, err)
+			}
+		}
+		numRecords += manifest.NumCpPrey + manifest.NumVp
+	}
+	if len(agents) != numRecords+1 {
+		t.Errorf("%s: %d rows for %d agent records", agentsFile, len(agents)-1, numRecords)
+	}
+	windows := map[string]bool{}
+	for _, row := range readCSV(t, filepath.Join(dir, apostaticFile))[1:] {
+		windows[row[0]+"-"+row[1]] = true
+	}
+	if want := summary.Turns / m.ApostaticWindow; len(windows) != want {
+		t.Errorf("%s: %d windows over %d turns, want %d", apostaticFile, len(windows), summary.Turns, want)
+	}
+}
+
+func TestRunBatchErrors(t *testing.T) {
+	m := testModel(t, 6)
+	m.CheckpointFreq = 2
+	//	a directory in the way of the checkpoint file.
+	if err := os.MkdirAll(filepath.Join(m.OutputDir, checkpointFile, "x"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	summary, err := m.RunBatch()
+	if err == nil {
+		t.Fatal("RunBatch() == nil, failing to write every checkpoint")
+	}
+	if summary.Turns != 6 {
+		t.Errorf("stopped after %d turns, want 6", summary.Turns)
+	}
+	if _, err := os.Stat(filepath.Join(m.OutputDir, batchSummaryFile)); err != nil {
+		t.Errorf("batch summary not written: %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("run took %v, held up by its errors", d)
+	}
+}
+
+/*
+testModel gives a Model of the TestConditionParams running for duration
+turns, logging into a temporary directory (its OutputDir) removed when the
+test ends. Tests set whatever else they need before calling runBatch.
+*/
+func testModel(t *testing.T, duration int) *Model {
+	dir, err := ioutil.TempDir("", "abm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	m.FixedDuration = duration
+	m.OutputDir = dir
+	return m
+}
+
+// runBatch runs m to completion, failing the test on error.
+func runBatch(t *testing.T, m *Model) BatchSummary {
+	summary, err := m.RunBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+// readLog reads the CSV file name from the log directory of m.
+func readLog(t *testing.T, m *Model, name string) [][]string {
+	return readCSV(t, filepath.Join(m.LogPath, name))
+}
+
+// readInterventions reads the interventions logged by m, in order.
+func readInterventions(t *testing.T, m *Model) []Intervention {
+	f, err := os.Open(filepath.Join(m.LogPath, interventionsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var interventions []Intervention
+	for lines := bufio.NewScanner(f); lines.Scan(); {
+		var u Intervention
+		if err := json.Unmarshal(lines.Bytes(), &u); err != nil {
+			t.Fatal(err)
+		}
+		interventions = append(interventions, u)
+	}
+	return interventions
+}
+
+func readCSV(t *testing.T, filename string) [][]string {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestRunBatchLogFreq(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abm-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	m.LogFreq = 3
+	m.OutputDir = dir
+	summary, err := m.RunBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, _ := filepath.Glob(filepath.Join(dir, "*_manifest.json"))
+	if want := (summary.Turns + m.LogFreq - 1) / m.LogFreq; len(manifests) != want {
+		t.Errorf("%d turns logged in %d turns, want %d", len(manifests), summary.Turns, want)
+	}
+	for turn := 0; turn < summary.Turns; turn++ {
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%08d_manifest.json", turn)))
+		if logged := err == nil; logged != (turn%m.LogFreq == 0) {
+			t.Errorf("turn %d: logged = %v", turn, logged)
+		}
+	}
+}
+
+func TestRunBatchReproducible(t *testing.T) {
+	run := func() *Model {
+		m := NewModel()
+		m.ConditionParams = TestConditionParams
+		m.Logging = false
+		m.RNGSeedVal = 42
+		m.OutputDir, _ = ioutil.TempDir("", "abm-batch")
+		defer os.RemoveAll(m.OutputDir)
+		if _, err := m.RunBatch(); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	a, b := run(), run()
+	if len(a.popCpPrey) != len(b.popCpPrey) || len(a.popVisualPredator) != len(b.popVisualPredator) {
+		t.Fatalf("population sizes differ: %d/%d vs %d/%d", len(a.popCpPrey), len(a.popVisualPredator), len(b.popCpPrey), len(b.popVisualPredator))
+	}
+	for i := range a.popCpPrey {
+		p, q := a.popCpPrey[i], b.popCpPrey[i]
+		if p.uuid != q.uuid || !p.pos.Equal(q.pos) || p.colouration != q.colouration {
+			t.Fatalf("cpPrey %d differs between runs with the same seed:\n%v\n%v", i, p.String(), q.String())
+		}
+	}
+	for i := range a.popVisualPredator {
+		p, q := a.popVisualPredator[i], b.popVisualPredator[i]
+		if p.uuid != q.uuid || !p.pos.Equal(q.pos) || p.τ != q.τ {
+			t.Fatalf("vp %d differs between runs with the same seed:\n%v\n%v", i, p.String(), q.String())
+		}
+	}
+}
+
+func TestRunBatchUnbounded(t *testing.T) {
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	m.LimitDuration = false
+	_, err := m.RunBatch()
+	invalid, ok := err.(ValidationError)
+	if !ok || len(invalid) != 1 || invalid[0].Key != "abm-limit-duration" {
+		t.Errorf("RunBatch() without a duration == %v, want abm-limit-duration rejected", invalid)
+	}
+}
@@ -19,3 +19,28 @@ func (m *Model) ErrPrinter() {
 		}
 	}
 }
+
+/*
+errCollector prints the errors of a headless run as ErrPrinter does, but
+without pausing, and keeps them: once done is closed (after every sender has
+finished), they are given on the returned channel, in the order received.
+*/
+func (m *Model) errCollector(done <-chan struct{}) <-chan []error {
+	collected := make(chan []error, 1)
+	go func() {
+		var errs []error
+		for {
+			select {
+			case <-done:
+				collected <- errs
+				return
+			case err := <-m.e:
+				if err != nil {
+					log.Println(err)
+					errs = append(errs, err)
+				}
+			}
+		}
+	}()
+	return collected
+}
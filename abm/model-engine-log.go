@@ -9,13 +9,11 @@ import (
   "os"
   "path"
   "path/filepath"
-  "time"
+  "sync"
 )
 
 // Data Logging process local to the model instance.
 func (m *Model) log(ec chan<- error) {
-  time.Sleep(pause)
-
   signature := "LOG_" + m.SessionIdentifier
   turnEnd, clash := m.turnSync.Register(signature)
   if clash {
@@ -24,54 +22,170 @@ func (m *Model) log(ec chan<- error) {
     return
   }
 
-  defer func() {
-    m.turnSync.Deregister(signature)
-    // Need to wipe the agent records too? -yes, probably... but should be in Stop()?
-  }()
+  defer m.turnSync.Deregister(signature)
 
-  if m.UseCustomLogPath {
-    m.LogPath = path.Join(os.Getenv("HOME")+os.Getenv("HOMEPATH"), m.CustomLogPath, abmlogPath, m.SessionIdentifier, m.timestamp)
+  var writes sync.WaitGroup // outstanding log file writes
+
+  // the CSV time series are written in turn order, as each turn ends.
+  summaries, err := openCSVSeries(m.LogPath, summaryFile, summaryHeader)
+  if err != nil {
+    ec <- err
   } else {
-    m.LogPath = path.Join(os.Getenv("HOME")+os.Getenv("HOMEPATH"), abmlogPath, m.SessionIdentifier, m.timestamp)
+    defer summaries.Close()
+  }
+  var agents *csvSeries
+  if m.LogAgentsCSV {
+    agents, err = openCSVSeries(m.LogPath, agentsFile, agentsHeader)
+    if err != nil {
+      ec <- err
+    } else {
+      defer agents.Close()
+    }
+  }
+
+  var alleles *csvSeries
+  if m.CpPreyGenetics != nil {
+    alleles, err = openCSVSeries(m.LogPath, allelesFile, allelesHeader)
+    if err != nil {
+      ec <- err
+    } else {
+      defer alleles.Close()
+    }
+  }
+
+  apostatic, err := openCSVSeries(m.LogPath, apostaticFile, apostaticHeader)
+  if err != nil {
+    ec <- err
+  } else {
+    defer apostatic.Close()
+  }
+  deaths, err := openCSVSeries(m.LogPath, deathsFile, deathsHeader)
+  if err != nil {
+    ec <- err
+  } else {
+    defer deaths.Close()
   }
 
   for {
     select {
     case <-m.halt: // RUN halted as channel closed – therefore we end LOG.
-      // clean up?
+      writes.Wait() // don't abandon the files of the final turn
       return
     case <-turnEnd:
-      func() {
-        cpr := m.cpPreyRecordCopy()
-        vpr := m.vpRecordCopy()
-        go func(record map[string]ColourPolymorphicPrey, errCh chan<- error) {
-          // write map as json to file.
-          tc := fmt.Sprintf("%08v", m.Turn)
-          dir := m.LogPath
-          path := dir + string(filepath.Separator) + tc + "_cpPrey_pop_record.dat"
-
-          msg, err := json.MarshalIndent(record, "", "  ")
-          if err != nil {
-            log.Printf("model: logging: json.Marshal failed, error: %v\n source: %s : %s : %v\n", err, m.SessionIdentifier, m.timestamp, m.Turn)
-            errCh <- err
-            return
+      // agents are only recorded on turns which are to be logged (see loggedTurn).
+      for _, record := range m.recordTake() {
+        if summaries != nil {
+          if err := summaries.write(record.summary.row()); err != nil {
+            ec <- err
           }
-
-          err = os.MkdirAll(dir, 0777)
-          if err != nil {
-            errCh <- err
-            return
+        }
+        if agents != nil {
+          if err := agents.write(agentRows(record.turn, record.cpPrey, record.vp)...); err != nil {
+            ec <- err
           }
-          err = ioutil.WriteFile(path, msg, 0777)
-          if err != nil {
+        }
+        if alleles != nil {
+          if err := alleles.write(alleleRows(record.turn, m.CpPreyGenetics, record.alleles)...); err != nil {
+            ec <- err
+          }
+        }
+        writes.Add(1)
+        go func(record turnRecord, errCh chan<- error) {
+          defer writes.Done()
+          if err := m.writeTurnRecords(record.turn, record.cpPrey, record.vp); err != nil {
             errCh <- err
-            return
           }
-        }(cpr, ec)
-        go func(record map[string]VisualPredator, errCh chan<- error) {
-          // write map as json to file.
-        }(vpr, ec)
-      }()
+        }(record, ec)
+      }
+      // every death is logged, whether or not its turn is.
+      if events := m.deathsTake(); deaths != nil && len(events) > 0 {
+        rows := make([][]string, len(events))
+        for i, e := range events {
+          rows[i] = e.row()
+        }
+        if err := deaths.write(rows...); err != nil {
+          ec <- err
+        }
+      }
+      if interventions := m.interventionsTake(); len(interventions) > 0 {
+        vs := make([]interface{}, len(interventions))
+        for i := range interventions {
+          vs[i] = interventions[i]
+        }
+        if err := appendJSONLines(m.LogPath, interventionsFile, vs...); err != nil {
+          ec <- err
+        }
+      }
+      for _, s := range m.statisticsTake() {
+        if apostatic != nil && s.Apostatic != nil {
+          if err := apostatic.write(apostaticRows(*s.Apostatic)...); err != nil {
+            ec <- err
+          }
+        }
+      }
     }
   }
 }
+
+// turnManifest links the record files written for a single turn.
+type turnManifest struct {
+  Session   string `json:"session"`
+  Timestamp string `json:"timestamp"`
+  Turn      int    `json:"turn"`
+  CpPrey    string `json:"cp-prey-record"`
+  Vp        string `json:"vp-record"`
+  NumCpPrey int    `json:"cp-prey-records"`
+  NumVp     int    `json:"vp-records"`
+}
+
+// writeTurnRecords writes the CP Prey and Visual Predator records for turn to
+// the log directory, and then the manifest linking them to the turn: the
+// presence of a manifest means both of its records are complete.
+func (m *Model) writeTurnRecords(turn int, cpr map[string]ColourPolymorphicPrey, vpr map[string]VisualPredator) error {
+  err := os.MkdirAll(m.LogPath, 0777)
+  if err != nil {
+    return err
+  }
+  tc := fmt.Sprintf("%08v", turn)
+  manifest := turnManifest{
+    Session:   m.SessionIdentifier,
+    Timestamp: m.timestamp,
+    Turn:      turn,
+    CpPrey:    tc + "_cpPrey_pop_record.dat",
+    Vp:        tc + "_vp_pop_record.dat",
+    NumCpPrey: len(cpr),
+    NumVp:     len(vpr),
+  }
+  err = m.writeLogFile(manifest.CpPrey, cpr, turn)
+  if err != nil {
+    return err
+  }
+  err = m.writeLogFile(manifest.Vp, vpr, turn)
+  if err != nil {
+    return err
+  }
+  return m.writeLogFile(tc+"_manifest.json", manifest, turn)
+}
+
+// writeLogFile writes v as indented JSON to the file name in the log directory.
+func (m *Model) writeLogFile(name string, v interface{}, turn int) error {
+  msg, err := json.MarshalIndent(v, "", "  ")
+  if err != nil {
+    log.Printf("model: logging: json.Marshal failed, error: %v\n source: %s : %s : %v\n", err, m.SessionIdentifier, m.timestamp, turn)
+    return err
+  }
+  return ioutil.WriteFile(filepath.Join(m.LogPath, name), msg, 0777)
+}
+
+// setLogPath determines the directory the LOG process writes to.
+// An explicit OutputDir (used by headless runs) takes precedence.
+func (m *Model) setLogPath() {
+  switch {
+  case m.OutputDir != "":
+    m.LogPath = m.OutputDir
+  case m.UseCustomLogPath:
+    m.LogPath = path.Join(os.Getenv("HOME")+os.Getenv("HOMEPATH"), m.CustomLogPath, abmlogPath, m.SessionIdentifier, m.timestamp)
+  default:
+    m.LogPath = path.Join(os.Getenv("HOME")+os.Getenv("HOMEPATH"), abmlogPath, m.SessionIdentifier, m.timestamp)
+  }
+}
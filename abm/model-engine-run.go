@@ -1,9 +1,12 @@
 package abm
 
 import (
+  "math"
   "sync"
   "time"
 
+  "github.com/benjamin-rood/abm-cp/calc"
+  "github.com/benjamin-rood/abm-cp/geometry"
   "github.com/benjamin-rood/gobr"
 )
 
@@ -13,24 +16,29 @@ func (m *Model) run(ec chan<- error) {
     select {
     case <-m.halt:
       gobr.WaitForSignalOnce(signature, m.turnSync) // block while waiting for turn to end.
+      ec <- m.writeLineage()
       time.Sleep(pause)
       return
     case <-m.Quit:
       gobr.WaitForSignalOnce(signature, m.turnSync) // block while waiting for turn to end.
+      ec <- m.writeLineage()
       ec <- m.Stop()
       time.Sleep(pause)
       return
     default:
       if m.LimitDuration && m.Turn >= m.FixedDuration {
+        ec <- m.writeLineage()
         ec <- m.Stop()
         return
       }
       if (len(m.popCpPrey) == 0) || (len(m.popVisualPredator) == 0) {
+        ec <- m.writeLineage()
         ec <- m.Stop()
         return
       }
       //	PROCEED WITH TURN
       m.turn(ec)
+      ec <- m.autoCheckpoint()
     }
   }
 }
@@ -38,66 +46,181 @@ func (m *Model) run(ec chan<- error) {
 func (m *Model) cpPreyPhase(errCh chan<- error) []ColourPolymorphicPrey {
   var mutex sync.Mutex
   var Wg sync.WaitGroup
-  var agentsUpdate []ColourPolymorphicPrey
+  results := make([][]ColourPolymorphicPrey, len(m.popCpPrey)) // indexed by agent, so the new population order is deterministic
+  logging, drawing := m.loggedTurn(m.Turn), m.drawnTurn(m.Turn)
+  var index *geometry.Grid //	for the CP Prey looking for a sire, only with genetics.
+  if m.CpPreyGenetics != nil {
+    index = m.cpPreyMateIndex()
+  }
 
   for i := range m.popCpPrey {
     Wg.Add(1)
-    go func(agent ColourPolymorphicPrey) {
+    // each agent draws from its own stream, seeded in order from the model's
+    // stream, so the draws don't depend on goroutine scheduling.
+    rng := calc.NewRNG(m.rng.Int63())
+    go func(i int, agent ColourPolymorphicPrey) {
       defer func() {
         Wg.Done()
-        if m.Logging {
+        if logging {
           // do this copying to the record in a goroutine once proven stable and safe!
           errCh <- m.cpPreyRecordAssignValue(agent.UUID(), agent)
         }
       }()
-      result := agent.Action(m.ConditionParams, len(m.popCpPrey))
-      if m.Visualise {
+      result := agent.Action(m.ConditionParams, m.Turn, m.popCpPrey, i, index, rng)
+      if drawing {
         m.render <- agent.GetDrawInfo()
       }
+      results[i] = result
       mutex.Lock()
-      m.numCpPreyCreated += len(result) - 1
-      agentsUpdate = append(agentsUpdate, result...)
-      mutex.Unlock()
       m.Action++
-    }(m.popCpPrey[i])
+      mutex.Unlock()
+    }(i, m.popCpPrey[i])
   }
   Wg.Wait()
+  var agentsUpdate []ColourPolymorphicPrey
+  for i, result := range results {
+    survived := false
+    for j := range result {
+      if result[j].uuid == m.popCpPrey[i].uuid {
+        survived = true
+        continue
+      }
+      // progeny are numbered in population order, independent of goroutine scheduling.
+      result[j].description.AgentNum = m.numCpPreyCreated
+      m.numCpPreyCreated++
+      m.lineage.cpPreyBorn(result[j], m.Turn)
+    }
+    if !survived && !m.popCpPrey[i].eaten() { //	the deaths of those eaten are accounted for when caught.
+      m.died(cpPreyDeath(m.popCpPrey[i], m.Turn, CauseAged))
+    }
+    agentsUpdate = append(agentsUpdate, result...)
+  }
   return agentsUpdate
 }
 
 func (m *Model) visualPredatorPhase(errCh chan<- error) []VisualPredator {
   var mutex sync.Mutex
   var agentsUpdate []VisualPredator
+  index := m.spatialIndex() //	positions don't change until the phase is complete.
+  logging, drawing := m.loggedTurn(m.Turn), m.drawnTurn(m.Turn)
   for i := range m.popVisualPredator {
     func(agent VisualPredator) {
       defer func() {
-        if m.Logging {
+        if logging {
           // do this copying to the record in a seperate goroutine once proven stable and safe!
           errCh <- m.vpRecordAssignValue(agent.UUID(), agent)
         }
       }()
-      result := agent.Action(errCh, m.ConditionParams, m.numVpCreated, m.Turn, m.popCpPrey, m.popVisualPredator, i)
-      if m.Visualise {
+      result := agent.Action(errCh, m.ConditionParams, m.numVpCreated, m.Turn, m.popCpPrey, m.popVisualPredator, i, index, m.rng)
+      if drawing {
         m.render <- agent.GetDrawInfo()
       }
       mutex.Lock()
-      m.numVpCreated += len(result) - 1
+      survived := false
+      for _, vp := range result {
+        if vp.uuid == agent.uuid {
+          survived = true
+          continue
+        }
+        m.numVpCreated++
+        m.lineage.vpBorn(vp, m.Turn)
+      }
+      if !survived {
+        cause := CauseStarved
+        if m.VpAgeing && agent.lifespan <= 1 { //	Age took its last turn.
+          cause = CauseAged
+        }
+        m.died(vpDeath(agent, m.Turn, cause))
+      }
       agentsUpdate = append(agentsUpdate, result...)
       mutex.Unlock()
       m.Action++
     }(m.popVisualPredator[i])
   }
+  for i := range m.popCpPrey {
+    if m.popCpPrey[i].eaten() { //	prey eaten in earlier turns have already been removed.
+      m.died(cpPreyDeath(m.popCpPrey[i], m.Turn, CauseEaten))
+    }
+  }
   return agentsUpdate
 }
 
+// everyTurns reports whether turn falls on a T:1 frequency of freq turns,
+// where a frequency of 0 or 1 means every turn.
+func everyTurns(turn int, freq int) bool {
+  return freq <= 1 || turn%freq == 0
+}
+
+// loggedTurn reports whether agents are recorded for the LOG process on turn (every LogFreq turns).
+func (m *Model) loggedTurn(turn int) bool {
+  return m.Logging && everyTurns(turn, m.LogFreq)
+}
+
+// seriesTurn reports whether the TurnSummary of turn is kept for the BatchSummary (every LogFreq turns).
+func (m *Model) seriesTurn(turn int) bool {
+  return m.CollectSeries && everyTurns(turn, m.LogFreq)
+}
+
+// drawnTurn reports whether agents send draw instructions to the VIS process on turn (every VisFreq turns).
+func (m *Model) drawnTurn(turn int) bool {
+  return m.Visualise && everyTurns(turn, m.VisFreq)
+}
+
+// spatialIndex builds the grid indexes over the current agent positions.
+// The prey grid uses sectors the size of the predator search range, so that a
+// PreySearch only needs to look into the handful of sectors around it; the
+// predator grid averages roughly one agent per sector for MateSearch.
+func (m *Model) spatialIndex() *SpatialIndex {
+  preyPos := make([]geometry.Vector, len(m.popCpPrey))
+  for i := range m.popCpPrey {
+    preyPos[i] = m.popCpPrey[i].pos
+  }
+  vpPos := make([]geometry.Vector, len(m.popVisualPredator))
+  for i := range m.popVisualPredator {
+    vpPos[i] = m.popVisualPredator[i].pos
+  }
+  d := 0.0
+  for _, b := range m.Bounds {
+    d = math.Max(d, b)
+  }
+  vpCell := (2 * d) / math.Ceil(math.Sqrt(float64(len(vpPos))+1))
+  return &SpatialIndex{
+    CpPrey: m.Environment.Grid(m.VpVsr, preyPos),
+    Vp:     m.Environment.Grid(vpCell, vpPos),
+  }
+}
+
+// cpPreyMateIndex builds a grid over the current CP Prey positions with
+// sectors the size of their search range, for MateSearch.
+func (m *Model) cpPreyMateIndex() *geometry.Grid {
+  pos := make([]geometry.Vector, len(m.popCpPrey))
+  for i := range m.popCpPrey {
+    pos[i] = m.popCpPrey[i].pos
+  }
+  return m.Environment.Grid(m.CpPreySr, pos)
+}
+
 func (m *Model) turn(errCh chan<- error) {
+  m.applyUpdates(errCh)
+  m.applyProtocol(errCh)
   m.popCpPrey = m.cpPreyPhase(errCh) // update the population based on the results from all Prey agents rule-based behaviour in the phase.
   m.Phase++
   m.Action = 0                                       // reset at phase end
   m.popVisualPredator = m.visualPredatorPhase(errCh) // update the population based on the results from all Predators rule-based behaviour in the phase.
+  m.observeSelection()                               // before the prey eaten in the phase are removed.
   m.Phase++
   m.Action = 0                   // reset at phase end
   m.Phase = 0                    // reset at Turn end
+  if m.loggedTurn(m.Turn) || m.seriesTurn(m.Turn) {
+    summary := m.summarise()
+    if m.loggedTurn(m.Turn) {
+      m.recordTurnEnd(m.Turn, summary)
+    }
+    if m.seriesTurn(m.Turn) {
+      m.series = append(m.series, summary)
+    }
+  }
+  m.deathsPublish()
   m.turnSync.Broadcast(blocking) // using blocking version to ensure synchronisation with the other processes in the active Engine Set.
   m.Turn++
 }
@@ -4,6 +4,7 @@ import (
   "errors"
   "fmt"
 
+  "github.com/benjamin-rood/abm-cp/colour"
   "github.com/benjamin-rood/abm-cp/render"
   "github.com/benjamin-rood/gobr"
 )
@@ -19,6 +20,12 @@ func (m *Model) vis(ec chan<- error) {
   }
   defer m.turnSync.Deregister(signature)
 
+  background, err := m.background()
+  if err != nil {
+    ec <- err
+  }
+  m.Om <- gobr.OutMsg{Type: "background", Data: background}
+
   msg := gobr.OutMsg{Type: "render", Data: nil}
   bg := m.BG.To256()
   dl := render.DrawList{
@@ -41,6 +48,17 @@ func (m *Model) vis(ec chan<- error) {
         dl.VP = append(dl.VP, job)
       }
     case <-turnEnd:
+      if e := m.environmentLatest(); e != nil {
+        bg = e.bg
+        dl.BG = bg
+        m.Om <- gobr.OutMsg{Type: "background", Data: e.background}
+      }
+      if s := m.statisticsLatest(); s != nil {
+        m.Om <- gobr.OutMsg{Type: "statistics", Data: *s}
+      }
+      if len(dl.CPP) == 0 && len(dl.VP) == 0 {
+        continue //	agents only send draw instructions on turns which are to be drawn (see drawnTurn).
+      }
       dl.CpPreyPop = fmt.Sprintf("cpPrey %d", len(m.popCpPrey))
       dl.VpPop = fmt.Sprintf("vp  %d", len(m.popVisualPredator))
       dl.TurnCount = fmt.Sprintf("%08d", m.Turn)
@@ -64,3 +82,41 @@ func (m *Model) vis(ec chan<- error) {
     }
   }
 }
+
+// background gives the (downsampled) image of the environment substrate for
+// the front-end to draw beneath the agents. A uniform substrate gives an
+// empty image, meaning only the DrawList BG colour is drawn.
+func (m *Model) background() (render.Background, error) {
+  if m.Substrate == nil {
+    return render.Background{}, nil
+  }
+  return render.NewBackground(m.Substrate.Image(), render.BackgroundMaxSize)
+}
+
+// environmentView is an environment changed while the model runs, as the web client draws it.
+type environmentView struct {
+  bg         colour.RGB256
+  background render.Background
+}
+
+// environmentPublish hands the current environment to the VIS process, to
+// be sent to the web client at the end of the turn.
+func (m *Model) environmentPublish() error {
+  background, err := m.background()
+  if err != nil {
+    return err
+  }
+  defer m.rStatsMu.Unlock()
+  m.rStatsMu.Lock()
+  m.envVis = &environmentView{bg: m.BG.To256(), background: background}
+  return nil
+}
+
+// environmentLatest returns the environment not yet sent to the web client, if changed.
+func (m *Model) environmentLatest() *environmentView {
+  defer m.rStatsMu.Unlock()
+  m.rStatsMu.Lock()
+  e := m.envVis
+  m.envVis = nil
+  return e
+}
@@ -7,6 +7,8 @@ import (
   "math/rand"
   "time"
 
+  "github.com/benjamin-rood/abm-cp/calc"
+  "github.com/benjamin-rood/abm-cp/stats"
   "github.com/benjamin-rood/gobr"
   "github.com/davecgh/go-spew/spew"
 )
@@ -35,17 +37,46 @@ func (m *Model) Controller() {
           } //	will block until receiving turn broadcast once.
           m.e <- m.Stop()
         }
-        err := json.Unmarshal(msg.Data, &m.ConditionParams)
+        conditions, err := m.ConditionParams.patched(ProtocolAction{Action: ActionConditions, Patch: msg.Data})
         if err != nil {
           errString := fmt.Sprintf("model Controller(): error: json.Unmarshal: %s", err)
           m.e <- errors.New(errString)
           break
         }
+        err = conditions.Validate()
+        if err != nil { //	the client is told which fields to correct.
+          m.Om <- gobr.OutMsg{Type: "invalid-conditions", Data: err}
+          m.e <- err
+          break
+        }
+        m.ConditionParams = conditions
         m.Timeframe.Reset()
         spew.Dump(m.ConditionParams)
         m.e <- m.Start()
       case "pause":
         m.e <- m.Suspend()
+      case "resume": //	restore a previously saved session by name and continue it
+        var session string
+        err := json.Unmarshal(msg.Data, &session)
+        if err != nil {
+          m.e <- fmt.Errorf("model Controller(): error: json.Unmarshal: %s", err)
+          break
+        }
+        if m.running {
+          m.e <- m.Suspend()
+        }
+        err = m.LoadSession(session)
+        if err != nil {
+          m.e <- err
+          break
+        }
+        m.e <- m.Resume()
+      case "update": //	change some of the conditions of the running model, from the next turn
+        err := m.Update(msg.Data)
+        if invalid, ok := err.(ValidationError); ok { //	as for "conditions".
+          m.Om <- gobr.OutMsg{Type: "invalid-conditions", Data: invalid}
+        }
+        m.e <- err
       }
     case <-m.Quit:
       gobr.WaitForSignalOnce(signature, m.turnSync) //	will block until receiving turn broadcast once.
@@ -59,17 +90,26 @@ func (m *Model) Start() error {
   if m.running {
     return errors.New("Model: Start() failed: model already running")
   }
-  m.running = true
-  if m.RNGRandomSeed {
-    rand.Seed(time.Now().UnixNano())
-  } else {
-    rand.Seed(m.RNGSeedVal)
+  err := m.ConditionParams.Validate()
+  if err != nil {
+    return err
   }
+  err = m.setupEnvironment()
+  if err != nil {
+    return err
+  }
+  m.running = true
+  m.resumed = false
+  m.discardUpdates()
+  m.setLogPath()
+  m.seedRNG()
   timestamp := fmt.Sprintf("%s", time.Now())
-  m.popCpPrey = GenerateCpPreyPopulation(m.CpPreyPopulationStart, m.numCpPreyCreated, m.Turn, m.ConditionParams, timestamp)
+  m.popCpPrey = GenerateCpPreyPopulation(m.CpPreyPopulationStart, m.numCpPreyCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
   m.numCpPreyCreated += m.CpPreyPopulationStart
-  m.popVisualPredator = GenerateVPredatorPopulation(m.VpPopulationStart, m.numVpCreated, m.Turn, m.ConditionParams, timestamp)
+  m.popVisualPredator = GenerateVPredatorPopulation(m.VpPopulationStart, m.numVpCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
   m.numVpCreated += m.VpPopulationStart
+  m.apostatic = stats.NewApostatic(m.MorphBins, m.ApostaticWindow)
+  m.startLineage()
   if m.Logging {
     go m.log(m.e)
   }
@@ -122,3 +162,16 @@ func (m *Model) Resume() error {
   }
   return nil
 }
+
+// seedRNG (re)creates the model's own random number stream and returns the
+// seed used, so that a run can always be reproduced from its seed.
+func (m *Model) seedRNG() int64 {
+  seed := m.RNGSeedVal
+  if m.RNGRandomSeed {
+    seed = time.Now().UnixNano()
+  }
+  m.seed = seed
+  m.rngSrc = calc.NewSource(seed)
+  m.rng = rand.New(m.rngSrc)
+  return seed
+}
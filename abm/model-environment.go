@@ -0,0 +1,79 @@
+package abm
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+)
+
+// The Boundary mode of the environment decides what happens to agents
+// reaching its Bounds. When it wraps around (the default), the environment
+// is a torus and all spatial relations between agents are measured as such.
+
+// wraps reports whether the environment is toroidal.
+func (e Environment) wraps() bool {
+	return e.Boundary != geometry.Reflect && e.Boundary != geometry.Wall
+}
+
+// Distance gives the shortest distance between two positions within the environment.
+func (e Environment) Distance(v geometry.Vector, u geometry.Vector) (float64, error) {
+	if e.wraps() {
+		return geometry.TorusDistance(v, u, e.Bounds)
+	}
+	return geometry.VectorDistance(v, u)
+}
+
+// AngleToIntercept gives the turn needed from heading 𝚯 at pos to face
+// along the shortest path to target within the environment.
+func (e Environment) AngleToIntercept(pos geometry.Vector, 𝚯 float64, target geometry.Vector) (float64, error) {
+	if e.wraps() {
+		return geometry.TorusAngleToIntercept(pos, 𝚯, target, e.Bounds)
+	}
+	return geometry.AngleToIntercept(pos, 𝚯, target)
+}
+
+// Grid builds a spatial index of positions within the environment.
+func (e Environment) Grid(cellSize float64, positions []geometry.Vector) *geometry.Grid {
+	if e.wraps() {
+		return geometry.NewTorusGrid(e.Bounds, cellSize, positions)
+	}
+	return geometry.NewGrid(e.Bounds, cellSize, positions)
+}
+
+// Confine brings a position (and heading) reached by moving back within the environment.
+func (e Environment) Confine(pos geometry.Vector, 𝚯 float64) (geometry.Vector, float64) {
+	return geometry.Confine(pos, 𝚯, e.Bounds, e.Boundary)
+}
+
+// RandPosition gives a random position within the environment.
+func (e Environment) RandPosition(rng *rand.Rand) geometry.Vector {
+	return geometry.RandVectorWithin(e.Bounds, e.Boundary, rng)
+}
+
+// Background gives the colour of the substrate at pos.
+func (e Environment) Background(pos geometry.Vector) colour.RGB {
+	if e.Substrate == nil {
+		return e.BG
+	}
+	return e.Substrate.At(pos, e.Bounds)
+}
+
+// FuzzyPosition gives a random position within ε of pos (e.g. for placing
+// offspring near their parent), kept within the environment.
+func (e Environment) FuzzyPosition(pos geometry.Vector, ε float64, rng *rand.Rand) (geometry.Vector, error) {
+	return geometry.FuzzifyVectorWithin(pos, ε, e.Bounds, e.Boundary, rng)
+}
+
+// setupEnvironment creates the substrate for the model conditions, which
+// then become the working model environment.
+func (m *Model) setupEnvironment() error {
+	substrate, err := NewSubstrate(m.ConditionParams.SubstrateSpec)
+	if err != nil {
+		return errors.New("Model: environment setup failed: " + err.Error())
+	}
+	m.ConditionParams.Substrate = substrate
+	m.Environment = m.ConditionParams.Environment
+	return nil
+}
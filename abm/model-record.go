@@ -33,3 +33,43 @@ func (m *Model) vpRecordAssignValue(key string, value VisualPredator) error {
 	m.recordVP[key] = value
 	return nil
 }
+
+// turnRecord is the complete record of a logged turn, handed from the RUN process to LOG.
+type turnRecord struct {
+	turn    int
+	cpPrey  map[string]ColourPolymorphicPrey
+	vp      map[string]VisualPredator
+	summary TurnSummary
+	alleles [][]int //	copies of each allele of each locus among the living CP Prey, with CpPreyGenetics
+}
+
+// recordTurnEnd queues the records of the turn just completed for the LOG
+// process, along with its summary and the allele counts of the CP Prey alive
+// at its end, and starts new (empty) records.
+func (m *Model) recordTurnEnd(turn int, summary TurnSummary) {
+	var alleles [][]int
+	if m.CpPreyGenetics != nil {
+		living := m.livingCpPrey()
+		genotypes := make([]Genotype, len(living))
+		for i, c := range living {
+			genotypes[i] = c.genotype
+		}
+		alleles = m.CpPreyGenetics.alleleCounts(genotypes)
+	}
+	defer m.rcpPreyRW.Unlock()
+	m.rcpPreyRW.Lock()
+	defer m.rvpRW.Unlock()
+	m.rvpRW.Lock()
+	m.recordQueue = append(m.recordQueue, turnRecord{turn, m.recordCPP, m.recordVP, summary, alleles})
+	m.recordCPP = make(map[string]ColourPolymorphicPrey)
+	m.recordVP = make(map[string]VisualPredator)
+}
+
+// recordTake returns the queued turn records, in turn order, emptying the queue.
+func (m *Model) recordTake() []turnRecord {
+	defer m.rcpPreyRW.Unlock()
+	m.rcpPreyRW.Lock()
+	queue := m.recordQueue
+	m.recordQueue = nil
+	return queue
+}
@@ -0,0 +1,92 @@
+package abm
+
+import (
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+// apostaticFile is the CSV time series of apostatic selection windows written into the log directory.
+const apostaticFile = "apostatic.csv" //	one row per morph per window
+
+/*
+Statistics holds measurements of the model which span more than a single
+turn. It is logged and sent to the web client (as a "statistics" message)
+on the turn it becomes available; fields which were not measured on that
+turn are nil.
+*/
+type Statistics struct {
+	Turn      int                    `json:"turn"`
+	Apostatic *stats.ApostaticWindow `json:"apostatic,omitempty"`
+}
+
+// observeSelection adds the turn just completed to the apostatic selection
+// window: the CP Prey exposed to predators in the turn are those which
+// survived their own phase, some of which have been eaten.
+func (m *Model) observeSelection() {
+	if m.apostatic == nil {
+		m.apostatic = stats.NewApostatic(m.MorphBins, m.ApostaticWindow)
+	}
+	prey := make([]colour.RGB, len(m.popCpPrey))
+	eaten := make([]bool, len(m.popCpPrey))
+	for i := range m.popCpPrey {
+		prey[i] = m.popCpPrey[i].colouration
+		eaten[i] = m.popCpPrey[i].eaten()
+	}
+	w, done := m.apostatic.Observe(m.Turn, prey, eaten)
+	if !done {
+		return
+	}
+	m.statisticsPublish(Statistics{Turn: m.Turn, Apostatic: &w})
+}
+
+// statisticsPublish hands s to the LOG and VIS processes, as active.
+func (m *Model) statisticsPublish(s Statistics) {
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	if m.Logging {
+		m.statsQueue = append(m.statsQueue, s)
+	}
+	if m.Visualise {
+		m.statsVis = &s
+	}
+}
+
+// statisticsTake returns the statistics queued for logging, in turn order, emptying the queue.
+func (m *Model) statisticsTake() []Statistics {
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	queue := m.statsQueue
+	m.statsQueue = nil
+	return queue
+}
+
+// statisticsLatest returns the statistics not yet sent to the web client, if any.
+func (m *Model) statisticsLatest() *Statistics {
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	s := m.statsVis
+	m.statsVis = nil
+	return s
+}
+
+var apostaticHeader = []string{
+	"window_start", "window_end", "morph", "red", "green", "blue",
+	"frequency", "exposure", "eaten", "rate", "selectivity", "coefficient", "defined",
+}
+
+// apostaticRows gives the long-format rows of an apostatic selection window,
+// one per morph present. The window-wide coefficient is repeated on each row.
+func apostaticRows(w stats.ApostaticWindow) [][]string {
+	var rows [][]string
+	for _, s := range w.Morphs {
+		rows = append(rows, []string{
+			strconv.Itoa(w.Start), strconv.Itoa(w.End), strconv.Itoa(s.Morph),
+			ftoa(s.Colour.Red), ftoa(s.Colour.Green), ftoa(s.Colour.Blue),
+			ftoa(s.Frequency), strconv.Itoa(s.Exposure), strconv.Itoa(s.Eaten), ftoa(s.Rate), ftoa(s.Selectivity),
+			ftoa(w.Coefficient), strconv.FormatBool(w.Defined),
+		})
+	}
+	return rows
+}
@@ -1,16 +1,20 @@
 package abm
 
 import (
-	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"path"
 	"sync"
 	"time"
 
+	"github.com/benjamin-rood/abm-cp/calc"
 	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
 	"github.com/benjamin-rood/abm-cp/render"
+	"github.com/benjamin-rood/abm-cp/stats"
 	"github.com/benjamin-rood/gobr"
 )
 
@@ -31,6 +35,23 @@ type Model struct {
 	render chan render.AgentRender // VIS message channel
 
 	turnSync *gobr.SignalHub // synchronisation
+	rng      *rand.Rand      // model-local random number stream, seeded at Start
+	rngSrc   *calc.Source    // source of rng, kept so its state can be checkpointed
+	seed     int64           // seed rng was started from
+	resumed  bool            // populations were restored from a checkpoint rather than generated
+
+	apostatic *stats.Apostatic // selection on CP Prey morphs over the current window
+	lineage   *Lineage         // genealogy of every agent, when LogLineage is set
+
+	turnDeaths []DeathEvent       // deaths of the turn in progress
+	deathSubs  deathSubscriptions // subscribers to DeathEvents
+
+	updates  []Intervention // changes to the conditions, waiting for the next turn
+	updateMu sync.Mutex     // guards updates, and the conditions while they change during a run
+
+	OutputDir     string        // when set, overrides the computed LogPath (e.g. headless batch runs)
+	CollectSeries bool          // when set, RunBatch keeps the TurnSummary of every LogFreq-th turn, logging or not
+	series        []TurnSummary // kept when CollectSeries is set
 
 	Stats  //	embedded global agent population statistics
 	DatBuf //	embedded buffer of last turn agent pop record for LOG
@@ -42,6 +63,14 @@ type AgentPopulations struct {
 	popVisualPredator []VisualPredator        // current predator agent population
 }
 
+// SpatialIndex holds the uniform-grid indexes of agent positions used to
+// narrow down searches. It must be rebuilt whenever positions change, and
+// a nil index (or nil field) falls back to an exhaustive search.
+type SpatialIndex struct {
+	CpPrey *geometry.Grid // positions of popCpPrey
+	Vp     *geometry.Grid // positions of popVisualPredator
+}
+
 /*
 Environment specifies the boundary / dimensions of the working model. They
 extend in both positive and negative directions, oriented at the center. Setting
@@ -50,9 +79,12 @@ most cases, a 2D environment will be sufficient.
 In the future it may include some environmental factors etc.
 */
 type Environment struct {
-	Bounds         []float64  `json:"abm-environment-bounds"` // d value for each axis
-	Dimensionality int        `json:"abm-environment-dimensionality"`
-	BG             colour.RGB `json:"abm-environment-background"`
+	Bounds         []float64     `json:"abm-environment-bounds"`   // d value for each axis
+	Boundary       string        `json:"abm-environment-boundary"` // boundary mode: geometry.Wrap (default), geometry.Reflect or geometry.Wall
+	Dimensionality int           `json:"abm-environment-dimensionality"`
+	BG             colour.RGB    `json:"abm-environment-background"`
+	SubstrateSpec  SubstrateSpec `json:"abm-environment-substrate"`               // how the substrate is created at Start
+	Substrate      *Substrate    `json:"abm-environment-substrate-map,omitempty"` // background colour map; nil for a uniform BG
 }
 
 // ConditionParams groups the CONSTANT LOCAL model conditions and constraints into a single set
@@ -71,6 +103,8 @@ type ConditionParams struct {
 	CpPreyReproductionChance float64                  `json:"abm-cp-prey-reproduction-chance"`     // chance of CP Prey  copulation success.
 	CpPreySpawnSize          int                      `json:"abm-cp-prey-spawn-size"`              // possible number of progeny = [1, max]
 	CpPreyMutationFactor     float64                  `json:"abm-cp-prey-mf"`                      // mutation factor
+	CpPreyUV                 bool                     `json:"abm-cp-prey-uv"`                      // prey colouration includes a random, heritable UV reflectance
+	CpPreyGenetics           *Genetics                `json:"abm-cp-prey-genetics,omitempty"`      // diploid multi-locus genotype deciding CP Prey colouration. Default = nil (colouration inherited clonally, mutated by abm-cp-prey-mf)
 	VpPopulationStart        int                      `json:"abm-vp-pop-start"`                    // starting Predator agent population size
 	VpPopulationCap          int                      `json:"abm-vp-pop-cap"`                      //
 	VpAgeing                 bool                     `json:"abm-vp-ageing"`                       //
@@ -94,12 +128,23 @@ type ConditionParams struct {
 	VpBaseAttackGain         float64                  `json:"abm-vp-baseline-attack-gain"`         //
 	VpCaf                    float64                  `json:"abm-vp-col-adaptation-factor"`        //
 	VpStarvation             bool                     `json:"abm-vp-starvation"`                   //
+	VpCrypsisWeight          float64                  `json:"abm-vp-crypsis-weight"`               // [0,1] how much low prey–substrate contrast hinders detection. Default = 0 (not at all)
+	VpColourMetric           string                   `json:"abm-vp-colour-metric"`                // how predators perceive colour difference: colour.MetricRGB (default), colour.MetricCIE76 or colour.MetricCIEDE2000
+	VpVisualSystems          []string                 `json:"abm-vp-visual-systems"`               // visual systems (by name) assigned to predators in turn, each seeing colour differences through it. Default = none (VpColourMetric)
+	VpVisualSystemDefs       []colour.VisualSystem    `json:"abm-vp-visual-system-defs"`           // custom visual systems, alongside (or replacing) the built-in colour.VisualSystems
+	MorphBins                int                      `json:"abm-morph-bins"`                      // # of bins per RGB channel dividing CP Prey colouration into morphs, for the apostatic selection and diversity statistics. Default = 0 (stats.DefaultMorphBins)
+	ApostaticWindow          int                      `json:"abm-apostatic-window"`                // # of turns over which apostatic selection is measured. Default = 0 (stats.DefaultWindow)
+	ClusterRadius            float64                  `json:"abm-cluster-radius"`                  // colour.RGBDistance within which CP Prey colourations are clustered together when counting distinct morphs. Default = 0 (stats.DefaultClusterRadius)
+	ClusterMin               int                      `json:"abm-cluster-min"`                     // minimum # of CP Prey making up a distinct morph cluster. Default = 0 (stats.DefaultClusterMin)
 	RandomAges               bool                     `json:"abm-random-ages"`                     //	flag determining if agent ages are randomised
 	RNGRandomSeed            bool                     `json:"abm-rng-random-seed"`                 // flag for using server-set random seed val.
 	RNGSeedVal               int64                    `json:"abm-rng-seedval"`                     // RNG seed value
 	Fuzzy                    float64                  `json:"abm-rng-fuzziness"`                   //	random 'fuzziness' offset
 	Logging                  bool                     `json:"abm-logging-flag"`                    // log abm on/off
 	LogFreq                  int                      `json:"abm-log-frequency"`                   // # of turns between writing log files. Default = 0
+	LogAgentsCSV             bool                     `json:"abm-log-agents-csv"`                  // also log every agent to agents.csv, alongside the summary.csv time series
+	LogLineage               bool                     `json:"abm-log-lineage"`                     // keep the genealogy of every agent, written to lineage.csv and as Newick trees when the model stops
+	CheckpointFreq           int                      `json:"abm-checkpoint-frequency"`            // # of turns between writing checkpoints to the log path. Default = 0 (never)
 	UseCustomLogPath         bool                     `json:"abm-use-custom-log-filepath"`         //
 	CustomLogPath            string                   `json:"abm-custom-log-filepath"`             //
 	LogPath                  string                   `json:"abm-log-filepath"`                    //	Default logging filepath unless UseCustomLogPath is ON
@@ -107,6 +152,7 @@ type ConditionParams struct {
 	VisFreq                  int                      `json:"abm-visualise-freq"`                  //	# of turns between sending draw instructions to web client. Default = 0
 	LimitDuration            bool                     `json:"abm-limit-duration"`                  //
 	FixedDuration            int                      `json:"abm-fixed-duration"`                  // fixed abm running length.
+	Protocol                 []ProtocolAction         `json:"abm-protocol,omitempty"`              // scheduled changes to the running model
 	SessionIdentifier        string                   `json:"abm-session-identifier"`              // user-friendly string (from client) to identify session
 }
 
@@ -138,17 +184,24 @@ func (t *Timeframe) Reset() {
 type Stats struct {
 	numCpPreyCreated int
 	numCpPreyEaten   int
-	numCpPreyDeath   int
+	numCpPreyDeath   int //	of any cause, including those eaten
 	numVpCreated     int
 	numVpDeath       int
 }
 
 // DatBuf is a wrapper for the buffered agent data saved for logging.
 type DatBuf struct {
-	recordCPP map[string]ColourPolymorphicPrey
-	rcpPreyRW sync.RWMutex
-	recordVP  map[string]VisualPredator
-	rvpRW     sync.RWMutex
+	recordCPP         map[string]ColourPolymorphicPrey
+	recordQueue       []turnRecord //	completed turns waiting to be logged
+	rcpPreyRW         sync.RWMutex
+	recordVP          map[string]VisualPredator
+	rvpRW             sync.RWMutex
+	statsQueue        []Statistics     //	completed statistics waiting to be logged
+	statsVis          *Statistics      //	latest statistics not yet sent to the web client
+	envVis            *environmentView //	changed environment not yet sent to the web client
+	deathQueue        []DeathEvent     //	deaths waiting to be logged
+	interventionQueue []Intervention   //	changes to the model waiting to be logged
+	rStatsMu          sync.Mutex
 }
 
 // AgentDescription used to aid for logging / debugging - used at time of agent creation
@@ -188,8 +241,12 @@ func (m *Model) PopLog() {
 	log.Printf("vp population size = %v\n", len(m.popVisualPredator))
 }
 
-func uuid() string {
+// uuid draws the identifier from the model's rng so that, for a fixed seed,
+// agent identities are as reproducible as their behaviour.
+func uuid(rng *rand.Rand) string {
 	b := make([]byte, 16)
-	rand.Read(b)
+	// not rng.Read, which buffers unused bytes outside of the checkpointed source state.
+	binary.BigEndian.PutUint64(b[:8], rng.Uint64())
+	binary.BigEndian.PutUint64(b[8:], rng.Uint64())
 	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
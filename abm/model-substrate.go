@@ -0,0 +1,176 @@
+package abm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" //	register JPEG decoding for image substrates
+	_ "image/png"  //	register PNG decoding for image substrates
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+	"github.com/benjamin-rood/abm-cp/render"
+)
+
+// Types of environment substrate.
+const (
+	SubstrateUniform      = "uniform"      //	the entire environment is the background colour BG (default)
+	SubstratePatches      = "patches"      //	Size*Size square patches, each a random choice from the Palette
+	SubstrateCheckerboard = "checkerboard" //	Size*Size square patches, cycling through the Palette
+	SubstrateFile         = "file"         //	a Substrate map read from the JSON file at File
+	SubstrateImage        = "image"        //	a PNG or JPEG image (e.g. a photograph) at File, stretched across the environment
+)
+
+const (
+	dSubstrateSize      = 4
+	maxSubstrateImgSize = 256 //	largest number of image patches along either axis
+)
+
+/*
+SubstrateSpec describes how the substrate of an Environment is created.
+Procedurally generated substrates use their own Seed, independent of the
+model RNG, so the same habitat can be kept across different model runs.
+*/
+type SubstrateSpec struct {
+	Type    string       `json:"type"`
+	Size    int          `json:"size"`    //	number of patches along each axis (for an image, along its longest side)
+	Palette []colour.RGB `json:"palette"` //	substrate colours; random colours are used when empty
+	Seed    int64        `json:"seed"`
+	File    string       `json:"file"`
+}
+
+/*
+Substrate is a map of background colours covering the Environment: a grid of
+Rows*Cols patches spread evenly across its Bounds. Colours are stored in
+row-major order, with the first row along the top (+y) edge.
+*/
+type Substrate struct {
+	Rows    int          `json:"rows"`
+	Cols    int          `json:"cols"`
+	Colours []colour.RGB `json:"colours"`
+}
+
+// NewSubstrate creates the substrate described by spec. A uniform substrate
+// needs no map, and gives nil.
+func NewSubstrate(spec SubstrateSpec) (*Substrate, error) {
+	switch spec.Type {
+	case "", SubstrateUniform:
+		return nil, nil
+	case SubstrateFile:
+		return LoadSubstrate(spec.File)
+	case SubstrateImage:
+		return LoadSubstrateImage(spec.File, spec.Size)
+	case SubstratePatches, SubstrateCheckerboard:
+	default:
+		return nil, fmt.Errorf("unknown substrate type %q", spec.Type)
+	}
+
+	n := spec.Size
+	if n <= 0 {
+		n = dSubstrateSize
+	}
+	rng := calc.NewRNG(spec.Seed)
+	palette := spec.Palette
+	if len(palette) == 0 {
+		for i := 0; i < 2; i++ {
+			palette = append(palette, colour.RandRGB(rng))
+		}
+	}
+	s := &Substrate{Rows: n, Cols: n}
+	for row := 0; row < n; row++ {
+		for col := 0; col < n; col++ {
+			var c colour.RGB
+			if spec.Type == SubstrateCheckerboard {
+				c = palette[(row+col)%len(palette)]
+			} else {
+				c = palette[rng.Intn(len(palette))]
+			}
+			s.Colours = append(s.Colours, c)
+		}
+	}
+	return s, nil
+}
+
+// LoadSubstrate reads a JSON-formatted Substrate map from filename.
+func LoadSubstrate(filename string) (*Substrate, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &Substrate{}
+	err = json.Unmarshal(raw, s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Rows <= 0 || s.Cols <= 0 || len(s.Colours) != s.Rows*s.Cols {
+		return nil, errors.New("substrate map " + filename + ": must have rows*cols colours")
+	}
+	return s, nil
+}
+
+/*
+LoadSubstrateImage reads a PNG or JPEG image from filename as a Substrate map,
+with one patch per pixel. Large images are first downsampled so that there are
+no more than size patches along the longest side (or maxSubstrateImgSize
+when size ≤ 0). The image is stretched to cover the environment Bounds,
+whatever its aspect ratio.
+*/
+func LoadSubstrateImage(filename string, size int) (*Substrate, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.New("substrate image " + filename + ": " + err.Error())
+	}
+	if size <= 0 || size > maxSubstrateImgSize {
+		size = maxSubstrateImgSize
+	}
+	return SubstrateFromImage(render.Downsample(img, size)), nil
+}
+
+// SubstrateFromImage creates a Substrate map with one patch for each pixel of img.
+func SubstrateFromImage(img image.Image) *Substrate {
+	b := img.Bounds()
+	s := &Substrate{Rows: b.Dy(), Cols: b.Dx()}
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		for px := b.Min.X; px < b.Max.X; px++ {
+			s.Colours = append(s.Colours, colour.FromColor(img.At(px, py)))
+		}
+	}
+	return s
+}
+
+// Image gives the substrate map as an image, with one pixel for each patch.
+func (s *Substrate) Image() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, s.Cols, s.Rows))
+	for row := 0; row < s.Rows; row++ {
+		for col := 0; col < s.Cols; col++ {
+			img.SetRGBA(col, row, s.Colours[(row*s.Cols)+col].Color())
+		}
+	}
+	return img
+}
+
+// At gives the substrate colour at pos within an environment of the given bounds.
+func (s *Substrate) At(pos geometry.Vector, bounds []float64) colour.RGB {
+	dx, dy := 1.0, 1.0
+	if len(bounds) > x && bounds[x] > 0 {
+		dx = bounds[x]
+	}
+	if len(bounds) > y && bounds[y] > 0 {
+		dy = bounds[y]
+	}
+	col := int(math.Floor(((pos[x] + dx) / (2 * dx)) * float64(s.Cols)))
+	row := int(math.Floor(((dy - pos[y]) / (2 * dy)) * float64(s.Rows)))
+	col = calc.ClampIntIn(col, 0, s.Cols-1)
+	row = calc.ClampIntIn(row, 0, s.Rows-1)
+	return s.Colours[(row*s.Cols)+col]
+}
@@ -0,0 +1,111 @@
+package abm
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+)
+
+func TestSubstrate(t *testing.T) {
+	spec := SubstrateSpec{Type: SubstrateCheckerboard, Size: 2, Palette: []colour.RGB{colour.Black, colour.White}}
+	s, err := NewSubstrate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := []float64{1.0, 1.0}
+	var tests = []struct {
+		pos  geometry.Vector
+		want colour.RGB
+	}{
+		{geometry.Vector{-0.5, 0.5}, colour.Black}, //	top-left
+		{geometry.Vector{0.5, 0.5}, colour.White},
+		{geometry.Vector{-0.5, -0.5}, colour.White},
+		{geometry.Vector{0.99, -0.99}, colour.Black}, //	bottom-right
+		{geometry.Vector{1.0, -1.0}, colour.Black},   //	edges belong to the last patch
+	}
+	for _, test := range tests {
+		if got := s.At(test.pos, bounds); got != test.want {
+			t.Errorf("At(%v) == %v, want %v", test.pos, got, test.want)
+		}
+	}
+
+	spec = SubstrateSpec{Type: SubstratePatches, Size: 8, Seed: 7}
+	a, _ := NewSubstrate(spec)
+	b, _ := NewSubstrate(spec)
+	if len(a.Colours) != 64 {
+		t.Fatalf("generated %d patches, want 64", len(a.Colours))
+	}
+	for i := range a.Colours {
+		if a.Colours[i] != b.Colours[i] {
+			t.Fatalf("patches generated from the same seed differ")
+		}
+	}
+	if _, err := NewSubstrate(SubstrateSpec{Type: "swamp"}); err == nil {
+		t.Errorf("unknown substrate type accepted")
+	}
+}
+
+func TestPreySearchCrypsis(t *testing.T) {
+	conditions := TestConditionParams
+	conditions.SubstrateSpec = SubstrateSpec{Type: SubstrateCheckerboard, Size: 2, Palette: []colour.RGB{colour.Black, colour.White}}
+	conditions.Substrate, _ = NewSubstrate(conditions.SubstrateSpec)
+	conditions.VpCrypsisWeight = 1.0
+
+	predator := vpTesterAgent(0, 0)
+	predator.vsr = 0.5
+	predator.𝛄 = 0.5
+	predator.τ = colour.RGB{Red: 0.1, Green: 0.1, Blue: 0.1}
+	prey := []ColourPolymorphicPrey{
+		cpPreyTesterAgent(-0.1, 0.1), //	dark prey on the dark patch: cryptic.
+		cpPreyTesterAgent(0.1, 0.1),  //	dark prey on the light patch: conspicuous.
+	}
+	prey[0].colouration = colour.RGB{Red: 0.05, Green: 0.05, Blue: 0.05}
+	prey[1].colouration = colour.RGB{Red: 0.05, Green: 0.05, Blue: 0.05}
+
+	target, _ := predator.PreySearch(prey, conditions, nil)
+	if target != &prey[1] {
+		t.Errorf("targeted %p, want the conspicuous prey %p", target, &prey[1])
+	}
+	prey[1].pos = geometry.Vector{-0.2, 0.2} //	now also on the dark patch.
+	if target, _ := predator.PreySearch(prey, conditions, nil); target != nil {
+		t.Errorf("found cryptic prey at %v", target.pos)
+	}
+	conditions.VpCrypsisWeight = 0 //	background plays no part.
+	if target, _ := predator.PreySearch(prey, conditions, nil); target == nil {
+		t.Errorf("no prey found when crypsis is disabled")
+	}
+}
+
+func TestSubstrateImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(img, image.Rect(0, 0, 10, 10), image.NewUniform(colour.Red.Color()), image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(10, 0, 20, 10), image.NewUniform(colour.Blue.Color()), image.ZP, draw.Src)
+	f, err := ioutil.TempFile("", "substrate*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	png.Encode(f, img)
+	f.Close()
+
+	s, err := NewSubstrate(SubstrateSpec{Type: SubstrateImage, File: f.Name(), Size: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cols != 4 || s.Rows != 2 {
+		t.Errorf("image substrate is %dx%d, want 4x2", s.Cols, s.Rows)
+	}
+	bounds := []float64{1.0, 1.0}
+	if got := s.At(geometry.Vector{-0.5, 0.5}, bounds); got != colour.Red {
+		t.Errorf("left half == %v, want %v", got, colour.Red)
+	}
+	if got := s.At(geometry.Vector{0.5, -0.5}, bounds); got != colour.Blue {
+		t.Errorf("right half == %v, want %v", got, colour.Blue)
+	}
+}
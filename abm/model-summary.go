@@ -0,0 +1,230 @@
+package abm
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+// Files of the CSV time series written into the log directory.
+const (
+	summaryFile = "summary.csv" //	one row per logged turn (see TurnSummary)
+	agentsFile  = "agents.csv"  //	one row per agent per logged turn, when LogAgentsCSV is set
+)
+
+/*
+TurnSummary holds the population sizes, the running totals from Stats, and
+the distribution of agent traits at the end of a turn. The means and
+variances of the CP Prey colouration are over the RGB channels, and its
+diversity is over the morph classes of MorphBins (see stats.Diversity); the
+predator means are of their imprinted target colour τ, imprinting strength
+ετ and search tolerance 𝛄.
+*/
+type TurnSummary struct {
+	Turn               int     `json:"turn"`
+	CpPreyPopulation   int     `json:"cp-prey-pop"`
+	VpPopulation       int     `json:"vp-pop"`
+	CpPreyCreated      int     `json:"cp-prey-created"`
+	CpPreyEaten        int     `json:"cp-prey-eaten"`
+	CpPreyDeaths       int     `json:"cp-prey-deaths"`
+	VpCreated          int     `json:"vp-created"`
+	VpDeaths           int     `json:"vp-deaths"`
+	CpPreyRedMean      float64 `json:"cp-prey-red-mean"`
+	CpPreyRedVar       float64 `json:"cp-prey-red-var"`
+	CpPreyGreenMean    float64 `json:"cp-prey-green-mean"`
+	CpPreyGreenVar     float64 `json:"cp-prey-green-var"`
+	CpPreyBlueMean     float64 `json:"cp-prey-blue-mean"`
+	CpPreyBlueVar      float64 `json:"cp-prey-blue-var"`
+	CpPreyMorphs       int     `json:"cp-prey-morphs"`
+	CpPreyShannon      float64 `json:"cp-prey-shannon"`
+	CpPreySimpson      float64 `json:"cp-prey-simpson"`
+	CpPreyClusters     int     `json:"cp-prey-clusters"`
+	CpPreyMeanDistance float64 `json:"cp-prey-mean-distance"`
+	VpImprintRedMean   float64 `json:"vp-τ-red-mean"`
+	VpImprintGreenMean float64 `json:"vp-τ-green-mean"`
+	VpImprintBlueMean  float64 `json:"vp-τ-blue-mean"`
+	VpImprintStrength  float64 `json:"vp-ετ-mean"`
+	VpToleranceMean    float64 `json:"vp-𝛄-mean"`
+}
+
+var summaryHeader = []string{
+	"turn", "cp_prey_pop", "vp_pop",
+	"cp_prey_created", "cp_prey_eaten", "cp_prey_deaths", "vp_created", "vp_deaths",
+	"cp_prey_red_mean", "cp_prey_red_var", "cp_prey_green_mean", "cp_prey_green_var", "cp_prey_blue_mean", "cp_prey_blue_var",
+	"cp_prey_morphs", "cp_prey_shannon", "cp_prey_simpson", "cp_prey_clusters", "cp_prey_mean_distance",
+	"vp_imprint_red_mean", "vp_imprint_green_mean", "vp_imprint_blue_mean", "vp_imprint_strength_mean", "vp_tolerance_mean",
+}
+
+// livingCpPrey gives the CP Prey which haven't been eaten: those caught in
+// the Visual Predator phase stay in popCpPrey until the next turn, but are
+// already counted among the deaths.
+func (m *Model) livingCpPrey() []ColourPolymorphicPrey {
+	living := make([]ColourPolymorphicPrey, 0, len(m.popCpPrey))
+	for _, c := range m.popCpPrey {
+		if !c.eaten() {
+			living = append(living, c)
+		}
+	}
+	return living
+}
+
+// summarise the current state of the model populations, of the living CP Prey only.
+func (m *Model) summarise() TurnSummary {
+	prey := m.livingCpPrey()
+	s := TurnSummary{
+		Turn:             m.Turn,
+		CpPreyPopulation: len(prey),
+		VpPopulation:     len(m.popVisualPredator),
+		CpPreyCreated:    m.numCpPreyCreated,
+		CpPreyEaten:      m.numCpPreyEaten,
+		CpPreyDeaths:     m.numCpPreyDeath,
+		VpCreated:        m.numVpCreated,
+		VpDeaths:         m.numVpDeath,
+	}
+	red := make([]float64, len(prey))
+	green := make([]float64, len(prey))
+	blue := make([]float64, len(prey))
+	colourations := make([]colour.RGB, len(prey))
+	for i, c := range prey {
+		red[i], green[i], blue[i] = c.colouration.Red, c.colouration.Green, c.colouration.Blue
+		colourations[i] = c.colouration
+	}
+	s.CpPreyRedMean, s.CpPreyRedVar = calc.MeanVariance(red)
+	s.CpPreyGreenMean, s.CpPreyGreenVar = calc.MeanVariance(green)
+	s.CpPreyBlueMean, s.CpPreyBlueVar = calc.MeanVariance(blue)
+	d := stats.NewMorphs(m.MorphBins).Diversity(colourations, m.ClusterRadius, m.ClusterMin)
+	s.CpPreyMorphs, s.CpPreyShannon, s.CpPreySimpson = d.Richness, d.Shannon, d.Simpson
+	s.CpPreyClusters, s.CpPreyMeanDistance = d.Clusters, d.MeanDistance
+
+	var τred, τgreen, τblue, ετ, 𝛄 []float64
+	for _, vp := range m.popVisualPredator {
+		τred = append(τred, vp.τ.Red)
+		τgreen = append(τgreen, vp.τ.Green)
+		τblue = append(τblue, vp.τ.Blue)
+		ετ = append(ετ, vp.ετ)
+		𝛄 = append(𝛄, vp.𝛄)
+	}
+	s.VpImprintRedMean, _ = calc.MeanVariance(τred)
+	s.VpImprintGreenMean, _ = calc.MeanVariance(τgreen)
+	s.VpImprintBlueMean, _ = calc.MeanVariance(τblue)
+	s.VpImprintStrength, _ = calc.MeanVariance(ετ)
+	s.VpToleranceMean, _ = calc.MeanVariance(𝛄)
+	return s
+}
+
+// Columns gives the summary as in summary.csv: the column names, and the row of values.
+func (s TurnSummary) Columns() ([]string, []string) {
+	return summaryHeader, s.row()
+}
+
+func (s TurnSummary) row() []string {
+	return []string{
+		strconv.Itoa(s.Turn), strconv.Itoa(s.CpPreyPopulation), strconv.Itoa(s.VpPopulation),
+		strconv.Itoa(s.CpPreyCreated), strconv.Itoa(s.CpPreyEaten), strconv.Itoa(s.CpPreyDeaths), strconv.Itoa(s.VpCreated), strconv.Itoa(s.VpDeaths),
+		ftoa(s.CpPreyRedMean), ftoa(s.CpPreyRedVar), ftoa(s.CpPreyGreenMean), ftoa(s.CpPreyGreenVar), ftoa(s.CpPreyBlueMean), ftoa(s.CpPreyBlueVar),
+		strconv.Itoa(s.CpPreyMorphs), ftoa(s.CpPreyShannon), ftoa(s.CpPreySimpson), strconv.Itoa(s.CpPreyClusters), ftoa(s.CpPreyMeanDistance),
+		ftoa(s.VpImprintRedMean), ftoa(s.VpImprintGreenMean), ftoa(s.VpImprintBlueMean), ftoa(s.VpImprintStrength), ftoa(s.VpToleranceMean),
+	}
+}
+
+// morphCounts gives the number of living CP Prey in each morph class of MorphBins.
+func (m *Model) morphCounts() []int {
+	prey := m.livingCpPrey()
+	colourations := make([]colour.RGB, len(prey))
+	for i, c := range prey {
+		colourations[i] = c.colouration
+	}
+	return stats.NewMorphs(m.MorphBins).Count(colourations)
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var agentsHeader = []string{
+	"turn", "agent_type", "uuid", "agent_num", "parent_uuid", "x", "y", "heading",
+	"lifespan", "hunger", "fertility", "red", "green", "blue", "uv", "imprint_strength", "tolerance", "vision",
+}
+
+// agentRows gives the long-format rows for the CP Prey and Visual Predator
+// records of a turn, ordered by agent type and then number. For predators,
+// the colour columns hold their imprinted target colour τ.
+func agentRows(turn int, cpr map[string]ColourPolymorphicPrey, vpr map[string]VisualPredator) [][]string {
+	t := strconv.Itoa(turn)
+	var prey []ColourPolymorphicPrey
+	for _, c := range cpr {
+		prey = append(prey, c)
+	}
+	sort.Slice(prey, func(i, j int) bool { return prey[i].description.AgentNum < prey[j].description.AgentNum })
+	var predators []VisualPredator
+	for _, vp := range vpr {
+		predators = append(predators, vp)
+	}
+	sort.Slice(predators, func(i, j int) bool { return predators[i].description.AgentNum < predators[j].description.AgentNum })
+
+	var rows [][]string
+	for _, c := range prey {
+		rows = append(rows, []string{
+			t, "cpPrey", c.uuid, strconv.Itoa(c.description.AgentNum), c.description.ParentUUID,
+			ftoa(c.pos[x]), ftoa(c.pos[y]), ftoa(c.𝚯),
+			strconv.Itoa(c.lifespan), strconv.Itoa(c.hunger), strconv.Itoa(c.fertility),
+			ftoa(c.colouration.Red), ftoa(c.colouration.Green), ftoa(c.colouration.Blue), ftoa(c.uv),
+			"", "", "",
+		})
+	}
+	for _, vp := range predators {
+		rows = append(rows, []string{
+			t, "vp", vp.uuid, strconv.Itoa(vp.description.AgentNum), vp.description.ParentUUID,
+			ftoa(vp.pos[x]), ftoa(vp.pos[y]), ftoa(vp.𝚯),
+			strconv.Itoa(vp.lifespan), strconv.Itoa(vp.hunger), strconv.Itoa(vp.fertility),
+			ftoa(vp.τ.Red), ftoa(vp.τ.Green), ftoa(vp.τ.Blue), ftoa(vp.τuv),
+			ftoa(vp.ετ), ftoa(vp.𝛄), vp.vision,
+		})
+	}
+	return rows
+}
+
+/*
+csvSeries appends rows to a CSV file, flushing after every write so that
+the file can be read while the model is still running. The header is only
+written to a new (empty) file, so a resumed model carries on the same series.
+*/
+type csvSeries struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func openCSVSeries(dir string, name string, header []string) (*csvSeries, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	s := &csvSeries{f: f, w: csv.NewWriter(f)}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		err = s.write(header)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *csvSeries) write(rows ...[]string) error {
+	return s.w.WriteAll(rows) //	flushes
+}
+
+func (s *csvSeries) Close() error {
+	s.w.Flush()
+	return s.f.Close()
+}
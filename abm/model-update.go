@@ -0,0 +1,173 @@
+package abm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// interventionsFile is the JSON Lines log of changes made to the conditions of a running model.
+const interventionsFile = "interventions.jsonl"
+
+/*
+fixedConditions are the ConditionParams which can't be changed while a
+model runs: they shape the environment, the founding populations or the
+processes of the engine, all set up at Start.
+*/
+var fixedConditions = map[string]bool{
+	"abm-environment":             true,
+	"abm-cp-prey-pop-start":       true,
+	"abm-vp-pop-start":            true,
+	"abm-cp-prey-genetics":        true,
+	"abm-morph-bins":              true,
+	"abm-apostatic-window":        true,
+	"abm-random-ages":             true,
+	"abm-rng-random-seed":         true,
+	"abm-rng-seedval":             true,
+	"abm-logging-flag":            true,
+	"abm-log-agents-csv":          true,
+	"abm-log-lineage":             true,
+	"abm-use-custom-log-filepath": true,
+	"abm-custom-log-filepath":     true,
+	"abm-log-filepath":            true,
+	"abm-visualise-flag":          true,
+	"abm-session-identifier":      true,
+	"abm-protocol":                true,
+}
+
+// Intervention records a change made to a running model, from the
+// beginning of its Turn.
+type Intervention struct {
+	Source string `json:"source"` //	where the change came from: "client" or "protocol"
+	ProtocolAction
+}
+
+/*
+checkPatch verifies that patch is a JSON object of ConditionParams fields
+(by JSON key), each of the right type and none of them fixedConditions.
+*/
+func checkPatch(patch json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(patch, &fields)
+	if err != nil {
+		return fmt.Errorf("conditions patch: %s", err)
+	}
+	var fixed []string
+	for key := range fields {
+		if fixedConditions[key] {
+			fixed = append(fixed, key)
+		}
+	}
+	if len(fixed) > 0 {
+		sort.Strings(fixed)
+		return fmt.Errorf("conditions patch: can't be changed while the model runs: %q", fixed)
+	}
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	var cp ConditionParams
+	err = dec.Decode(&cp)
+	if err != nil {
+		return fmt.Errorf("conditions patch: %s", err)
+	}
+	return nil
+}
+
+/*
+Update changes the conditions of the model from the beginning of the next
+turn (or, if suspended, the turn it resumes with), leaving the agent
+populations as they are. The patch is a JSON object of just the
+ConditionParams fields to be changed, keyed as in a "conditions" message,
+e.g. {"abm-vp-attack-chance": 0.5}. An invalid patch is rejected whole, as
+is one which would leave invalid conditions (a ValidationError), checked
+against the conditions the updates already waiting will leave. Every change
+is recorded in the log as an Intervention. Updates still waiting are kept
+in a checkpoint, so a session saved and resumed doesn't lose them.
+*/
+func (m *Model) Update(patch json.RawMessage) error {
+	err := checkPatch(patch)
+	if err != nil {
+		return err
+	}
+	u := Intervention{Source: "client", ProtocolAction: ProtocolAction{Action: ActionConditions, Patch: patch}}
+	defer m.updateMu.Unlock()
+	m.updateMu.Lock()
+	ahead := m.ConditionParams
+	for _, waiting := range m.updates {
+		if ahead.validatePatch(waiting.ProtocolAction) == nil {
+			ahead, _ = ahead.patched(waiting.ProtocolAction)
+		}
+	}
+	err = ahead.validatePatch(u.ProtocolAction)
+	if err != nil {
+		return err
+	}
+	m.updates = append(m.updates, u)
+	return nil
+}
+
+// discardUpdates drops any changes made by Update which are yet to be applied.
+func (m *Model) discardUpdates() {
+	defer m.updateMu.Unlock()
+	m.updateMu.Lock()
+	m.updates = nil
+}
+
+// applyUpdates applies the changes made by Update since the last turn, in
+// order, each independently of the others: every one rejected is reported
+// on errCh. It must only be called between turns. The conditions only change
+// while holding updateMu, so Update can check a patch against them meanwhile.
+func (m *Model) applyUpdates(errCh chan<- error) {
+	defer m.updateMu.Unlock()
+	m.updateMu.Lock()
+	for _, u := range m.updates {
+		if err := m.intervene(u); err != nil {
+			errCh <- err
+		}
+	}
+	m.updates = nil
+}
+
+// intervened hands an applied Intervention to the LOG process, when logging.
+func (m *Model) intervened(u Intervention) {
+	if !m.Logging {
+		return
+	}
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	m.interventionQueue = append(m.interventionQueue, u)
+}
+
+// interventionsTake returns the interventions queued for logging, in order, emptying the queue.
+func (m *Model) interventionsTake() []Intervention {
+	defer m.rStatsMu.Unlock()
+	m.rStatsMu.Lock()
+	queue := m.interventionQueue
+	m.interventionQueue = nil
+	return queue
+}
+
+// appendJSONLines appends each of vs to the file name in dir, as a line of JSON.
+func appendJSONLines(dir string, name string, vs ...interface{}) error {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f) //	one value per line.
+	for _, v := range vs {
+		err = enc.Encode(v)
+		if err != nil {
+			break
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package abm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestCheckPatch(t *testing.T) {
+	for patch, valid := range map[string]bool{
+		`{"abm-vp-attack-chance": 0.5, "abm-cp-prey-mf": 0.1}`: true,
+		`{}`:                               true,
+		`{"abm-vp-attack-chance": "high"}`: false,
+		`{"abm-vp-atack-chance": 0.5}`:     false,
+		`{"abm-cp-prey-pop-start": 10}`:    false,
+		`{"abm-environment": {}}`:          false,
+		`[0.5]`:                            false,
+	} {
+		if err := checkPatch(json.RawMessage(patch)); (err == nil) != valid {
+			t.Errorf("checkPatch(%s) == %v", patch, err)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	m := testModel(t, 5)
+	runBatch(t, m)
+	if err := m.Update(json.RawMessage(`{"abm-vp-attack-chance": 0}`)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer //	the update waiting is saved with the rest.
+	if err := m.Checkpoint(&buf); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := RestoreModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	population := len(resumed.popCpPrey)
+	if len(resumed.updates) != 1 {
+		t.Fatalf("%d updates restored, want 1", len(resumed.updates))
+	}
+	if resumed.VpAttackChance != TestConditionParams.VpAttackChance {
+		t.Fatal("conditions changed before the next turn")
+	}
+	resumed.FixedDuration = 10
+	resumed.OutputDir = m.OutputDir
+	summary := runBatch(t, resumed)
+	if resumed.VpAttackChance != 0 {
+		t.Errorf("VpAttackChance == %v, want 0", resumed.VpAttackChance)
+	}
+	if summary.CpPreyCreated < population {
+		t.Errorf("populations replaced: %d CP Prey created in all, %d before the update", summary.CpPreyCreated, population)
+	}
+	for _, row := range readLog(t, resumed, deathsFile)[1:] {
+		if turn, _ := strconv.Atoi(row[0]); turn >= 5 && row[3] == CauseEaten {
+			t.Errorf("CP Prey eaten on turn %d, after attacks were stopped", turn)
+		}
+	}
+
+	if interventions := readInterventions(t, resumed); len(interventions) != 1 || interventions[0].Turn != 5 || interventions[0].Source != "client" {
+		t.Errorf("interventions logged: %+v", interventions)
+	}
+}
+
+func TestUpdateRejected(t *testing.T) {
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	if err := m.Update(json.RawMessage(`{"abm-vp-attack-chance": 0}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := m.Update(json.RawMessage(`{"abm-vp-attack-chance": 5}`))
+	if invalid, ok := err.(ValidationError); !ok || invalid[0].Key != "abm-vp-attack-chance" {
+		t.Errorf("Update(abm-vp-attack-chance 5) == %v, want a ValidationError", err)
+	}
+	if len(m.updates) != 1 {
+		t.Fatalf("%d updates waiting, want 1", len(m.updates))
+	}
+
+	// an update can still be rejected when applied, e.g. after a protocol patch.
+	m.updates = append([]Intervention{{Source: "client", ProtocolAction: ProtocolAction{Action: ActionConditions, Patch: json.RawMessage(`{"abm-cp-prey-mf": -1}`)}}}, m.updates...)
+	errCh := make(chan error, 2)
+	m.applyUpdates(errCh)
+	close(errCh)
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || m.VpAttackChance != 0 || m.CpPreyMutationFactor != TestConditionParams.CpPreyMutationFactor {
+		t.Errorf("applied with errors %v: abm-vp-attack-chance %v, abm-cp-prey-mf %v", errs, m.VpAttackChance, m.CpPreyMutationFactor)
+	}
+}
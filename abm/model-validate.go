@@ -0,0 +1,259 @@
+package abm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+)
+
+// FieldError is a ConditionParams field whose value breaks a constraint.
+type FieldError struct {
+	Key        string      `json:"key"`        //	JSON key of the field; nested keys are joined by "."
+	Value      interface{} `json:"value"`      //	the offending value
+	Constraint string      `json:"constraint"` //	what the value must be, e.g. "≥ 0"
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s = %v: must be %s", e.Key, e.Value, e.Constraint)
+}
+
+// ValidationError lists every FieldError of a set of ConditionParams.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	s := make([]string, len(e))
+	for i, fe := range e {
+		s[i] = fe.Error()
+	}
+	return "invalid conditions: " + strings.Join(s, "; ")
+}
+
+// validator collects the FieldErrors of the constraints it checks.
+type validator struct {
+	errs ValidationError
+}
+
+// check records a FieldError unless ok.
+func (v *validator) check(ok bool, key string, value interface{}, constraint string) {
+	if !ok {
+		v.errs = append(v.errs, FieldError{Key: key, Value: value, Constraint: constraint})
+	}
+}
+
+// err gives the collected errors, or nil when there are none.
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *validator) nonNegative(key string, n int) {
+	v.check(n >= 0, key, n, "≥ 0")
+}
+
+func (v *validator) nonNegativeFloat(key string, f float64) {
+	v.check(f >= 0, key, f, "≥ 0")
+}
+
+func (v *validator) chance(key string, f float64) {
+	v.check(f >= 0 && f <= 1, key, f, "in [0, 1]")
+}
+
+func (v *validator) colour(key string, c colour.RGB) {
+	v.check(c.Red >= 0 && c.Red <= 1 && c.Green >= 0 && c.Green <= 1 && c.Blue >= 0 && c.Blue <= 1, key, c, "a colour with channels in [0, 1]")
+}
+
+/*
+Validate checks every field of the conditions against the constraints the
+model needs to run, returning a ValidationError listing each one broken, or
+nil. The actions of the Protocol are checked too, along with the conditions
+each of its patches would leave the model with.
+*/
+func (c ConditionParams) Validate() error {
+	v := &validator{}
+	c.validateFields(v, "")
+	c.validateProtocol(v)
+	return v.err()
+}
+
+/*
+ValidateHeadless checks the conditions as Validate does, and also that a
+run without a client attached will end of its own accord: nobody is there
+to stop it, so abm-limit-duration must be set with a positive
+abm-fixed-duration.
+*/
+func (c ConditionParams) ValidateHeadless() error {
+	v := &validator{}
+	c.validateFields(v, "")
+	c.validateProtocol(v)
+	v.check(c.LimitDuration, "abm-limit-duration", c.LimitDuration, "set for a headless run to end")
+	v.check(c.FixedDuration > 0, "abm-fixed-duration", c.FixedDuration, "> 0 for a headless run to end")
+	return v.err()
+}
+
+// validateFields checks the fields of c other than the Protocol, prefixing every key.
+func (c ConditionParams) validateFields(v *validator, prefix string) {
+	env := prefix + "abm-environment."
+	v.check(len(c.Bounds) == 2 && c.Dimensionality == 2, env+"abm-environment-dimensionality", c.Dimensionality, "2, with 2 abm-environment-bounds")
+	for _, b := range c.Bounds {
+		if b <= 0 {
+			v.check(false, env+"abm-environment-bounds", c.Bounds, "> 0 along every axis")
+			break
+		}
+	}
+	switch c.Boundary {
+	case "", geometry.Wrap, geometry.Reflect, geometry.Wall:
+	default:
+		v.check(false, env+"abm-environment-boundary", c.Boundary, fmt.Sprintf("one of %q, %q or %q", geometry.Wrap, geometry.Reflect, geometry.Wall))
+	}
+	v.colour(env+"abm-environment-background", c.BG)
+	c.SubstrateSpec.validate(v, env+"abm-environment-substrate.")
+
+	p := prefix
+	v.nonNegative(p+"abm-cp-prey-pop-start", c.CpPreyPopulationStart)
+	v.check(c.CpPreyPopulationCap >= c.CpPreyPopulationStart, p+"abm-cp-prey-pop-cap", c.CpPreyPopulationCap, fmt.Sprintf("≥ abm-cp-prey-pop-start (%d)", c.CpPreyPopulationStart))
+	v.check(!c.CpPreyAgeing || c.CpPreyLifespan >= 1, p+"abm-cp-prey-lifespan", c.CpPreyLifespan, "≥ 1 when abm-cp-prey-ageing")
+	v.nonNegativeFloat(p+"abm-cp-prey-speed", c.CpPreyS)
+	v.nonNegativeFloat(p+"abm-cp-prey-acceleration", c.CpPreyA)
+	v.nonNegativeFloat(p+"abm-cp-prey-turn", c.CpPreyTurn)
+	v.nonNegativeFloat(p+"abm-cp-prey-sr", c.CpPreySr)
+	v.nonNegative(p+"abm-cp-prey-gestation", c.CpPreyGestation)
+	v.nonNegative(p+"abm-cp-prey-sexual-cost", c.CpPreySexualCost)
+	v.chance(p+"abm-cp-prey-reproduction-chance", c.CpPreyReproductionChance)
+	v.nonNegative(p+"abm-cp-prey-spawn-size", c.CpPreySpawnSize)
+	v.nonNegativeFloat(p+"abm-cp-prey-mf", c.CpPreyMutationFactor)
+	if c.CpPreyGenetics != nil {
+		c.CpPreyGenetics.validate(v, p+"abm-cp-prey-genetics.")
+	}
+
+	v.nonNegative(p+"abm-vp-pop-start", c.VpPopulationStart)
+	v.check(c.VpPopulationCap >= c.VpPopulationStart, p+"abm-vp-pop-cap", c.VpPopulationCap, fmt.Sprintf("≥ abm-vp-pop-start (%d)", c.VpPopulationStart))
+	v.check(!c.VpAgeing || c.VpLifespan >= 1, p+"abm-vp-lifespan", c.VpLifespan, "≥ 1 when abm-vp-ageing")
+	v.nonNegative(p+"abm-vp-starvation-point", c.VpStarvationPoint)
+	v.nonNegative(p+"abm-vp-panic-point", c.VpPanicPoint)
+	v.nonNegative(p+"abm-vp-gestation", c.VpGestation)
+	v.nonNegative(p+"abm-vp-sex-req", c.VpSexualRequirement)
+	v.nonNegativeFloat(p+"abm-vp-speed", c.VpMovS)
+	v.nonNegativeFloat(p+"abm-vp-acceleration", c.VpMovA)
+	v.nonNegativeFloat(p+"abm-vp-turn", c.VpTurn)
+	v.nonNegativeFloat(p+"abm-vp-vsr", c.VpVsr)
+	v.nonNegativeFloat(p+"abm-vp-visual-search-tolerance", c.VpVb𝛄)
+	v.nonNegativeFloat(p+"abm-vp-visual-search-tolerance-bump", c.VpV𝛄Bump)
+	v.nonNegativeFloat(p+"abm-vp-baseline-col-sig-strength", c.VpVbε)
+	v.nonNegativeFloat(p+"abm-vp-max-col-sig-strength", c.VpVmε)
+	v.chance(p+"abm-vp-reproduction-chance", c.VpReproductionChance)
+	v.nonNegative(p+"abm-vp-spawn-size", c.VpSpawnSize)
+	v.chance(p+"abm-vp-vsr-chance", c.VpSearchChance)
+	v.chance(p+"abm-vp-attack-chance", c.VpAttackChance)
+	v.nonNegativeFloat(p+"abm-vp-baseline-attack-gain", c.VpBaseAttackGain)
+	v.chance(p+"abm-vp-col-adaptation-factor", c.VpCaf)
+	v.chance(p+"abm-vp-crypsis-weight", c.VpCrypsisWeight)
+	_, err := colour.MetricNamed(c.VpColourMetric)
+	v.check(err == nil, p+"abm-vp-colour-metric", c.VpColourMetric, fmt.Sprintf("one of %q, %q or %q", colour.MetricRGB, colour.MetricCIE76, colour.MetricCIEDE2000))
+	for i, vs := range c.VpVisualSystemDefs {
+		err := vs.Validate()
+		v.check(err == nil, fmt.Sprintf("%sabm-vp-visual-system-defs[%d]", p, i), vs.Name, fmt.Sprintf("a usable visual system (%v)", err))
+	}
+	for i, name := range c.VpVisualSystems {
+		_, ok := c.visualSystem(name)
+		v.check(ok, fmt.Sprintf("%sabm-vp-visual-systems[%d]", p, i), name, "a built-in visual system or one of abm-vp-visual-system-defs")
+	}
+
+	v.nonNegative(p+"abm-morph-bins", c.MorphBins)
+	v.nonNegative(p+"abm-apostatic-window", c.ApostaticWindow)
+	v.nonNegativeFloat(p+"abm-cluster-radius", c.ClusterRadius)
+	v.nonNegative(p+"abm-cluster-min", c.ClusterMin)
+	v.nonNegativeFloat(p+"abm-rng-fuzziness", c.Fuzzy)
+	v.nonNegative(p+"abm-log-frequency", c.LogFreq)
+	v.nonNegative(p+"abm-checkpoint-frequency", c.CheckpointFreq)
+	v.check(!c.UseCustomLogPath || c.CustomLogPath != "", p+"abm-custom-log-filepath", c.CustomLogPath, "set when abm-use-custom-log-filepath")
+	v.nonNegative(p+"abm-visualise-freq", c.VisFreq)
+	v.nonNegative(p+"abm-fixed-duration", c.FixedDuration)
+	v.check(validSession(c.SessionIdentifier), p+"abm-session-identifier", c.SessionIdentifier, `non-empty, without a path separator or ".."`)
+}
+
+// validate checks the substrate can be created, short of reading its file.
+func (s SubstrateSpec) validate(v *validator, prefix string) {
+	switch s.Type {
+	case "", SubstrateUniform, SubstratePatches, SubstrateCheckerboard:
+	case SubstrateFile, SubstrateImage:
+		v.check(s.File != "", prefix+"file", s.File, "set for a "+s.Type+" substrate")
+	default:
+		v.check(false, prefix+"type", s.Type, fmt.Sprintf("one of %q, %q, %q, %q or %q", SubstrateUniform, SubstratePatches, SubstrateCheckerboard, SubstrateFile, SubstrateImage))
+	}
+	v.nonNegative(prefix+"size", s.Size)
+	for i, c := range s.Palette {
+		v.colour(fmt.Sprintf("%spalette[%d]", prefix, i), c)
+	}
+}
+
+/*
+validateProtocol checks every action of the Protocol. When all else is
+valid, it then makes the conditions and environment patches in turn order
+on a copy of c, checking the conditions the model would be left with after
+each one.
+*/
+func (c ConditionParams) validateProtocol(v *validator) {
+	var patches []int
+	for i, a := range c.Protocol {
+		a.check(v, fmt.Sprintf("abm-protocol[%d].", i))
+		if a.Colour != nil && c.CpPreyGenetics != nil {
+			v.check(false, fmt.Sprintf("abm-protocol[%d].colour", i), *a.Colour, "absent with abm-cp-prey-genetics, which decides the colouration")
+		}
+		if a.Action == ActionConditions || a.Action == ActionEnvironment {
+			patches = append(patches, i)
+		}
+	}
+	if len(v.errs) > 0 {
+		return
+	}
+	sort.SliceStable(patches, func(i, j int) bool {
+		return c.Protocol[patches[i]].Turn < c.Protocol[patches[j]].Turn
+	})
+	patched := c
+	for _, i := range patches {
+		var err error
+		patched, err = patched.patched(c.Protocol[i])
+		v.check(err == nil, fmt.Sprintf("abm-protocol[%d].patch", i), string(c.Protocol[i].Patch), fmt.Sprintf("applicable (%v)", err))
+		patched.validateFields(v, fmt.Sprintf("abm-protocol[%d].patch.", i))
+		if len(v.errs) > 0 {
+			return //	later patches would only repeat the errors.
+		}
+	}
+}
+
+// patched gives the conditions after a conditions or environment patch,
+// leaving c (including the arrays its slices share) unchanged.
+func (c ConditionParams) patched(a ProtocolAction) (ConditionParams, error) {
+	var p ConditionParams
+	raw, err := json.Marshal(c) //	a deep copy, as a checkpoint would make.
+	if err == nil {
+		err = json.Unmarshal(raw, &p)
+	}
+	if err != nil {
+		return c, err
+	}
+	switch a.Action {
+	case ActionConditions:
+		err = json.Unmarshal(a.Patch, &p)
+	case ActionEnvironment:
+		err = json.Unmarshal(a.Patch, &p.Environment)
+	}
+	return p, err
+}
+
+// validatePatch checks the conditions a conditions or environment patch would leave c with.
+func (c ConditionParams) validatePatch(a ProtocolAction) error {
+	p, err := c.patched(a)
+	if err != nil {
+		return err
+	}
+	v := &validator{}
+	p.validateFields(v, "")
+	return v.err()
+}
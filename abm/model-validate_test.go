@@ -0,0 +1,96 @@
+package abm
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for name, c := range map[string]ConditionParams{"default": DefaultConditionParams, "test": TestConditionParams} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s conditions: %v", name, err)
+		}
+	}
+
+	c := TestConditionParams
+	c.Bounds = []float64{1, 0}
+	c.CpPreyAgeing = true
+	c.CpPreyLifespan = 0
+	c.CpPreySpawnSize = -1
+	c.CpPreyPopulationCap = c.CpPreyPopulationStart - 1
+	c.VpAttackChance = 1.5
+	c.VpColourMetric = "hsv"
+	c.SessionIdentifier = "../../x"
+	c.Protocol = []ProtocolAction{{Turn: 5, Action: ActionAddVp}}
+	err := c.Validate()
+	invalid, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("Validate() == %v, want a ValidationError", err)
+	}
+	var keys []string
+	for _, fe := range invalid {
+		keys = append(keys, fe.Key)
+	}
+	sort.Strings(keys)
+	want := []string{
+		"abm-cp-prey-lifespan",
+		"abm-cp-prey-pop-cap",
+		"abm-cp-prey-spawn-size",
+		"abm-environment.abm-environment-bounds",
+		"abm-protocol[0].count",
+		"abm-session-identifier",
+		"abm-vp-attack-chance",
+		"abm-vp-colour-metric",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("invalid keys %q, want %q", keys, want)
+	}
+}
+
+func TestValidateProtocolPatches(t *testing.T) {
+	c := TestConditionParams
+	c.Protocol = []ProtocolAction{
+		{Turn: 20, Action: ActionConditions, Patch: json.RawMessage(`{"abm-vp-attack-chance": 2}`)},
+		{Turn: 10, Action: ActionConditions, Patch: json.RawMessage(`{"abm-cp-prey-ageing": true, "abm-cp-prey-lifespan": 0}`)},
+	}
+	invalid, ok := c.Validate().(ValidationError)
+	if !ok || len(invalid) != 1 || invalid[0].Key != "abm-protocol[1].patch.abm-cp-prey-lifespan" {
+		t.Errorf("Validate() == %v, want only the earlier patch rejected", invalid)
+	}
+	if c.CpPreyAgeing || c.VpAttackChance != TestConditionParams.VpAttackChance {
+		t.Error("Validate() changed the conditions")
+	}
+
+	m := NewModel()
+	m.ConditionParams = TestConditionParams
+	m.CpPreyAgeing = true
+	m.RandomAges = true
+	m.CpPreyLifespan = 0
+	if _, err := m.RunBatch(); err == nil {
+		t.Error("RunBatch() started with invalid conditions")
+	}
+}
+
+func TestValidateDimensionality(t *testing.T) {
+	for _, tc := range []struct {
+		bounds []float64
+		d      int
+		ok     bool
+	}{
+		{[]float64{1, 1}, 2, true},
+		{[]float64{1}, 1, false}, //	the engine is 2-D only
+		{[]float64{1, 1, 1}, 3, false},
+		{[]float64{1, 1}, 3, false},
+		{nil, 0, false},
+	} {
+		c := TestConditionParams
+		c.Bounds = tc.bounds
+		c.Dimensionality = tc.d
+		invalid, _ := c.Validate().(ValidationError)
+		if ok := len(invalid) == 0; ok != tc.ok {
+			t.Errorf("bounds %v, dimensionality %d: Validate() == %v", tc.bounds, tc.d, invalid)
+		}
+	}
+}
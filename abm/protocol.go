@@ -0,0 +1,256 @@
+package abm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// Actions of a ProtocolAction.
+const (
+	ActionConditions   = "conditions"     //	change ConditionParams by a JSON patch, as Model.Update
+	ActionEnvironment  = "environment"    //	change the background colour and/or substrate by a JSON patch of Environment
+	ActionAddCpPrey    = "add-cp-prey"    //	introduce Count new CP Prey, of Colour if given
+	ActionAddVp        = "add-vp"         //	introduce Count new Visual Predators
+	ActionRemoveCpPrey = "remove-cp-prey" //	remove Count CP Prey at random (0 = all)
+	ActionRemoveVp     = "remove-vp"      //	remove Count Visual Predators at random (0 = all)
+)
+
+// environmentPatchable are the Environment fields a protocol can change while the model runs.
+var environmentPatchable = map[string]bool{
+	"abm-environment-background": true,
+	"abm-environment-substrate":  true,
+}
+
+/*
+ProtocolAction is a change to a running model, made at the beginning of
+Turn. An experimental protocol lists them in ConditionParams.Protocol, e.g.
+
+	{"turn": 500, "action": "remove-vp"}
+	{"turn": 1000, "action": "add-cp-prey", "count": 50, "colour": {"red": 1, "green": 0, "blue": 0}}
+	{"turn": 2000, "action": "environment", "patch": {"abm-environment-background": {"red": 0.2, "green": 0.5, "blue": 0.2}}}
+	{"turn": 2500, "action": "conditions", "patch": {"abm-vp-attack-chance": 0.5}}
+
+Actions for the same turn are made in the order listed.
+*/
+type ProtocolAction struct {
+	Turn   int             `json:"turn"`
+	Action string          `json:"action"`
+	Patch  json.RawMessage `json:"patch,omitempty"`  //	for ActionConditions and ActionEnvironment
+	Count  int             `json:"count,omitempty"`  //	number of agents added or removed
+	Colour *colour.RGB     `json:"colour,omitempty"` //	of the CP Prey added; random when absent
+}
+
+// check verifies the action can be made, whatever the state of the model,
+// keying any FieldErrors by prefix.
+func (a ProtocolAction) check(v *validator, prefix string) {
+	v.nonNegative(prefix+"turn", a.Turn)
+	v.nonNegative(prefix+"count", a.Count)
+	if a.Colour != nil {
+		v.check(a.Action == ActionAddCpPrey, prefix+"colour", *a.Colour, "absent unless the action is "+ActionAddCpPrey)
+		v.colour(prefix+"colour", *a.Colour)
+	}
+	switch a.Action {
+	case ActionConditions:
+		err := checkPatch(a.Patch)
+		v.check(err == nil, prefix+"patch", string(a.Patch), fmt.Sprintf("a valid conditions patch (%v)", err))
+	case ActionEnvironment:
+		err := checkEnvironmentPatch(a.Patch)
+		v.check(err == nil, prefix+"patch", string(a.Patch), fmt.Sprintf("a valid environment patch (%v)", err))
+	case ActionAddCpPrey, ActionAddVp:
+		v.check(a.Count > 0, prefix+"count", a.Count, "≥ 1 to add agents")
+	case ActionRemoveCpPrey, ActionRemoveVp:
+	default:
+		v.check(false, prefix+"action", a.Action, fmt.Sprintf("one of %q, %q, %q, %q, %q or %q",
+			ActionConditions, ActionEnvironment, ActionAddCpPrey, ActionAddVp, ActionRemoveCpPrey, ActionRemoveVp))
+	}
+}
+
+// checkEnvironmentPatch verifies that patch is a JSON object of the environmentPatchable fields.
+func checkEnvironmentPatch(patch json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(patch, &fields)
+	if err != nil {
+		return fmt.Errorf("environment patch: %s", err)
+	}
+	var fixed []string
+	for key := range fields {
+		if !environmentPatchable[key] {
+			fixed = append(fixed, key)
+		}
+	}
+	if len(fixed) > 0 {
+		sort.Strings(fixed)
+		return fmt.Errorf("environment patch: can't be changed while the model runs: %q", fixed)
+	}
+	var env Environment
+	err = json.Unmarshal(patch, &env)
+	if err != nil {
+		return fmt.Errorf("environment patch: %s", err)
+	}
+	return nil
+}
+
+// checkProtocol verifies every action of a protocol on its own, giving a
+// ValidationError keyed as in ConditionParams.
+func checkProtocol(protocol []ProtocolAction) error {
+	v := &validator{}
+	for i, a := range protocol {
+		a.check(v, fmt.Sprintf("abm-protocol[%d].", i))
+	}
+	return v.err()
+}
+
+// LoadProtocol reads an experimental protocol: a JSON object listing its
+// actions, {"actions": [...]} (see ProtocolAction). The actions are checked
+// on their own; ConditionParams.Validate checks them against the conditions.
+func LoadProtocol(r io.Reader) ([]ProtocolAction, error) {
+	var protocol struct {
+		Actions []ProtocolAction `json:"actions"`
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&protocol)
+	if err != nil {
+		return nil, fmt.Errorf("protocol: %s", err)
+	}
+	return protocol.Actions, checkProtocol(protocol.Actions)
+}
+
+// applyProtocol makes the actions of the protocol for the current turn, in
+// order, each independently of the others: every one that fails is reported
+// on errCh. It must only be called between turns. Like applyUpdates, it holds updateMu.
+func (m *Model) applyProtocol(errCh chan<- error) {
+	defer m.updateMu.Unlock()
+	m.updateMu.Lock()
+	for _, a := range m.Protocol {
+		if a.Turn != m.Turn {
+			continue
+		}
+		if err := m.intervene(Intervention{Source: "protocol", ProtocolAction: a}); err != nil {
+			errCh <- err
+		}
+	}
+}
+
+// intervene makes the change to the model, and records it.
+// It must only be called between turns.
+func (m *Model) intervene(u Intervention) error {
+	u.Turn = m.Turn
+	timestamp := fmt.Sprintf("%s", time.Now())
+	if u.Action == ActionConditions || u.Action == ActionEnvironment {
+		err := m.ConditionParams.validatePatch(u.ProtocolAction)
+		if err != nil {
+			return err //	rejected whole, leaving the model as it is.
+		}
+	}
+	switch u.Action {
+	case ActionConditions:
+		// only the fields in the patch are written, leaving alone those
+		// fixedConditions the LOG and VIS processes read as they run. The
+		// patch has already been decoded onto a copy by validatePatch, so
+		// it doesn't fail part way through.
+		if err := json.Unmarshal(u.Patch, &m.ConditionParams); err != nil {
+			return fmt.Errorf("Model: %s intervention failed: %s", u.Action, err)
+		}
+	case ActionEnvironment:
+		err := m.changeEnvironment(u.Patch)
+		if err != nil {
+			return fmt.Errorf("Model: %s intervention failed: %s", u.Action, err)
+		}
+	case ActionAddCpPrey:
+		prey := GenerateCpPreyPopulation(u.Count, m.numCpPreyCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
+		m.numCpPreyCreated += u.Count
+		for i := range prey {
+			if u.Colour != nil {
+				prey[i].colouration = *u.Colour
+			}
+			m.lineage.cpPreyBorn(prey[i], m.Turn)
+		}
+		m.popCpPrey = append(m.popCpPrey, prey...)
+	case ActionAddVp:
+		vps := GenerateVPredatorPopulation(u.Count, m.numVpCreated, m.Turn, m.ConditionParams, timestamp, m.rng)
+		m.numVpCreated += u.Count
+		for i := range vps {
+			m.lineage.vpBorn(vps[i], m.Turn)
+		}
+		m.popVisualPredator = append(m.popVisualPredator, vps...)
+	case ActionRemoveCpPrey:
+		var alive []int //	those eaten last turn are already dead.
+		for i := range m.popCpPrey {
+			if !m.popCpPrey[i].eaten() {
+				alive = append(alive, i)
+			}
+		}
+		removed := m.chooseRemoved(alive, u.Count)
+		var remaining []ColourPolymorphicPrey
+		for i := range m.popCpPrey {
+			if removed[i] {
+				m.died(cpPreyDeath(m.popCpPrey[i], m.Turn, CauseRemoved))
+				continue
+			}
+			remaining = append(remaining, m.popCpPrey[i])
+		}
+		m.popCpPrey = remaining
+	case ActionRemoveVp:
+		all := make([]int, len(m.popVisualPredator))
+		for i := range all {
+			all[i] = i
+		}
+		removed := m.chooseRemoved(all, u.Count)
+		var remaining []VisualPredator
+		for i := range m.popVisualPredator {
+			if removed[i] {
+				m.died(vpDeath(m.popVisualPredator[i], m.Turn, CauseRemoved))
+				continue
+			}
+			remaining = append(remaining, m.popVisualPredator[i])
+		}
+		m.popVisualPredator = remaining
+	}
+	m.intervened(u)
+	return nil
+}
+
+// chooseRemoved picks count of the candidate indices at random (all of them if count is 0).
+func (m *Model) chooseRemoved(candidates []int, count int) map[int]bool {
+	removed := make(map[int]bool)
+	if count == 0 || count >= len(candidates) {
+		for _, i := range candidates {
+			removed[i] = true
+		}
+		return removed
+	}
+	for _, j := range m.rng.Perm(len(candidates))[:count] {
+		removed[candidates[j]] = true
+	}
+	return removed
+}
+
+// changeEnvironment applies an environment patch, recreating the substrate
+// when it changes, and hands the new background to the VIS process.
+func (m *Model) changeEnvironment(patch json.RawMessage) error {
+	env := m.ConditionParams.Environment
+	err := json.Unmarshal(patch, &env)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(patch, &fields)
+	if _, ok := fields["abm-environment-substrate"]; ok {
+		env.Substrate, err = NewSubstrate(env.SubstrateSpec)
+		if err != nil {
+			return err
+		}
+	}
+	m.ConditionParams.Environment = env
+	m.Environment = env
+	if m.Visualise {
+		return m.environmentPublish()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package abm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+func TestLoadProtocol(t *testing.T) {
+	for protocol, valid := range map[string]bool{
+		`{"actions": [{"turn": 10, "action": "add-cp-prey", "count": 5, "colour": {"red": 1, "green": 0, "blue": 0}}]}`:                  true,
+		`{"actions": [{"turn": 10, "action": "remove-vp"}, {"turn": 10, "action": "conditions", "patch": {"abm-vp-attack-chance": 0}}]}`: true,
+		`{"actions": [{"turn": 10, "action": "environment", "patch": {"abm-environment-substrate": {"type": "patches"}}}]}`:              true,
+		`{"actions": [{"turn": 10, "action": "add-vp"}]}`:                                                                                false,
+		`{"actions": [{"turn": -1, "action": "remove-vp"}]}`:                                                                             false,
+		`{"actions": [{"turn": 10, "action": "remove-vp", "colour": {"red": 1, "green": 0, "blue": 0}}]}`:                                false,
+		`{"actions": [{"turn": 10, "action": "add-cp-prey", "count": 5, "colour": {"red": 2, "green": 0, "blue": 0}}]}`:                  false,
+		`{"actions": [{"turn": 10, "action": "conditions", "patch": {"abm-cp-prey-pop-start": 10}}]}`:                                    false,
+		`{"actions": [{"turn": 10, "action": "environment", "patch": {"abm-environment-bounds": [1, 1]}}]}`:                              false,
+		`{"actions": [{"turn": 10, "action": "flood"}]}`:                                                                                 false,
+		`{"actions": [], "repeat": true}`: false,
+	} {
+		if _, err := LoadProtocol(strings.NewReader(protocol)); (err == nil) != valid {
+			t.Errorf("LoadProtocol(%s) == %v", protocol, err)
+		}
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	red := colour.RGB{Red: 1}
+	green := colour.RGB{Green: 1}
+	m := testModel(t, 8)
+	m.Protocol = []ProtocolAction{
+		{Turn: 2, Action: ActionAddCpPrey, Count: 10, Colour: &red},
+		{Turn: 3, Action: ActionEnvironment, Patch: json.RawMessage(`{"abm-environment-background": {"red": 0, "green": 1, "blue": 0}}`)},
+		{Turn: 4, Action: ActionRemoveVp, Count: 1},
+		{Turn: 5, Action: ActionRemoveCpPrey, Count: 5},
+	}
+	runBatch(t, m)
+	if m.BG != green || m.ConditionParams.BG != green {
+		t.Errorf("background %v, want %v", m.BG, green)
+	}
+	removed := map[string]int{}
+	for _, row := range readLog(t, m, deathsFile)[1:] {
+		if row[3] == CauseRemoved {
+			removed[row[1]]++
+			if row[0] != "4" && row[0] != "5" {
+				t.Errorf("%s removed on turn %s", row[1], row[0])
+			}
+		}
+	}
+	if removed["vp"] != 1 || removed["cpPrey"] != 5 {
+		t.Errorf("removed %v, want 1 vp and 5 cpPrey", removed)
+	}
+
+	interventions := readInterventions(t, m)
+	if len(interventions) != len(m.Protocol) {
+		t.Fatalf("%d interventions logged, want %d", len(interventions), len(m.Protocol))
+	}
+	for i, u := range interventions {
+		if u.Source != "protocol" || u.Turn != m.Protocol[i].Turn || u.Action != m.Protocol[i].Action {
+			t.Errorf("intervention %d: %+v, want %+v", i, u, m.Protocol[i])
+		}
+	}
+}
+
+func TestProtocolFailure(t *testing.T) {
+	m := testModel(t, 4)
+	m.Protocol = []ProtocolAction{
+		{Turn: 2, Action: ActionEnvironment, Patch: json.RawMessage(`{"abm-environment-substrate": {"type": "file", "file": "no-such-substrate.json"}}`)},
+		{Turn: 2, Action: ActionAddVp, Count: 3},
+	}
+	summary, err := m.RunBatch()
+	if err == nil {
+		t.Error("RunBatch() == nil, with a substrate that can't be loaded")
+	}
+	if want := m.VpPopulationStart + 3; summary.VpCreated != want {
+		t.Errorf("%d vp created, want %d: the action after the failure was skipped", summary.VpCreated, want)
+	}
+	if interventions := readInterventions(t, m); len(interventions) != 1 || interventions[0].Action != ActionAddVp {
+		t.Errorf("interventions logged %+v, want only the %s", interventions, ActionAddVp)
+	}
+}
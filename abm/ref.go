@@ -1,6 +1,9 @@
 package abm
 
-import "github.com/benjamin-rood/abm-cp/colour"
+import (
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
+)
 
 // holds baseline parameters for a running model.
 
@@ -112,6 +115,7 @@ var (
 	// DefaultEnvironment to be used as a baseline example
 	DefaultEnvironment = Environment{
 		Bounds:         []float64{d, d},
+		Boundary:       geometry.Wrap,
 		Dimensionality: dimensionality,
 		BG:             DefaultBG,
 	}
@@ -154,6 +158,7 @@ var (
 		RNGRandomSeed:            dRNGRandomSeed,
 		RNGSeedVal:               dRNGSeedVal,
 		Fuzzy:                    dFuzzy,
+		SessionIdentifier:        dSessionIdentifier,
 	}
 
 	// TestConditionParams to be used for unit testing.
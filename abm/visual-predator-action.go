@@ -2,12 +2,18 @@ package abm
 
 import (
 	"log"
+	"math/rand"
 
 	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/geometry"
 )
 
 // Action : Rule-Based-Behaviour for Visual Predator Agent
-func (vp *VisualPredator) Action(errCh chan<- error, conditions ConditionParams, start int, turn int, cpPreyPop []ColourPolymorphicPrey, neighbours []VisualPredator, me int) []VisualPredator {
+func (vp *VisualPredator) Action(errCh chan<- error, conditions ConditionParams, start int, turn int, cpPreyPop []ColourPolymorphicPrey, neighbours []VisualPredator, me int, index *SpatialIndex, rng *rand.Rand) []VisualPredator {
+	var preyIndex, vpIndex *geometry.Grid
+	if index != nil {
+		preyIndex, vpIndex = index.CpPrey, index.Vp
+	}
 	var returning []VisualPredator
 	var Φ float64
 	popSize := len(neighbours)
@@ -16,7 +22,7 @@ func (vp *VisualPredator) Action(errCh chan<- error, conditions ConditionParams,
 	case "DEATH":
 		goto End
 	case "PREY SEARCH":
-		success := vp.SearchAndAttack(cpPreyPop, conditions, errCh)
+		success := vp.SearchAndAttack(cpPreyPop, conditions, errCh, preyIndex, rng)
 		if success {
 			goto Add
 		}
@@ -25,21 +31,21 @@ func (vp *VisualPredator) Action(errCh chan<- error, conditions ConditionParams,
 		if len(neighbours) <= 0 {
 			goto Patrol
 		}
-		mate := vp.MateSearch(neighbours, me, errCh)
-		success := vp.Copulation(mate, conditions)
+		mate := vp.MateSearch(neighbours, me, conditions.Environment, errCh, vpIndex)
+		success := vp.Copulation(mate, conditions, rng)
 		if !success {
 			goto Patrol
 		}
 	case "SPAWN":
-		children := vp.Birth(conditions, start, turn)
+		children := vp.Birth(conditions, start, turn, rng)
 		returning = append(returning, children...)
 	default:
 		log.Println("vp.Action Switch: FAIL: jump =", jump)
 	}
 Patrol:
-	Φ = calc.RandFloatIn(-vp.tr, vp.tr)
+	Φ = calc.RandFloatIn(-vp.tr, vp.tr, rng)
 	vp.Turn(Φ)
-	vp.Move()
+	vp.Move(conditions.Environment)
 Add:
 	returning = append(returning, *vp)
 End:
@@ -47,17 +53,17 @@ End:
 }
 
 // SearchAndAttack gathers the logic for these steps of the VP Action
-func (vp *VisualPredator) SearchAndAttack(prey []ColourPolymorphicPrey, conditions ConditionParams, errCh chan<- error) bool {
+func (vp *VisualPredator) SearchAndAttack(prey []ColourPolymorphicPrey, conditions ConditionParams, errCh chan<- error, index *geometry.Grid, rng *rand.Rand) bool {
 	var attacking bool
 	var err error
-	target, err := vp.PreySearch(prey) //	will move towards any viable prey it can see.
+	target, err := vp.PreySearch(prey, conditions, index) //	will move towards any viable prey it can see.
 	errCh <- err
 	if target != nil {
-		attacking, err = vp.Intercept(target.pos)
+		attacking, err = vp.Intercept(target.pos, conditions.Environment)
 		errCh <- err
 	}
 	if attacking {
-		vp.Attack(target, conditions)
+		vp.Attack(target, conditions, rng)
 		return true
 	}
 	return false
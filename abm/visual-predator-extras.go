@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/rand"
 
+	"github.com/benjamin-rood/abm-cp/calc"
 	"github.com/benjamin-rood/abm-cp/colour"
 	"github.com/benjamin-rood/abm-cp/geometry"
 	"github.com/benjamin-rood/abm-cp/render"
@@ -32,7 +34,9 @@ func (vp VisualPredator) MarshalJSON() ([]byte, error) {
 		"𝛄":                       vp.𝛄,
 		"gravid":                  vp.gravid,
 		"colour-target-value":     vp.τ,
+		"colour-target-uv":        vp.τuv,
 		"colour-imprint-strength": vp.ετ,
+		"visual-system":           vp.vision,
 	})
 }
 
@@ -80,13 +84,15 @@ func (vp *VisualPredator) String() string {
 	buffer.WriteString(fmt.Sprintf("fertility=%v\n", vp.fertility))
 	buffer.WriteString(fmt.Sprintf("gravid=%v\n", vp.gravid))
 	buffer.WriteString(fmt.Sprintf("τ=%v\n", vp.τ))
+	buffer.WriteString(fmt.Sprintf("τuv=%v\n", vp.τuv))
 	buffer.WriteString(fmt.Sprintf("ετ=%v\n", vp.ετ))
 	buffer.WriteString(fmt.Sprintf("𝛄=%v\n", vp.𝛄))
+	buffer.WriteString(fmt.Sprintf("vision=%v\n", vp.vision))
 	return buffer.String()
 }
 
 func vpTesterAgent(xPos float64, yPos float64) (tester VisualPredator) {
-	tester = vpTestPop(1)[0]
+	tester = vpTestPop(1, calc.NewRNG(tRNGSeedVal))[0]
 	tester.pos[x] = xPos
 	tester.pos[y] = yPos
 	return
@@ -94,8 +100,15 @@ func vpTesterAgent(xPos float64, yPos float64) (tester VisualPredator) {
 
 // colourImprinting updates VP colour / visual recognition bias
 // Uses a bias / weighting value, 𝜎 (sigma) to control the degree of
-// adaptation VP will make to differences in 'eaten' CP Prey  colours.
-func (vp *VisualPredator) colourImprinting(target colour.RGB, 𝜎 float64) {
+// adaptation VP will make to differences in 'eaten' CP Prey  colours
+// (and UV reflectance, targetUV).
+// With a perceptual colour metric, the adaptation is made in CIELAB.
+func (vp *VisualPredator) colourImprinting(target colour.RGB, targetUV float64, 𝜎 float64, metric string) {
+	vp.τuv = vp.τuv - ((vp.τuv - targetUV) * 𝜎)
+	if colour.IsPerceptual(metric) {
+		vp.τ = colour.LabLerp(vp.τ, target, 𝜎)
+		return
+	}
 	𝚫red := (vp.τ.Red - target.Red) * 𝜎
 	𝚫green := (vp.τ.Green - target.Green) * 𝜎
 	𝚫blue := (vp.τ.Blue - target.Blue) * 𝜎
@@ -104,8 +117,48 @@ func (vp *VisualPredator) colourImprinting(target colour.RGB, 𝜎 float64) {
 	vp.τ.Blue = vp.τ.Blue - 𝚫blue
 }
 
-func vpTestPop(size int) []VisualPredator {
-	return GenerateVPredatorPopulation(size, 0, 0, TestConditionParams, testStamp)
+// colourMetric gives the Metric predators use to judge colour difference.
+// Unknown metric names fall back to colour.RGBDistance.
+func (conditions ConditionParams) colourMetric() colour.Metric {
+	metric, _ := colour.MetricNamed(conditions.VpColourMetric)
+	return metric
+}
+
+// visualSystem finds the named visual system, preferring the custom
+// definitions in conditions over the built-in colour.VisualSystems.
+func (conditions ConditionParams) visualSystem(name string) (colour.VisualSystem, bool) {
+	if name == "" {
+		return colour.VisualSystem{}, false
+	}
+	for _, vs := range conditions.VpVisualSystemDefs {
+		if vs.Name == name {
+			return vs, true
+		}
+	}
+	vs, err := colour.VisualSystemNamed(name)
+	return vs, err == nil
+}
+
+// perception judges the difference between two colourations, each with a UV reflectance.
+type perception func(c1 colour.RGB, uv1 float64, c2 colour.RGB, uv2 float64) float64
+
+// perception gives how vp perceives colour differences: through its visual
+// system if it has one, otherwise by the colour metric set in conditions
+// (which is blind to UV).
+func (vp *VisualPredator) perception(conditions ConditionParams) perception {
+	if vs, ok := conditions.visualSystem(vp.vision); ok {
+		return func(c1 colour.RGB, uv1 float64, c2 colour.RGB, uv2 float64) float64 {
+			return vs.Difference(c1.Reflectance(uv1), c2.Reflectance(uv2))
+		}
+	}
+	metric := conditions.colourMetric()
+	return func(c1 colour.RGB, _ float64, c2 colour.RGB, _ float64) float64 {
+		return metric(c1, c2)
+	}
+}
+
+func vpTestPop(size int, rng *rand.Rand) []VisualPredator {
+	return GenerateVPredatorPopulation(size, 0, 0, TestConditionParams, testStamp, rng)
 }
 
 // VSRSectorSampling checks which sectors the VP agent's
@@ -31,20 +31,22 @@ type VisualPredator struct {
 	vsr           float64          //	visual search range
 	𝛄             float64          // search target / colour variation tolerance
 	τ             colour.RGB       //	imprinted target / colour specialisation value
+	τuv           float64          //	imprinted target UV reflectance
 	ετ            float64          //	imprinting / colour specialisation strength
+	vision        string           //	name of the visual system colour is seen through ("" for ConditionParams.VpColourMetric)
 }
 
 // GenerateVPredatorPopulation will create `size` number of Visual Predator agents
-func GenerateVPredatorPopulation(size int, start int, mt int, conditions ConditionParams, timestamp string) []VisualPredator {
+func GenerateVPredatorPopulation(size int, start int, mt int, conditions ConditionParams, timestamp string, rng *rand.Rand) []VisualPredator {
 	pop := []VisualPredator{}
 	for i := 0; i < size; i++ {
 		agent := VisualPredator{}
-		agent.uuid = uuid()
+		agent.uuid = uuid(rng)
 		agent.description = AgentDescription{AgentType: "vp", AgentNum: start + i, ParentUUID: "", CreatedMT: mt, CreatedAT: timestamp}
-		agent.pos = geometry.RandVector(conditions.Bounds)
+		agent.pos = conditions.RandPosition(rng)
 		if conditions.VpAgeing {
 			if conditions.RandomAges {
-				agent.lifespan = calc.RandIntIn(int(float64(conditions.VpLifespan)*0.7), int(float64(conditions.VpLifespan)*1.3))
+				agent.lifespan = calc.RandIntIn(int(float64(conditions.VpLifespan)*0.7), int(float64(conditions.VpLifespan)*1.3), rng)
 			} else {
 				agent.lifespan = conditions.VpLifespan
 			}
@@ -53,7 +55,7 @@ func GenerateVPredatorPopulation(size int, start int, mt int, conditions Conditi
 		}
 		agent.movS = conditions.VpMovS
 		agent.movA = conditions.VpMovA
-		agent.𝚯 = rand.Float64() * (2 * math.Pi)
+		agent.𝚯 = rng.Float64() * (2 * math.Pi)
 		agent.dir = geometry.UnitVector(agent.𝚯)
 		agent.tr = conditions.VpTurn
 		agent.vsr = conditions.VpVsr
@@ -61,23 +63,29 @@ func GenerateVPredatorPopulation(size int, start int, mt int, conditions Conditi
 		agent.hunger = conditions.VpSexualRequirement + 1
 		agent.fertility = 1
 		agent.gravid = false
-		agent.τ = colour.RandRGB()
+		agent.τ = colour.RandRGB(rng)
+		if conditions.CpPreyUV {
+			agent.τuv = rng.Float64()
+		}
 		agent.ετ = conditions.VpVbε
+		if len(conditions.VpVisualSystems) > 0 {
+			agent.vision = conditions.VpVisualSystems[(start+i)%len(conditions.VpVisualSystems)]
+		}
 		pop = append(pop, agent)
 	}
 	return pop
 }
 
-func vpSpawn(size int, start int, mt int, parent VisualPredator, conditions ConditionParams, timestamp string) []VisualPredator {
+func vpSpawn(size int, start int, mt int, parent VisualPredator, conditions ConditionParams, timestamp string, rng *rand.Rand) []VisualPredator {
 	pop := []VisualPredator{}
 	for i := 0; i < size; i++ {
 		agent := parent
-		agent.uuid = uuid()
+		agent.uuid = uuid(rng)
 		agent.description = AgentDescription{AgentType: "vp", AgentNum: start + i, ParentUUID: parent.uuid, CreatedMT: mt, CreatedAT: timestamp}
 		agent.pos = parent.pos
 		if conditions.VpAgeing {
 			if conditions.RandomAges {
-				agent.lifespan = calc.RandIntIn(int(float64(conditions.VpLifespan)*0.7), int(float64(conditions.VpLifespan)*1.3))
+				agent.lifespan = calc.RandIntIn(int(float64(conditions.VpLifespan)*0.7), int(float64(conditions.VpLifespan)*1.3), rng)
 			} else {
 				agent.lifespan = conditions.VpLifespan
 			}
@@ -86,15 +94,17 @@ func vpSpawn(size int, start int, mt int, parent VisualPredator, conditions Cond
 		}
 		agent.movS = parent.movS
 		agent.movA = parent.movA
-		agent.𝚯 = rand.Float64() * (2 * math.Pi)
+		agent.𝚯 = rng.Float64() * (2 * math.Pi)
 		agent.dir = parent.dir
 		agent.tr = parent.tr
 		agent.vsr = parent.vsr
 		agent.hunger = conditions.VpSexualRequirement + 1
 		agent.fertility = 1
 		agent.gravid = false
-		agent.τ = colour.RandRGBClamped(parent.τ, 0.5) //	random offset (up to 50%) deviation from parent's target colour
+		agent.τ = colour.RandRGBClamped(parent.τ, 0.5, rng) //	random offset (up to 50%) deviation from parent's target colour
+		agent.τuv = parent.τuv
 		agent.ετ = conditions.VpVbε
+		agent.vision = parent.vision //	visual systems are inherited
 		pop = append(pop, agent)
 	}
 	return pop
@@ -109,7 +119,8 @@ func (vp *VisualPredator) Turn(𝚯 float64) {
 }
 
 // Move updates the agent's position if it doesn't encounter any errors.
-func (vp *VisualPredator) Move() error {
+// The environment boundary mode decides what happens at its edges.
+func (vp *VisualPredator) Move(env Environment) error {
 	var posOffset, newPos geometry.Vector
 	var err error
 	posOffset, err = geometry.VecScalarMultiply(vp.dir, vp.movS*vp.movA)
@@ -120,36 +131,57 @@ func (vp *VisualPredator) Move() error {
 	if err != nil {
 		return errors.New("agent move failed: " + err.Error())
 	}
-	newPos[x] = calc.WrapFloatIn(newPos[x], -1.0, 1.0)
-	newPos[y] = calc.WrapFloatIn(newPos[y], -1.0, 1.0)
+	newPos, 𝚯 := env.Confine(newPos, vp.𝚯)
+	if 𝚯 != vp.𝚯 { //	bounced off the boundary.
+		vp.Turn(𝚯 - vp.𝚯)
+	}
 	vp.pos = newPos
 	return nil
 }
 
 // PreySearch – uses Visual Search to try to 'recognise' a nearby prey agent within model Environment to target
-func (vp *VisualPredator) PreySearch(prey []ColourPolymorphicPrey) (*ColourPolymorphicPrey, error) {
+// If index is non-nil, only the prey in its sectors within visual range are considered.
+// Prey which contrast little with the substrate beneath them are harder to detect,
+// to the extent given by conditions.VpCrypsisWeight (see crypsis).
+// Colours are compared through the predator's own visual system (see perception).
+func (vp *VisualPredator) PreySearch(prey []ColourPolymorphicPrey, conditions ConditionParams, index *geometry.Grid) (*ColourPolymorphicPrey, error) {
+	env := conditions.Environment
+	see := vp.perception(conditions)
 	c := vp.ετ
 	var 𝒇 = visualSignalStrength(c)
 	var 𝛘 float64 // colour sorting value - colour distance/difference between vp.imprimt and cpPrey.colouration
 	var δ float64 // position sorting value - vector distance between vp.pos and cpPrey.pos
 	var err error
 	var searchSet []visualRecognition
-	for i := range prey { //	exhaustive search 😱
-		δ, err = geometry.VectorDistance(vp.pos, prey[i].pos)
+	var candidates []int
+	if index != nil {
+		candidates = index.Within(vp.pos, vp.vsr)
+	} else {
+		candidates = make([]int, len(prey)) //	exhaustive search 😱
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+	for _, i := range candidates {
+		if prey[i].eaten() { //	by another predator earlier in the phase.
+			continue
+		}
+		δ, err = env.Distance(vp.pos, prey[i].pos)
 		if δ <= vp.vsr { // ∴ only include the prey agent for considertion if within visual range
-			𝛘 = colour.RGBDistance(vp.τ, prey[i].colouration)
+			𝛘 = see(vp.τ, vp.τuv, prey[i].colouration, prey[i].uv)
 			if 𝛘 < vp.𝛄 { // i.e. if and only if colour distance falls within predator's current search tolerance
-				a := visualRecognition{δ, 𝛘, 𝒇, c, &prey[i]}
+				κ := crypsis(prey[i].colouration, prey[i].uv, env.Background(prey[i].pos), conditions.VpCrypsisWeight, see)
+				a := visualRecognition{δ, 𝛘, 𝒇, c, 1 - κ, &prey[i]}
 				searchSet = append(searchSet, a)
 			}
 		}
 	}
 
-	sort.Sort(byOptimalAttackVector(searchSet)) //	sort by 𝒇(x) - distance
+	sort.Stable(byOptimalAttackVector(searchSet)) //	sort by 𝒇(x) - distance
 
 	// search within biased and reduced set
 	for i, p := range searchSet {
-		if 𝒇(p.𝛘) > (1 - vp.𝛄) { // i.e. is the colour detection strength sufficiently great
+		if p.strength() > (1 - vp.𝛄) { // i.e. is the colour detection strength sufficiently great
 			return &(*searchSet[i].ColourPolymorphicPrey), err
 		}
 	}
@@ -157,23 +189,24 @@ func (vp *VisualPredator) PreySearch(prey []ColourPolymorphicPrey) (*ColourPolym
 }
 
 // Attack VP agent attempts to attack CP prey agent
-func (vp *VisualPredator) Attack(prey *ColourPolymorphicPrey, conditions ConditionParams) bool {
+func (vp *VisualPredator) Attack(prey *ColourPolymorphicPrey, conditions ConditionParams, rng *rand.Rand) bool {
 	if prey == nil {
 		return false
 	}
-	α := rand.Float64()
+	α := rng.Float64()
 	if α > (1 - conditions.VpAttackChance) {
 		vp.attackSuccess = true
-		vp.colourImprinting(prey.colouration, conditions.VpCaf)
+		vp.colourImprinting(prey.colouration, prey.uv, conditions.VpCaf, conditions.VpColourMetric)
 		c := vp.ετ
 		𝒇 := visualSignalStrength(c)
-		𝛘 := colour.RGBDistance(vp.τ, prey.colouration)
+		𝛘 := vp.perception(conditions)(vp.τ, vp.τuv, prey.colouration, prey.uv)
 		Vg := 𝒇(𝛘) * conditions.VpBaseAttackGain
 		vp.hunger -= int(Vg)
 		if vp.hunger < 0 {
 			vp.hunger = 0
 		}
 		prey.lifespan = 0 //	i.e. prey agent is flagged for removal at the beginning of next turn and will not be drawn again.
+		prey.predator = vp.uuid
 		if conditions.VpVmε > vp.ετ {
 			vp.ετ++
 		}
@@ -191,10 +224,11 @@ func (vp *VisualPredator) Attack(prey *ColourPolymorphicPrey, conditions Conditi
 	return vp.attackSuccess
 }
 
-// Intercept attempts to turn and move towards target position (as much as vp is able)
-func (vp *VisualPredator) Intercept(target geometry.Vector) (bool, error) {
-	dist, _ := geometry.VectorDistance(vp.pos, target)
-	Ψ, err := geometry.AngleToIntercept(vp.pos, vp.𝚯, target)
+// Intercept attempts to turn and move towards target position (as much as vp is able),
+// taking the shortest path within the environment.
+func (vp *VisualPredator) Intercept(target geometry.Vector, env Environment) (bool, error) {
+	dist, _ := env.Distance(vp.pos, target)
+	Ψ, err := env.AngleToIntercept(vp.pos, vp.𝚯, target)
 	if dist < vp.movS {
 		vp.pos = target
 		vp.Turn(calc.ClampFloatIn(Ψ, -vp.tr, vp.tr))
@@ -202,20 +236,34 @@ func (vp *VisualPredator) Intercept(target geometry.Vector) (bool, error) {
 	}
 	vp.Turn(calc.ClampFloatIn(Ψ, -vp.tr, vp.tr))
 	// vp.Turn(Ψ)
-	vp.Move()
+	vp.Move(env)
 	return false, err
 }
 
-// MateSearch searches species population for sexual coupling
-func (vp *VisualPredator) MateSearch(neighbours []VisualPredator, me int, errCh chan<- error) *VisualPredator {
+// MateSearch searches species population for sexual coupling.
+// If index is non-nil it is used to find the nearest neighbour directly.
+func (vp *VisualPredator) MateSearch(neighbours []VisualPredator, me int, env Environment, errCh chan<- error, index *geometry.Grid) *VisualPredator {
 	if len(neighbours) == 0 {
 		return nil
 	}
 
+	if index != nil {
+		nearest, found := index.Nearest(vp.pos, me)
+		if !found {
+			return nil
+		}
+		inRange, err := vp.Intercept(neighbours[nearest].pos, env)
+		errCh <- err
+		if inRange {
+			return &neighbours[nearest]
+		}
+		return nil
+	}
+
 	var searchSet []proxVP
 	f := func(u geometry.Vector, errCh chan<- error) func(geometry.Vector) float64 {
 		return func(v geometry.Vector) float64 {
-			δ, err := geometry.VectorDistance(u, v)
+			δ, err := env.Distance(u, v)
 			errCh <- err
 			return δ
 		}
@@ -232,11 +280,11 @@ func (vp *VisualPredator) MateSearch(neighbours []VisualPredator, me int, errCh
 		return nil
 	}
 
-	sort.Sort(byProximityVp(searchSet))
+	sort.Stable(byProximityVp(searchSet)) //	ties go to the lowest index, as with an indexed search.
 
 	target := searchSet[0].pos // guaranteed to exist by test on test of searchSet length above
 
-	inRange, err := vp.Intercept(target)
+	inRange, err := vp.Intercept(target, env)
 	errCh <- err
 	if inRange {
 		return searchSet[0].VisualPredator
@@ -284,14 +332,14 @@ func (vp *VisualPredator) jump(conditions ConditionParams, popSize int) (jump st
 }
 
 // Copulation for sexual reproduction between Visual Predator agents
-func (vp *VisualPredator) Copulation(mate *VisualPredator, conditions ConditionParams) bool {
+func (vp *VisualPredator) Copulation(mate *VisualPredator, conditions ConditionParams, rng *rand.Rand) bool {
 	if mate == nil {
 		return false
 	}
 	if mate.fertility < conditions.VpSexualRequirement {
 		return false
 	}
-	ω := rand.Float64()
+	ω := rng.Float64()
 	mate.fertility = 1 // it takes two to tango, buddy!
 	if ω <= conditions.VpReproductionChance {
 		vp.gravid = true
@@ -303,14 +351,14 @@ func (vp *VisualPredator) Copulation(mate *VisualPredator, conditions ConditionP
 }
 
 // Birth spawns Visual Predator children
-func (vp *VisualPredator) Birth(conditions ConditionParams, start int, mt int) []VisualPredator {
+func (vp *VisualPredator) Birth(conditions ConditionParams, start int, mt int, rng *rand.Rand) []VisualPredator {
 	n := 1
 	if conditions.VpSpawnSize > 1 {
-		n = rand.Intn(conditions.VpSpawnSize) + 1
+		n = rng.Intn(conditions.VpSpawnSize) + 1
 	}
 
 	timestamp := fmt.Sprintf("%s", time.Now())
-	progeny := vpSpawn(n, start, mt, *vp, conditions, timestamp)
+	progeny := vpSpawn(n, start, mt, *vp, conditions, timestamp, rng)
 	vp.hunger++
 	vp.gravid = false
 	return progeny
@@ -2,11 +2,13 @@ package abm
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"testing"
 
 	"github.com/benjamin-rood/abm-cp/calc"
 	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/geometry"
 )
 
 func TestVPIntercept(t *testing.T) {
@@ -20,7 +22,7 @@ func TestVPIntercept(t *testing.T) {
 	prey[0].colouration = colour.RGB{Red: 0.6, Green: 0.2, Blue: 0.4} //  close enough to be recognised.
 
 	want := 0.3927
-	target, _ := predator.PreySearch(prey)
+	target, _ := predator.PreySearch(prey, TestConditionParams, nil)
 	if target == nil {
 		t.Errorf("No target found.")
 	}
@@ -34,6 +36,30 @@ func TestVPIntercept(t *testing.T) {
 	}
 }
 
+func TestPreySearchAcrossBoundary(t *testing.T) {
+	predator := vpTesterAgent(0.99, 0)
+	predator.𝚯 = 0 //	heading towards the right-hand edge.
+	predator.vsr = 0.2
+	predator.τ = colour.RGB{Red: 0.71, Green: 0.1, Blue: 0.39}
+	prey := []ColourPolymorphicPrey{cpPreyTesterAgent(-0.99, 0)}
+	prey[0].colouration = colour.RGB{Red: 0.6, Green: 0.2, Blue: 0.4}
+
+	target, _ := predator.PreySearch(prey, TestConditionParams, nil)
+	if target == nil {
+		t.Fatal("prey across the boundary not found")
+	}
+	intercepted, err := predator.Intercept(target.pos, TestConditionParams.Environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !intercepted || !predator.pos.Equal(target.pos) {
+		t.Errorf("failed to intercept across the boundary: at %v, target at %v", predator.pos, target.pos)
+	}
+	if predator.𝚯 != 0 {
+		t.Errorf("turned to %v to intercept, want 0", predator.𝚯)
+	}
+}
+
 func shuffle(arr []ColourPolymorphicPrey) {
 	rand.Seed(12345) // no shuffling without this line
 
@@ -44,18 +70,18 @@ func shuffle(arr []ColourPolymorphicPrey) {
 }
 
 func TestSearchAndAttack(t *testing.T) {
-	rand.Seed(0)
+	rng := calc.NewRNG(0)
 	predator := vpTesterAgent(0, 0)
 	// fmt.Println(predator.String())
-	prey := cpPreyTestPop(1000)
-	want := &prey[539]
+	prey := cpPreyTestPop(1000, rng)
+	want := &prey[953]
 
 	// for i := range prey {
 	// 	fmt.Printf("%v\t%p\n", i, &prey[i])
 	// }
 
 	var err error
-	target, err := predator.PreySearch(prey) //	previous test made sure this was true.
+	target, err := predator.PreySearch(prey, TestConditionParams, nil) //	previous test made sure this was true.
 	if target == nil {
 		t.Errorf("No target found in PreySearch")
 		return
@@ -67,7 +93,7 @@ func TestSearchAndAttack(t *testing.T) {
 		t.Errorf(err.Error())
 		return
 	}
-	intercepted, err := predator.Intercept(target.pos)
+	intercepted, err := predator.Intercept(target.pos, TestConditionParams.Environment)
 	if !intercepted {
 		t.Errorf("Failed to intercept target.")
 	}
@@ -75,15 +101,14 @@ func TestSearchAndAttack(t *testing.T) {
 		t.Errorf(err.Error())
 		return
 	}
-	success := predator.Attack(target, TestConditionParams)
+	success := predator.Attack(target, TestConditionParams, rng)
 	if !success {
 		t.Errorf("Attack unsuccessful.")
 	}
 }
 
 func TestMateSearch(t *testing.T) {
-	rand.Seed(0)
-	neighbours := vpTestPop(10)
+	neighbours := vpTestPop(10, calc.NewRNG(0))
 	// for i := range neighbours {
 	// 	fmt.Printf("%v\t%p\n", i, &neighbours[i])
 	// }
@@ -103,9 +128,11 @@ func TestMateSearch(t *testing.T) {
 	for i := range neighbours {
 		neighbours[i].fertility = 100
 	}
+	neighbours[2].pos[x] = vp.pos[x] + (vp.movS / 2) //	nearest neighbour, within reach.
+	neighbours[2].pos[y] = vp.pos[y]
 
 	want := &neighbours[2]
-	got := vp.MateSearch(neighbours, 0, ec)
+	got := vp.MateSearch(neighbours, 0, TestConditionParams.Environment, ec, nil)
 	// fmt.Printf("%p\n", got)
 	if got == nil {
 		t.Error("returned nil")
@@ -120,3 +147,183 @@ func TestMateSearch(t *testing.T) {
 		t.Errorf("want = %v\tgot = %v\n", vp.pos, got.pos)
 	}
 }
+
+func preyPositions(prey []ColourPolymorphicPrey) []geometry.Vector {
+	var positions []geometry.Vector
+	for i := range prey {
+		positions = append(positions, prey[i].pos)
+	}
+	return positions
+}
+
+func TestPreySearchIndexed(t *testing.T) {
+	rng := calc.NewRNG(0)
+	prey := cpPreyTestPop(5000, rng)
+	index := TestConditionParams.Grid(TestConditionParams.VpVsr, preyPositions(prey))
+	predators := vpTestPop(200, rng)
+	for i := range predators {
+		predators[i].𝛄 = 0.5 //	wide enough that most searches find something.
+		want, _ := predators[i].PreySearch(prey, TestConditionParams, nil)
+		got, _ := predators[i].PreySearch(prey, TestConditionParams, index)
+		if got != want {
+			t.Fatalf("vp %d: indexed PreySearch = %p, exhaustive = %p", i, got, want)
+		}
+	}
+}
+
+func TestMateSearchIndexed(t *testing.T) {
+	ec := make(chan error, 1)
+	go func() {
+		for range ec {
+		}
+	}()
+	defer close(ec)
+	neighbours := vpTestPop(500, calc.NewRNG(0))
+	var positions []geometry.Vector
+	for i := range neighbours {
+		positions = append(positions, neighbours[i].pos)
+	}
+	index := TestConditionParams.Grid(0.1, positions)
+	for i := range neighbours {
+		a, b := neighbours[i], neighbours[i] //	MateSearch moves the searching agent.
+		want := a.MateSearch(neighbours, i, TestConditionParams.Environment, ec, nil)
+		got := b.MateSearch(neighbours, i, TestConditionParams.Environment, ec, index)
+		if got != want || !a.pos.Equal(b.pos) {
+			t.Fatalf("vp %d: indexed MateSearch = %p at %v, exhaustive = %p at %v", i, got, b.pos, want, a.pos)
+		}
+	}
+}
+
+func benchmarkPreySearch(b *testing.B, size int, indexed bool) {
+	rng := calc.NewRNG(0)
+	prey := cpPreyTestPop(size, rng)
+	predators := vpTestPop(100, rng)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var index *geometry.Grid
+		if indexed { //	the index is rebuilt once per VP phase, so include it in the cost.
+			index = TestConditionParams.Grid(TestConditionParams.VpVsr, preyPositions(prey))
+		}
+		for i := range predators {
+			predators[i].PreySearch(prey, TestConditionParams, index)
+		}
+	}
+}
+
+func BenchmarkPreySearchExhaustive1000(b *testing.B)  { benchmarkPreySearch(b, 1000, false) }
+func BenchmarkPreySearchIndexed1000(b *testing.B)     { benchmarkPreySearch(b, 1000, true) }
+func BenchmarkPreySearchExhaustive10000(b *testing.B) { benchmarkPreySearch(b, 10000, false) }
+func BenchmarkPreySearchIndexed10000(b *testing.B)    { benchmarkPreySearch(b, 10000, true) }
+
+func benchmarkMateSearch(b *testing.B, size int, indexed bool) {
+	ec := make(chan error, 1)
+	go func() {
+		for range ec {
+		}
+	}()
+	defer close(ec)
+	neighbours := vpTestPop(size, calc.NewRNG(0))
+	var positions []geometry.Vector
+	for i := range neighbours {
+		positions = append(positions, neighbours[i].pos)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var index *geometry.Grid
+		if indexed {
+			index = TestConditionParams.Grid(0.1, positions)
+		}
+		for i := 0; i < 100; i++ {
+			vp := neighbours[i]
+			vp.MateSearch(neighbours, i, TestConditionParams.Environment, ec, index)
+		}
+	}
+}
+
+func BenchmarkMateSearchExhaustive1000(b *testing.B) { benchmarkMateSearch(b, 1000, false) }
+func BenchmarkMateSearchIndexed1000(b *testing.B)    { benchmarkMateSearch(b, 1000, true) }
+
+func TestMoveBoundary(t *testing.T) {
+	var tests = []struct {
+		boundary string
+		want     geometry.Vector
+		want𝚯    float64
+	}{
+		{geometry.Wrap, geometry.Vector{-0.95, 0}, 0},
+		{geometry.Reflect, geometry.Vector{0.95, 0}, calc.ToFixed(math.Pi, 5)},
+		{geometry.Wall, geometry.Vector{1.0, 0}, 0},
+	}
+	for _, test := range tests {
+		env := TestConditionParams.Environment
+		env.Boundary = test.boundary
+		vp := vpTesterAgent(0.95, 0)
+		vp.Turn(-vp.𝚯) //	heading straight for the right-hand edge.
+		vp.movS, vp.movA = 0.1, 1.0
+		vp.Move(env)
+		got := geometry.Vector{calc.ToFixed(vp.pos[x], 5), calc.ToFixed(vp.pos[y], 5)}
+		if !got.Equal(test.want) || calc.ToFixed(vp.𝚯, 4) != calc.ToFixed(test.want𝚯, 4) {
+			t.Errorf("%s: moved to %v heading %v, want %v heading %v", test.boundary, got, vp.𝚯, test.want, test.want𝚯)
+		}
+	}
+}
+
+func TestColourMetric(t *testing.T) {
+	predator := vpTesterAgent(0, 0)
+	predator.vsr = 0.5
+	predator.𝛄 = 0.2
+	predator.τ = colour.RGB{Red: 0.5, Green: 0.5, Blue: 0.1}
+	prey := []ColourPolymorphicPrey{cpPreyTesterAgent(0.05, 0.05)}
+	prey[0].colouration = colour.RGB{Red: 0.5, Green: 0.5, Blue: 0.4} //	close in RGB, but a clearly different hue.
+
+	conditions := TestConditionParams
+	conditions.VpColourMetric = colour.MetricRGB
+	if target, _ := predator.PreySearch(prey, conditions, nil); target == nil {
+		t.Errorf("%s: prey not recognised", conditions.VpColourMetric)
+	}
+	conditions.VpColourMetric = colour.MetricCIE76
+	if target, _ := predator.PreySearch(prey, conditions, nil); target != nil {
+		t.Errorf("%s: prey recognised", conditions.VpColourMetric)
+	}
+
+	predator.colourImprinting(colour.White, 0, 0.5, colour.MetricCIEDE2000)
+	if got := calc.ToFixed(predator.τ.Lab().L, 3); got != calc.ToFixed((colour.RGB{Red: 0.5, Green: 0.5, Blue: 0.1}.Lab().L+100)/2, 3) {
+		t.Errorf("imprinting with a perceptual metric should adapt halfway in CIELAB, L = %v", got)
+	}
+}
+
+func TestPreySearchVisualSystem(t *testing.T) {
+	predator := vpTesterAgent(0, 0)
+	predator.vsr = 0.5
+	predator.𝛄 = 0.2
+	predator.τ = colour.RGB{Red: 0.5, Green: 0.4, Blue: 0.3}
+	predator.τuv = 0.9
+	prey := []ColourPolymorphicPrey{cpPreyTesterAgent(0.05, 0.05), cpPreyTesterAgent(0.2, 0.2)}
+	for i := range prey {
+		prey[i].colouration = predator.τ
+	}
+	prey[0].uv = 0.1 //	nearer, but only matches the imprint to an eye blind to UV.
+	prey[1].uv = 0.9
+
+	var tests = []struct {
+		vision string
+		want   int
+	}{
+		{"", 0},
+		{colour.VisionUVTetrachromat, 1},
+	}
+	for _, test := range tests {
+		predator.vision = test.vision
+		target, _ := predator.PreySearch(prey, TestConditionParams, nil)
+		if target != &prey[test.want] {
+			t.Errorf("%q: expected prey %d to be targeted", test.vision, test.want)
+		}
+	}
+
+	conditions := TestConditionParams
+	conditions.VpVisualSystemDefs = []colour.VisualSystem{colour.VisualSystems[colour.VisionUVTetrachromat]}
+	conditions.VpVisualSystemDefs[0].Name = "kestrel"
+	predator.vision = "kestrel"
+	if target, _ := predator.PreySearch(prey, conditions, nil); target != &prey[1] {
+		t.Error("custom visual system: expected prey 1 to be targeted")
+	}
+}
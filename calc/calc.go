@@ -19,14 +19,14 @@ func ToFixed(num float64, precision int) (output float64) {
 	return
 }
 
-// RandFloatIn will give a random value in [min, max)
-func RandFloatIn(min float64, max float64) float64 {
-	return (rand.Float64() * (max - min)) + min
+// RandFloatIn will give a random value in [min, max), drawn from rng
+func RandFloatIn(min float64, max float64, rng *rand.Rand) float64 {
+	return (rng.Float64() * (max - min)) + min
 }
 
-// RandIntIn will give a random value in [min, max)
-func RandIntIn(min int, max int) int {
-	return rand.Intn(max-min) + min
+// RandIntIn will give a random value in [min, max), drawn from rng
+func RandIntIn(min int, max int, rng *rand.Rand) int {
+	return rng.Intn(max-min) + min
 }
 
 // ClampFloatIn will ensure that a floating point value is within range [min, max]. Dependant on min < max
@@ -43,6 +43,20 @@ func ClampFloatIn(f float64, min float64, max float64) float64 {
 	return f
 }
 
+// ClampIntIn will ensure that an integer value is within range [min, max]. Dependant on min <= max
+func ClampIntIn(i int, min int, max int) int {
+	if min > max {
+		return i
+	}
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
 // WrapFloatIn loops values within range [min, max].
 // e.g. range [0, 1.0], let the function symbolised by f, if input x = 1.1
 // then  f(1.1) = 0.1
@@ -62,3 +76,19 @@ func WrapFloatIn(f float64, min float64, max float64) float64 {
 	}
 	return f
 }
+
+// MeanVariance gives the mean and (population) variance of xs, or zeros if xs is empty.
+func MeanVariance(xs []float64) (mean float64, variance float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(xs))
+	return
+}
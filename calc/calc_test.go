@@ -50,8 +50,9 @@ func Test(t *testing.T) {
 		{1.76024973645, 33.99},
 	}
 
+	rng := NewRNG(0)
 	for _, rfi := range randFloatInTests {
-		got := RandFloatIn(rfi.min, rfi.max)
+		got := RandFloatIn(rfi.min, rfi.max, rng)
 		if (got < rfi.min) || (got >= rfi.max) {
 			t.Errorf("RandFloatIn(%v, %v) == %v\n", rfi.min, rfi.max, got)
 		}
@@ -66,7 +67,7 @@ func Test(t *testing.T) {
 	}
 
 	for _, rii := range randIntInTests {
-		got := RandIntIn(rii.min, rii.max)
+		got := RandIntIn(rii.min, rii.max, rng)
 		if (got < rii.min) || (got >= rii.max) {
 			t.Errorf("RandFloatIn(%v, %v) == %v\n", rii.min, rii.max, got)
 		}
@@ -105,4 +106,11 @@ func Test(t *testing.T) {
 			t.Errorf("WrapFloatIn(%v, %v, %v) == %v, want %v\n", wf.f, wf.min, wf.max, got, wf.want)
 		}
 	}
+
+	if mean, variance := MeanVariance([]float64{2, 4, 4, 4, 5, 5, 7, 9}); mean != 5 || variance != 4 {
+		t.Errorf("MeanVariance == (%v, %v), want (5, 4)\n", mean, variance)
+	}
+	if mean, variance := MeanVariance(nil); mean != 0 || variance != 0 {
+		t.Errorf("MeanVariance(nil) == (%v, %v), want (0, 0)\n", mean, variance)
+	}
 }
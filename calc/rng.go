@@ -0,0 +1,48 @@
+package calc
+
+import "math/rand"
+
+/*
+Source is a small, fast rand.Source64 (SplitMix64) whose entire state is a
+single word. It is cheap enough to create one per agent per phase, which lets
+concurrently acting agents each draw from their own deterministic stream.
+See: http://xoshiro.di.unimi.it/splitmix64.c
+*/
+type Source struct {
+	state uint64
+}
+
+// NewSource returns a Source seeded with seed.
+func NewSource(seed int64) *Source {
+	return &Source{state: uint64(seed)}
+}
+
+// NewRNG returns a *rand.Rand drawing from a new Source seeded with seed.
+func NewRNG(seed int64) *rand.Rand {
+	return rand.New(NewSource(seed))
+}
+
+// State returns the complete internal state of s: a Source created by
+// NewSource(int64(s.State())) continues the identical stream.
+func (s *Source) State() uint64 {
+	return s.state
+}
+
+// Seed implements rand.Source
+func (s *Source) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Uint64 implements rand.Source64
+func (s *Source) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Int63 implements rand.Source
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
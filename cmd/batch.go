@@ -0,0 +1,241 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/experiment"
+	"github.com/spf13/cobra"
+)
+
+// exit status codes for `abm-cp batch`
+const (
+	exitComplete         = 0
+	exitError            = 1
+	exitCpPreyExtinct    = 2
+	exitPredatorExtinct  = 3
+	batchDurationDefault = 0
+)
+
+var (
+	batchDuration   int
+	batchOutput     string
+	batchCheckpoint int
+	batchResume     string
+	batchProtocol   string
+	batchReplicates int
+	batchWorkers    int
+	batchQuantiles  []float64
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch [<conditions.json> | --resume <checkpoint.json>]",
+	Short: "Headless run of the abm-cp model to completion.",
+	Long: `Loads JSON-formatted Model Condition Parameters and runs the abm-cp model
+without a web client until the fixed duration is reached or either agent
+population becomes extinct, so a duration must be given: by --duration, or
+by abm-limit-duration and abm-fixed-duration. Logs are written as
+configured, along with a final batch summary. With --checkpoint, the model state is saved every N
+turns and a run can later be continued from that file with --resume.
+With --protocol, the actions of an experimental protocol file (e.g. adding
+or removing agents, or changing the background) are made at their turns.
+With --replicates N, the model is run N times on a pool of workers, each
+replicate with its own seed (the conditions' seed plus its number, or one
+drawn from the clock when the seed is random), logging into its own
+subdirectory of the output directory. Their per-turn summaries are
+aggregated into the mean, median and quantiles of every metric, written to
+bands.csv and bands.json (which the web UI can plot), alongside an
+index.csv of every replicate.
+
+Exit status:
+  0  ran for the full duration
+  1  invalid input or failure
+  2  CP Prey population extinct
+  3  Visual Predator population extinct`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := batchModel(args)
+		if err != nil {
+			logError("batch", err)
+			os.Exit(exitError)
+		}
+		if batchDuration > 0 {
+			m.LimitDuration = true
+			m.FixedDuration = batchDuration
+		}
+		if batchCheckpoint > 0 {
+			m.CheckpointFreq = batchCheckpoint
+		}
+		if batchProtocol != "" {
+			m.Protocol, err = loadProtocol(batchProtocol)
+			if err != nil {
+				logError("batch", err)
+				os.Exit(exitError)
+			}
+		}
+		if batchReplicates > 1 {
+			err = replicates(m.ConditionParams)
+			if err != nil {
+				logError("batch", err)
+				os.Exit(exitError)
+			}
+			os.Exit(exitComplete)
+		}
+		m.OutputDir = batchOutput
+		summary, err := m.RunBatch()
+		if err != nil {
+			logError("batch", err)
+			os.Exit(exitError)
+		}
+		report, _ := json.MarshalIndent(summary, "", "  ")
+		log.Printf("batch summary:\n%s\n", report)
+
+		switch summary.Outcome {
+		case abm.BatchCpPreyExtinct:
+			os.Exit(exitCpPreyExtinct)
+		case abm.BatchPredatorExtinct:
+			os.Exit(exitPredatorExtinct)
+		}
+		os.Exit(exitComplete)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(batchCmd)
+	batchCmd.Flags().IntVarP(&batchDuration, "duration", "d", batchDurationDefault, "number of turns to run for (overrides the conditions file)")
+	batchCmd.Flags().StringVarP(&batchOutput, "output", "o", "", "directory for log files and the batch summary")
+	batchCmd.Flags().IntVarP(&batchCheckpoint, "checkpoint", "c", 0, "write a checkpoint every N turns")
+	batchCmd.Flags().StringVarP(&batchResume, "resume", "r", "", "continue the run saved in a checkpoint file")
+	batchCmd.Flags().StringVarP(&batchProtocol, "protocol", "p", "", "make the actions of an experimental protocol file at their turns (overrides the conditions file)")
+	batchCmd.Flags().IntVarP(&batchReplicates, "replicates", "n", 1, "number of replicate runs, each with its own seed, to aggregate")
+	batchCmd.Flags().IntVarP(&batchWorkers, "workers", "w", runtime.NumCPU(), "number of replicates to run at once")
+	batchCmd.Flags().Float64SliceVarP(&batchQuantiles, "quantiles", "q", experiment.DefaultQuantiles, "quantiles bounding the band of each metric over the replicates")
+}
+
+// replicates runs the conditions --replicates times, and aggregates them into bands.
+func replicates(conditions abm.ConditionParams) error {
+	if batchResume != "" {
+		return errors.New("--replicates cannot be used with --resume")
+	}
+	if err := experiment.CheckQuantiles(batchQuantiles); err != nil {
+		return err
+	}
+	if err := conditions.ValidateHeadless(); err != nil {
+		return err
+	}
+	seed := conditions.RNGSeedVal
+	if conditions.RNGRandomSeed {
+		seed = time.Now().UnixNano()
+	}
+	output := batchOutput
+	if output == "" {
+		output = "replicates"
+	}
+	runs, err := experiment.Sweep{Replicates: batchReplicates, Seed: seed}.Runs(conditions)
+	if err != nil {
+		return err
+	}
+	log.Printf("batch: %d replicates from seed %d on %d workers\n", len(runs), seed, batchWorkers)
+	results := experiment.RunAll(runs, output, batchWorkers, func(r experiment.Result) {
+		if r.Err != nil {
+			log.Printf("batch: %s failed: %s\n", r.ID, r.Err)
+			return
+		}
+		log.Printf("batch: %s %s after %d turns\n", r.ID, r.Summary.Outcome, r.Summary.Turns)
+	})
+	err = writeIndex(filepath.Join(output, experiment.IndexFile), results)
+	if err != nil {
+		return err
+	}
+	err = writeBands(output, experiment.AggregateResults(results, batchQuantiles))
+	if err != nil {
+		return err
+	}
+	return failures(results)
+}
+
+// batchModel either restores the model from the --resume checkpoint,
+// or creates a new one from the conditions file given as the sole argument.
+func batchModel(args []string) (*abm.Model, error) {
+	if batchResume != "" {
+		if len(args) != 0 {
+			return nil, errors.New("a conditions file cannot be used with --resume")
+		}
+		f, err := os.Open(batchResume)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return abm.RestoreModel(f)
+	}
+	if len(args) != 1 {
+		return nil, errors.New("exactly one conditions file must be provided")
+	}
+	conditions, err := loadConditions(args[0])
+	if err != nil {
+		return nil, err
+	}
+	m := abm.NewModel()
+	m.ConditionParams = conditions
+	return m, nil
+}
+
+// loadConditions reads a JSON-formatted ConditionParams file, and validates
+// them. Fields absent from the file keep their default values.
+func loadConditions(filename string) (abm.ConditionParams, error) {
+	conditions := abm.DefaultConditionParams
+	conditions.Bounds = append([]float64(nil), abm.DefaultConditionParams.Bounds...) // don't share the default's backing array
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return conditions, err
+	}
+	err = json.Unmarshal(raw, &conditions)
+	if err != nil {
+		return conditions, err
+	}
+	return conditions, conditions.Validate()
+}
+
+// loadProtocol reads a JSON-formatted experimental protocol file.
+func loadProtocol(filename string) ([]abm.ProtocolAction, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return abm.LoadProtocol(f)
+}
+
+// logError logs why a command failed, listing every invalid condition.
+func logError(command string, err error) {
+	invalid, ok := err.(abm.ValidationError)
+	if !ok {
+		log.Printf("%s: %s\n", command, err)
+		return
+	}
+	log.Printf("%s: invalid conditions:\n", command)
+	for _, fe := range invalid {
+		log.Println("  ", fe)
+	}
+}
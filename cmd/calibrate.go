@@ -0,0 +1,154 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/benjamin-rood/abm-cp/experiment"
+	"github.com/benjamin-rood/abm-cp/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	calibrateDuration int
+	calibrateOutput   string
+	calibrateWorkers  int
+)
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate <conditions.json> <calibration.json> <observed.csv>",
+	Short: "Calibrate the abm-cp model against observed morph frequencies by ABC.",
+	Long: `Loads JSON-formatted base Model Condition Parameters, a calibration giving
+the prior distribution of each parameter to fit (by its JSON key), and a CSV
+of observed morph frequencies, with "red", "green", "blue" (in [0, 1]) and
+"count" (or "frequency") columns:
+
+  {"priors": [
+     {"key": "abm-vp-col-adaptation-factor", "min": 0.05, "max": 0.5},
+     {"key": "abm-cp-prey-mf", "distribution": "normal", "mean": 0.05, "sd": 0.02, "min": 0, "max": 1}],
+   "distance": "total-variation", "particles": 100, "generations": 3, "seed": 1}
+
+Parameter sets are drawn from the priors and run as batches on a pool of
+workers. Those whose final prey morph frequencies are closest to the
+observed ones are accepted by ABC rejection, then refined by each generation
+of ABC-SMC. The posterior sample is written to posterior.csv, and every
+generation with the posterior estimates to calibration.json, alongside the
+index.csv and log directory of every run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := calibrate(args)
+		if err != nil {
+			logError("calibrate", err)
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(calibrateCmd)
+	calibrateCmd.Flags().IntVarP(&calibrateDuration, "duration", "d", batchDurationDefault, "number of turns to run each model for (overrides the conditions file)")
+	calibrateCmd.Flags().StringVarP(&calibrateOutput, "output", "o", "calibration", "directory for the posterior, the index and the log directory of every run")
+	calibrateCmd.Flags().IntVarP(&calibrateWorkers, "workers", "w", runtime.NumCPU(), "number of models to run at once")
+}
+
+func calibrate(args []string) error {
+	if len(args) != 3 {
+		return errors.New("a conditions file, a calibration file and an observed morphs file must be provided")
+	}
+	base, err := loadConditions(args[0])
+	if err != nil {
+		return err
+	}
+	if calibrateDuration > 0 {
+		base.LimitDuration = true
+		base.FixedDuration = calibrateDuration
+	}
+	if err := base.ValidateHeadless(); err != nil {
+		return err
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	c, err := experiment.LoadCalibration(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	f, err = os.Open(args[2])
+	if err != nil {
+		return err
+	}
+	observed, err := experiment.LoadObserved(f, stats.NewMorphs(base.MorphBins))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	runner := func(runs []experiment.Run) []experiment.Result {
+		log.Printf("calibrate: %d runs on %d workers\n", len(runs), calibrateWorkers)
+		return experiment.RunAll(runs, calibrateOutput, calibrateWorkers, func(r experiment.Result) {
+			if r.Err != nil {
+				log.Printf("calibrate: %s failed: %s\n", r.ID, r.Err)
+			}
+		})
+	}
+	posterior, results, err := c.Calibrate(base, observed, runner)
+	if ierr := writeIndex(filepath.Join(calibrateOutput, experiment.IndexFile), results); err == nil {
+		err = ierr
+	}
+	if err != nil {
+		return err
+	}
+	for i, g := range posterior.Generations {
+		log.Printf("calibrate: generation %d accepted %d particles within %v in %d runs\n", i, len(g.Particles), g.Tolerance, g.Runs)
+	}
+	if posterior.Stopped != "" {
+		log.Printf("calibrate: stopped early: %s\n", posterior.Stopped)
+	}
+	log.Printf("calibrate: posterior\n%s", posterior)
+	err = writePosterior(calibrateOutput, posterior)
+	if err != nil {
+		return err
+	}
+	return failures(results)
+}
+
+// writePosterior writes the posterior of a calibration into dir, as CSV, and its full report as JSON.
+func writePosterior(dir string, posterior experiment.Posterior) error {
+	raw, err := json.MarshalIndent(posterior, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, experiment.CalibrationFile), raw, 0666)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, experiment.PosteriorFile))
+	if err != nil {
+		return err
+	}
+	err = posterior.WritePosterior(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
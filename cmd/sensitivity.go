@@ -0,0 +1,144 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/benjamin-rood/abm-cp/experiment"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sensitivityDuration int
+	sensitivityOutput   string
+	sensitivityWorkers  int
+)
+
+// sensitivityCmd represents the sensitivity command
+var sensitivityCmd = &cobra.Command{
+	Use:   "sensitivity <conditions.json> <design.json>",
+	Short: "Global sensitivity analysis of the abm-cp model's outputs to its parameters.",
+	Long: `Loads JSON-formatted base Model Condition Parameters and a sensitivity
+design: the parameters to vary, each by its JSON key over a range, and the
+outputs to analyse (the columns of summary.csv, or apostatic_coefficient):
+
+  {"method": "sobol", "sampler": "sobol", "samples": 64,
+   "parameters": [
+     {"key": "abm-vp-col-adaptation-factor", "min": 0.05, "max": 0.5},
+     {"key": "abm-cp-prey-spawn-size", "min": 1, "max": 4, "integer": true}],
+   "outputs": ["cp_prey_shannon", "apostatic_coefficient"],
+   "replicates": 2, "seed": 1}
+
+The "sobol" method samples the parameters from a Sobol sequence (or a Latin
+hypercube, with the "lhs" sampler) and estimates first- and total-order Sobol
+indices; the "morris" method estimates elementary effects along "samples"
+trajectories over a grid of "levels" values. Every design point is run as a
+batch, once per replicate seed, on a pool of workers. The report is written
+to sensitivity.json and sensitivity.csv in the output directory, alongside
+the index.csv and log directory of every run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := sensitivity(args)
+		if err != nil {
+			logError("sensitivity", err)
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sensitivityCmd)
+	sensitivityCmd.Flags().IntVarP(&sensitivityDuration, "duration", "d", batchDurationDefault, "number of turns to run each model for (overrides the conditions file)")
+	sensitivityCmd.Flags().StringVarP(&sensitivityOutput, "output", "o", "sensitivity", "directory for the report, the index and the log directory of every run")
+	sensitivityCmd.Flags().IntVarP(&sensitivityWorkers, "workers", "w", runtime.NumCPU(), "number of models to run at once")
+}
+
+func sensitivity(args []string) error {
+	if len(args) != 2 {
+		return errors.New("a conditions file and a sensitivity design file must be provided")
+	}
+	base, err := loadConditions(args[0])
+	if err != nil {
+		return err
+	}
+	if sensitivityDuration > 0 {
+		base.LimitDuration = true
+		base.FixedDuration = sensitivityDuration
+	}
+	if err := base.ValidateHeadless(); err != nil {
+		return err
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	s, err := experiment.LoadSensitivity(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	runs, err := s.Runs(base)
+	if err != nil {
+		return err
+	}
+	log.Printf("sensitivity: %d runs on %d workers\n", len(runs), sensitivityWorkers)
+	results := experiment.RunAll(runs, sensitivityOutput, sensitivityWorkers, func(r experiment.Result) {
+		if r.Err != nil {
+			log.Printf("sensitivity: %s failed: %s\n", r.ID, r.Err)
+			return
+		}
+		log.Printf("sensitivity: %s %s after %d turns\n", r.ID, r.Summary.Outcome, r.Summary.Turns)
+	})
+	err = writeIndex(filepath.Join(sensitivityOutput, experiment.IndexFile), results)
+	if err != nil {
+		return err
+	}
+	report, err := s.Analyse(results)
+	if err != nil {
+		return err
+	}
+	err = writeReport(sensitivityOutput, report)
+	if err != nil {
+		return err
+	}
+	return failures(results)
+}
+
+// writeReport writes the report of a sensitivity analysis into dir, as JSON and CSV.
+func writeReport(dir string, report experiment.Report) error {
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, experiment.SensitivityFile), raw, 0666)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, experiment.SensitivityCSVFile))
+	if err != nil {
+		return err
+	}
+	err = report.WriteCSV(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
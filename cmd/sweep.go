@@ -0,0 +1,174 @@
+// Copyright © 2016 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/benjamin-rood/abm-cp/experiment"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sweepDuration  int
+	sweepOutput    string
+	sweepWorkers   int
+	sweepQuantiles []float64
+)
+
+// sweepCmd represents the sweep command
+var sweepCmd = &cobra.Command{
+	Use:   "sweep <conditions.json> <sweep.json>",
+	Short: "Headless runs of the abm-cp model over a grid of parameters.",
+	Long: `Loads JSON-formatted base Model Condition Parameters and a sweep of the
+parameters to vary, each by its JSON key, over a list of values or a range:
+
+  {"parameters": [
+     {"key": "abm-vp-col-adaptation-factor", "values": [0.1, 0.2, 0.4]},
+     {"key": "abm-cp-prey-mf", "from": 0.01, "to": 0.1, "steps": 4}],
+   "replicates": 3, "seed": 1}
+
+Every combination of values is run as a batch, once per replicate seed, on a
+pool of workers. Each run logs into its own subdirectory of the output
+directory, alongside an index.csv of the parameters and final summary of
+every run. The per-turn summaries of the replicates of each combination are
+aggregated into the mean, median and quantiles of every metric, written to
+bands.csv and bands.json (which the web UI can plot).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := sweep(args)
+		if err != nil {
+			logError("sweep", err)
+			os.Exit(exitError)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sweepCmd)
+	sweepCmd.Flags().IntVarP(&sweepDuration, "duration", "d", batchDurationDefault, "number of turns to run each model for (overrides the conditions file)")
+	sweepCmd.Flags().StringVarP(&sweepOutput, "output", "o", "sweep", "directory for the index and the log directory of every run")
+	sweepCmd.Flags().IntVarP(&sweepWorkers, "workers", "w", runtime.NumCPU(), "number of models to run at once")
+	sweepCmd.Flags().Float64SliceVarP(&sweepQuantiles, "quantiles", "q", experiment.DefaultQuantiles, "quantiles bounding the band of each metric over the replicates")
+}
+
+func sweep(args []string) error {
+	if len(args) != 2 {
+		return errors.New("a conditions file and a sweep file must be provided")
+	}
+	base, err := loadConditions(args[0])
+	if err != nil {
+		return err
+	}
+	if sweepDuration > 0 {
+		base.LimitDuration = true
+		base.FixedDuration = sweepDuration
+	}
+	if err := base.ValidateHeadless(); err != nil {
+		return err
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	s, err := experiment.LoadSweep(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := experiment.CheckQuantiles(sweepQuantiles); err != nil {
+		return err
+	}
+	runs, err := s.Runs(base)
+	if err != nil {
+		return err
+	}
+	log.Printf("sweep: %d runs on %d workers\n", len(runs), sweepWorkers)
+	results := experiment.RunAll(runs, sweepOutput, sweepWorkers, func(r experiment.Result) {
+		if r.Err != nil {
+			log.Printf("sweep: %s failed: %s\n", r.ID, r.Err)
+			return
+		}
+		log.Printf("sweep: %s %s after %d turns\n", r.ID, r.Summary.Outcome, r.Summary.Turns)
+	})
+	err = writeIndex(filepath.Join(sweepOutput, experiment.IndexFile), results)
+	if err != nil {
+		return err
+	}
+	err = writeBands(sweepOutput, experiment.AggregateResults(results, sweepQuantiles))
+	if err != nil {
+		return err
+	}
+	return failures(results)
+}
+
+// failures gives an error counting the runs of an experiment which failed, or
+// nil when none did, so the command exits with exitError once its output is written.
+func failures(results []experiment.Result) error {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d runs failed", failed, len(results))
+}
+
+// writeIndex writes the index of the results of an experiment to filename.
+func writeIndex(filename string, results []experiment.Result) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = experiment.WriteIndex(f, results)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeBands writes the bands of an experiment into dir, as CSV and JSON.
+func writeBands(dir string, bands []experiment.Bands) error {
+	raw, err := json.Marshal(bands)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, experiment.BandsJSONFile), raw, 0666)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, experiment.BandsFile))
+	if err != nil {
+		return err
+	}
+	err = experiment.WriteBands(f, bands)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package colour
 
 import (
+	"image/color"
 	"math"
 	"math/rand"
 
@@ -66,27 +67,27 @@ func RGBDistance(c1 RGB, c2 RGB) float64 {
 }
 
 // RandRGB will return a random valid RGB object within the complete range of all possible RGB values.
-func RandRGB() RGB {
-	red := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0))
-	green := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0))
-	blue := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0))
+func RandRGB(rng *rand.Rand) RGB {
+	red := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0), rng)
+	green := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0), rng)
+	blue := calc.RandFloatIn(0, math.Nextafter(1.0, 2.0), rng)
 	return RGB{red, green, blue}
 }
 
 // RandRGB256 will return a random colour value
-func RandRGB256() RGB256 {
-	red := byte(rand.Intn(256))
-	green := byte(rand.Intn(256))
-	blue := byte(rand.Intn(256))
+func RandRGB256(rng *rand.Rand) RGB256 {
+	red := byte(rng.Intn(256))
+	green := byte(rng.Intn(256))
+	blue := byte(rng.Intn(256))
 	return RGB256{red, green, blue}
 }
 
 // RandRGBClamped will return a random valid RGB object within some differential of `col`
-func RandRGBClamped(col RGB, diff float64) RGB {
-	diff = rand.NormFloat64() * diff
-	red := col.Red + calc.RandFloatIn(-diff, diff)
-	green := col.Green + calc.RandFloatIn(-diff, diff)
-	blue := col.Blue + calc.RandFloatIn(-diff, diff)
+func RandRGBClamped(col RGB, diff float64, rng *rand.Rand) RGB {
+	diff = rng.NormFloat64() * diff
+	red := col.Red + calc.RandFloatIn(-diff, diff, rng)
+	green := col.Green + calc.RandFloatIn(-diff, diff, rng)
+	blue := col.Blue + calc.RandFloatIn(-diff, diff, rng)
 	red = calc.ClampFloatIn(red, 0.0, 1.0)
 	green = calc.ClampFloatIn(green, 0.0, 1.0)
 	blue = calc.ClampFloatIn(blue, 0.0, 1.0)
@@ -100,3 +101,24 @@ func (rgb RGB) Invert() (inv RGB) {
 	inv.Blue = 1.0 - rgb.Blue
 	return
 }
+
+// FromColor converts any colour from the standard library image/color package to RGB.
+// Transparency is ignored, i.e. colours are treated as opaque.
+func FromColor(c color.Color) RGB {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return Black
+	}
+	//	un-premultiply alpha
+	return RGB{
+		Red:   float64(r) / float64(a),
+		Green: float64(g) / float64(a),
+		Blue:  float64(b) / float64(a),
+	}
+}
+
+// Color converts rgb into an (opaque) image/color value.
+func (rgb RGB) Color() color.RGBA {
+	c := rgb.To256()
+	return color.RGBA{R: c.Red, G: c.Green, B: c.Blue, A: 255}
+}
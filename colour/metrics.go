@@ -0,0 +1,133 @@
+package colour
+
+import (
+	"errors"
+	"math"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Names of the available colour difference metrics.
+const (
+	MetricRGB       = "rgb"       //	RGBDistance (default)
+	MetricCIE76     = "cie76"     //	CIE76Distance
+	MetricCIEDE2000 = "ciede2000" //	CIEDE2000Distance
+)
+
+/*
+Metric quantifies the difference between two colours as a ratio from 0.0
+(indistinguishable) to 1.0, as RGBDistance does, so that metrics can be
+swapped for one another without changing any thresholds expressed in terms
+of colour difference.
+*/
+type Metric func(c1 RGB, c2 RGB) float64
+
+// MetricNamed gives the Metric with the given name. An empty name gives RGBDistance.
+func MetricNamed(name string) (Metric, error) {
+	switch name {
+	case "", MetricRGB:
+		return RGBDistance, nil
+	case MetricCIE76:
+		return CIE76Distance, nil
+	case MetricCIEDE2000:
+		return CIEDE2000Distance, nil
+	}
+	return RGBDistance, errors.New("unknown colour metric: " + name)
+}
+
+// IsPerceptual reports whether the named metric is measured in a perceptual
+// (CIELAB) colour space, where colours should also be mixed.
+func IsPerceptual(name string) bool {
+	return name == MetricCIE76 || name == MetricCIEDE2000
+}
+
+// ΔE values are scaled down to ratios by the difference between black and white (ΔE = 100).
+func ΔEToRatio(ΔE float64) float64 {
+	return calc.ToFixed(calc.ClampFloatIn(ΔE/100, 0, 1), 5)
+}
+
+// CIE76Distance is the CIE76 ΔE between two colours, as a Metric ratio.
+func CIE76Distance(c1 RGB, c2 RGB) float64 {
+	return ΔEToRatio(DeltaE76(c1.Lab(), c2.Lab()))
+}
+
+// CIEDE2000Distance is the CIEDE2000 ΔE between two colours, as a Metric ratio.
+func CIEDE2000Distance(c1 RGB, c2 RGB) float64 {
+	return ΔEToRatio(DeltaE2000(c1.Lab(), c2.Lab()))
+}
+
+// DeltaE76 is the CIE76 colour difference: the euclidean distance in CIELAB.
+func DeltaE76(c1 Lab, c2 Lab) float64 {
+	ΔL, Δa, Δb := c1.L-c2.L, c1.A-c2.A, c1.B-c2.B
+	return math.Sqrt((ΔL * ΔL) + (Δa * Δa) + (Δb * Δb))
+}
+
+func deg(rad float64) float64 { return rad * 180 / math.Pi }
+func rad(deg float64) float64 { return deg * math.Pi / 180 }
+
+// hue angle in degrees [0,360)
+func hue(a float64, b float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := deg(math.Atan2(b, a))
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+/*
+DeltaE2000 is the CIEDE2000 colour difference (with kL = kC = kH = 1), following
+Sharma, Wu & Dalal (2005) "The CIEDE2000 color-difference formula:
+Implementation notes, supplementary test data, and mathematical observations".
+*/
+func DeltaE2000(c1 Lab, c2 Lab) float64 {
+	const pow25to7 = 6103515625.0 //	25^7
+	Cab := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	Cab7 := math.Pow(Cab, 7)
+	G := 0.5 * (1 - math.Sqrt(Cab7/(Cab7+pow25to7)))
+	a1, a2 := (1+G)*c1.A, (1+G)*c2.A
+	C1, C2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+	h1, h2 := hue(a1, c1.B), hue(a2, c2.B)
+
+	ΔL := c2.L - c1.L
+	ΔC := C2 - C1
+	Δh := 0.0
+	if C1*C2 != 0 {
+		Δh = h2 - h1
+		switch {
+		case Δh > 180:
+			Δh -= 360
+		case Δh < -180:
+			Δh += 360
+		}
+	}
+	ΔH := 2 * math.Sqrt(C1*C2) * math.Sin(rad(Δh/2))
+
+	L := (c1.L + c2.L) / 2
+	C := (C1 + C2) / 2
+	h := h1 + h2
+	if C1*C2 != 0 {
+		switch {
+		case math.Abs(h1-h2) <= 180:
+			h /= 2
+		case h < 360:
+			h = (h + 360) / 2
+		default:
+			h = (h - 360) / 2
+		}
+	}
+	T := 1 - (0.17 * math.Cos(rad(h-30))) + (0.24 * math.Cos(rad(2*h))) + (0.32 * math.Cos(rad((3*h)+6))) - (0.20 * math.Cos(rad((4*h)-63)))
+	Δθ := 30 * math.Exp(-math.Pow((h-275)/25, 2))
+	C7 := math.Pow(C, 7)
+	RC := 2 * math.Sqrt(C7/(C7+pow25to7))
+	L50 := (L - 50) * (L - 50)
+	SL := 1 + ((0.015 * L50) / math.Sqrt(20+L50))
+	SC := 1 + (0.045 * C)
+	SH := 1 + (0.015 * C * T)
+	RT := -math.Sin(rad(2*Δθ)) * RC
+
+	l, c, hh := ΔL/SL, ΔC/SC, ΔH/SH
+	return math.Sqrt((l * l) + (c * c) + (hh * hh) + (RT * c * hh))
+}
@@ -0,0 +1,73 @@
+package colour
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+func TestDeltaE2000(t *testing.T) {
+	// a selection of the test data from Sharma, Wu & Dalal (2005):
+	var tests = []struct {
+		c1, c2 Lab
+		want   float64
+	}{
+		{Lab{50.0000, 2.6772, -79.7751}, Lab{50.0000, 0.0000, -82.7485}, 2.0425},
+		{Lab{50.0000, 0.0000, 0.0000}, Lab{50.0000, -1.0000, 2.0000}, 2.3669},
+		{Lab{50.0000, 2.4900, -0.0010}, Lab{50.0000, -2.4900, 0.0011}, 7.2195},
+		{Lab{50.0000, 2.5000, 0.0000}, Lab{73.0000, 25.0000, -18.0000}, 27.1492},
+		{Lab{60.2574, -34.0099, 36.2677}, Lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{Lab{2.0776, 0.0795, -1.1350}, Lab{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for _, test := range tests {
+		got := calc.ToFixed(DeltaE2000(test.c1, test.c2), 4)
+		if got != test.want {
+			t.Errorf("DeltaE2000(%v, %v) == %v, want %v", test.c1, test.c2, got, test.want)
+		}
+		if back := calc.ToFixed(DeltaE2000(test.c2, test.c1), 4); back != got {
+			t.Errorf("DeltaE2000 not symmetric: %v vs %v", got, back)
+		}
+	}
+}
+
+func TestColourSpaces(t *testing.T) {
+	white := White.Lab()
+	if calc.ToFixed(white.L, 3) != 100 || math.Abs(white.A) > 0.01 || math.Abs(white.B) > 0.01 {
+		t.Errorf("White.Lab() == %v, want {100 0 0}", white)
+	}
+	red := Red.Lab()
+	if calc.ToFixed(red.L, 1) != 53.2 || calc.ToFixed(red.A, 1) != 80.1 || calc.ToFixed(red.B, 1) != 67.2 {
+		t.Errorf("Red.Lab() == %v, want {53.2 80.1 67.2}", red)
+	}
+	if DeltaE76(Black.Lab(), White.Lab()) < 99.99 || CIE76Distance(Black, White) != 1 {
+		t.Errorf("black and white should be ΔE 100 apart")
+	}
+	var hsvTests = []struct {
+		rgb RGB
+		hsv HSV
+	}{
+		{Red, HSV{0, 1, 1}},
+		{Cyan, HSV{180, 1, 1}},
+		{RGB{0.5, 0.25, 0.5}, HSV{300, 0.5, 0.5}},
+		{RGB{0.2, 0.2, 0.2}, HSV{0, 0, 0.2}},
+	}
+	for _, test := range hsvTests {
+		if got := test.rgb.HSV(); got != test.hsv {
+			t.Errorf("%v.HSV() == %v, want %v", test.rgb, got, test.hsv)
+		}
+	}
+	for _, c := range []RGB{Orange, Magenta, {0.3, 0.6, 0.9}, {0.01, 0.02, 0.03}} {
+		for name, back := range map[string]RGB{"Lab": c.Lab().RGB(), "HSV": c.HSV().RGB()} {
+			if RGBDistance(c, back) != 0 {
+				t.Errorf("%v does not survive the round trip through %s: %v", c, name, back)
+			}
+		}
+	}
+	if mid := LabLerp(Black, White, 0.5).Lab(); calc.ToFixed(mid.L, 3) != 50 {
+		t.Errorf("LabLerp(Black, White, 0.5) has L = %v, want 50", mid.L)
+	}
+	if _, err := MetricNamed("cie94"); err == nil {
+		t.Errorf("unknown metric accepted")
+	}
+}
@@ -0,0 +1,190 @@
+package colour
+
+import (
+	"math"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+/*
+XYZ is a colour in the CIE 1931 XYZ colour space, scaled so that the Y
+(luminance) of the D65 reference white is 1.0.
+*/
+type XYZ struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+/*
+Lab is a colour in the CIE 1976 L*a*b* (CIELAB) colour space, relative to
+the D65 reference white. L is lightness in [0,100], while a (green–red)
+and b (blue–yellow) are roughly in [-128,128].
+*/
+type Lab struct {
+	L float64 `json:"l"`
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+/*
+HSV is a colour by hue (H, in degrees [0,360)), saturation (S, in [0,1])
+and value (V, in [0,1]).
+*/
+type HSV struct {
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+	V float64 `json:"v"`
+}
+
+// D65 reference white
+var D65 = XYZ{X: 0.95047, Y: 1.0, Z: 1.08883}
+
+const labε = 6.0 / 29.0
+
+// sRGB companding
+func linearise(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func compand(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return (1.055 * math.Pow(c, 1/2.4)) - 0.055
+}
+
+// XYZ converts rgb (as sRGB) into the CIE XYZ colour space.
+func (rgb RGB) XYZ() XYZ {
+	r, g, b := linearise(rgb.Red), linearise(rgb.Green), linearise(rgb.Blue)
+	return XYZ{
+		X: (0.4124564 * r) + (0.3575761 * g) + (0.1804375 * b),
+		Y: (0.2126729 * r) + (0.7151522 * g) + (0.0721750 * b),
+		Z: (0.0193339 * r) + (0.1191920 * g) + (0.9503041 * b),
+	}
+}
+
+// RGB converts xyz into sRGB. Colours outside the sRGB gamut are clamped to it.
+func (xyz XYZ) RGB() RGB {
+	r := (3.2404542 * xyz.X) - (1.5371385 * xyz.Y) - (0.4985314 * xyz.Z)
+	g := (-0.9692660 * xyz.X) + (1.8760108 * xyz.Y) + (0.0415560 * xyz.Z)
+	b := (0.0556434 * xyz.X) - (0.2040259 * xyz.Y) + (1.0572252 * xyz.Z)
+	return RGB{
+		Red:   calc.ClampFloatIn(compand(r), 0, 1),
+		Green: calc.ClampFloatIn(compand(g), 0, 1),
+		Blue:  calc.ClampFloatIn(compand(b), 0, 1),
+	}
+}
+
+func labF(t float64) float64 {
+	if t > labε*labε*labε {
+		return math.Cbrt(t)
+	}
+	return (t / (3 * labε * labε)) + (4.0 / 29.0)
+}
+
+func labFInv(t float64) float64 {
+	if t > labε {
+		return t * t * t
+	}
+	return 3 * labε * labε * (t - (4.0 / 29.0))
+}
+
+// Lab converts xyz into the CIELAB colour space.
+func (xyz XYZ) Lab() Lab {
+	fx, fy, fz := labF(xyz.X/D65.X), labF(xyz.Y/D65.Y), labF(xyz.Z/D65.Z)
+	return Lab{
+		L: (116 * fy) - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+// XYZ converts lab into the CIE XYZ colour space.
+func (lab Lab) XYZ() XYZ {
+	fy := (lab.L + 16) / 116
+	fx := fy + (lab.A / 500)
+	fz := fy - (lab.B / 200)
+	return XYZ{
+		X: D65.X * labFInv(fx),
+		Y: D65.Y * labFInv(fy),
+		Z: D65.Z * labFInv(fz),
+	}
+}
+
+// Lab converts rgb (as sRGB) into the CIELAB colour space.
+func (rgb RGB) Lab() Lab {
+	return rgb.XYZ().Lab()
+}
+
+// RGB converts lab into sRGB. Colours outside the sRGB gamut are clamped to it.
+func (lab Lab) RGB() RGB {
+	return lab.XYZ().RGB()
+}
+
+// HSV converts rgb into hue, saturation and value.
+func (rgb RGB) HSV() HSV {
+	max := math.Max(rgb.Red, math.Max(rgb.Green, rgb.Blue))
+	min := math.Min(rgb.Red, math.Min(rgb.Green, rgb.Blue))
+	Δ := max - min
+	hsv := HSV{V: max}
+	if max > 0 {
+		hsv.S = Δ / max
+	}
+	if Δ == 0 {
+		return hsv //	achromatic: hue is undefined, use 0.
+	}
+	switch max {
+	case rgb.Red:
+		hsv.H = 60 * math.Mod((rgb.Green-rgb.Blue)/Δ, 6)
+	case rgb.Green:
+		hsv.H = 60 * (((rgb.Blue - rgb.Red) / Δ) + 2)
+	default:
+		hsv.H = 60 * (((rgb.Red - rgb.Green) / Δ) + 4)
+	}
+	if hsv.H < 0 {
+		hsv.H += 360
+	}
+	return hsv
+}
+
+// RGB converts hsv into RGB.
+func (hsv HSV) RGB() RGB {
+	h := math.Mod(hsv.H, 360)
+	if h < 0 {
+		h += 360
+	}
+	c := hsv.V * hsv.S
+	hp := h / 60
+	xc := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, xc, 0
+	case hp < 2:
+		r, g, b = xc, c, 0
+	case hp < 3:
+		r, g, b = 0, c, xc
+	case hp < 4:
+		r, g, b = 0, xc, c
+	case hp < 5:
+		r, g, b = xc, 0, c
+	default:
+		r, g, b = c, 0, xc
+	}
+	m := hsv.V - c
+	return RGB{Red: r + m, Green: g + m, Blue: b + m}
+}
+
+// LabLerp mixes colours c1 and c2 in the CIELAB colour space: t = 0 gives c1, and t = 1 gives c2.
+func LabLerp(c1 RGB, c2 RGB, t float64) RGB {
+	a, b := c1.Lab(), c2.Lab()
+	return Lab{
+		L: a.L + ((b.L - a.L) * t),
+		A: a.A + ((b.A - a.A) * t),
+		B: a.B + ((b.B - a.B) * t),
+	}.RGB()
+}
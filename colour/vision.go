@@ -0,0 +1,199 @@
+package colour
+
+import (
+	"errors"
+	"math"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Names of the built-in visual systems.
+const (
+	VisionDichromat      = "dichromat"       //	short- and long-wave cones, as in most mammals
+	VisionTrichromat     = "trichromat"      //	short-, medium- and long-wave cones, as in many fish (and humans)
+	VisionUVTetrachromat = "uv-tetrachromat" //	UV-, short-, medium- and long-wave cones, as in UV-sensitive birds
+)
+
+const (
+	dVisionScale   = 10   //	ΔS (in JNDs) perceived as a difference ratio of 0.5
+	minQuantaCatch = 1e-3 //	floor on receptor quantum catches, so that black still gives a finite signal
+)
+
+/*
+Reflectance is a colouration described by its reflectance (from 0.0 to 1.0)
+in four broad wavebands: ultraviolet, blue, green and red. The visible
+bands are those of an RGB colour; UV is invisible to humans, and so has no
+RGB equivalent.
+*/
+type Reflectance struct {
+	UV    float64 `json:"uv"`
+	Blue  float64 `json:"blue"`
+	Green float64 `json:"green"`
+	Red   float64 `json:"red"`
+}
+
+// Reflectance gives the reflectance of rgb, together with its UV reflectance uv.
+func (rgb RGB) Reflectance(uv float64) Reflectance {
+	return Reflectance{UV: uv, Blue: rgb.Blue, Green: rgb.Green, Red: rgb.Red}
+}
+
+/*
+Receptor is a single class of photoreceptor (cone). Sensitivity is its
+relative sensitivity in each waveband, and Noise is the standard deviation
+of noise in its signal (e = ω/√η for a Weber fraction ω and a relative
+abundance η of the receptor type).
+*/
+type Receptor struct {
+	Name        string      `json:"name"`
+	Sensitivity Reflectance `json:"sensitivity"`
+	Noise       float64     `json:"noise"`
+}
+
+/*
+VisualSystem is a set of photoreceptors through which colour differences
+are judged with the receptor-noise-limited (RNL) model of Vorobyev &
+Osorio (1998) "Receptor noise as a determinant of colour thresholds".
+Only chromatic differences are modelled: colourations which differ solely
+in brightness are indistinguishable. Scale is the ΔS (in JNDs, just
+noticeable differences) which Difference maps to a ratio of 0.5.
+*/
+type VisualSystem struct {
+	Name      string     `json:"name"`
+	Receptors []Receptor `json:"receptors"`
+	Scale     float64    `json:"scale"`
+}
+
+// VisualSystems holds the built-in visual systems, by name.
+var VisualSystems = map[string]VisualSystem{
+	VisionDichromat: {
+		Name: VisionDichromat,
+		Receptors: []Receptor{
+			{"sws", Reflectance{UV: 0.2, Blue: 1, Green: 0.25, Red: 0}, 0.05},
+			{"lws", Reflectance{UV: 0, Blue: 0.05, Green: 0.8, Red: 1}, 0.05},
+		},
+		Scale: dVisionScale,
+	},
+	VisionTrichromat: {
+		Name: VisionTrichromat,
+		Receptors: []Receptor{
+			{"sws", Reflectance{UV: 0.05, Blue: 1, Green: 0.15, Red: 0}, 0.1},
+			{"mws", Reflectance{UV: 0, Blue: 0.15, Green: 1, Red: 0.45}, 0.07},
+			{"lws", Reflectance{UV: 0, Blue: 0.05, Green: 0.6, Red: 1}, 0.05},
+		},
+		Scale: dVisionScale,
+	},
+	VisionUVTetrachromat: {
+		Name: VisionUVTetrachromat,
+		Receptors: []Receptor{
+			{"uvs", Reflectance{UV: 1, Blue: 0.25, Green: 0, Red: 0}, 0.1},
+			{"sws", Reflectance{UV: 0.15, Blue: 1, Green: 0.2, Red: 0}, 0.0707},
+			{"mws", Reflectance{UV: 0, Blue: 0.2, Green: 1, Red: 0.25}, 0.0707},
+			{"lws", Reflectance{UV: 0, Blue: 0, Green: 0.3, Red: 1}, 0.05},
+		},
+		Scale: dVisionScale,
+	},
+}
+
+// VisualSystemNamed gives the built-in visual system with the given name.
+func VisualSystemNamed(name string) (VisualSystem, error) {
+	vs, ok := VisualSystems[name]
+	if !ok {
+		return VisualSystem{}, errors.New("unknown visual system: " + name)
+	}
+	return vs, nil
+}
+
+// Validate checks that vs can be used to judge colour differences.
+func (vs VisualSystem) Validate() error {
+	if len(vs.Receptors) < 2 {
+		return errors.New("visual system " + vs.Name + ": needs at least two receptors")
+	}
+	for _, r := range vs.Receptors {
+		if r.Noise <= 0 {
+			return errors.New("visual system " + vs.Name + ": receptor " + r.Name + " must have noise > 0")
+		}
+		s := r.Sensitivity
+		if s.UV < 0 || s.Blue < 0 || s.Green < 0 || s.Red < 0 || s.UV+s.Blue+s.Green+s.Red == 0 {
+			return errors.New("visual system " + vs.Name + ": receptor " + r.Name + " must have non-negative, non-zero sensitivity")
+		}
+	}
+	if vs.Scale < 0 {
+		return errors.New("visual system " + vs.Name + ": scale must be ≥ 0")
+	}
+	return nil
+}
+
+// QuantumCatch gives the relative quantum catch of each receptor viewing
+// reflectance r under a flat ("white") illuminant.
+func (vs VisualSystem) QuantumCatch(r Reflectance) []float64 {
+	q := make([]float64, len(vs.Receptors))
+	for i, rc := range vs.Receptors {
+		s := rc.Sensitivity
+		q[i] = (s.UV * r.UV) + (s.Blue * r.Blue) + (s.Green * r.Green) + (s.Red * r.Red)
+	}
+	return q
+}
+
+/*
+DeltaS is the RNL chromatic distance, in JNDs, between reflectances a and
+b. With Δfᵢ the difference in the (log) signal of receptor i and eᵢ its
+noise, it is the general n-receptor form
+
+	ΔS² = Σᵢ<ⱼ (Πₖ≠ᵢ,ⱼ eₖ)² (Δfᵢ - Δfⱼ)² / Σᵢ (Πₖ≠ᵢ eₖ)²
+
+which reduces to the dichromat, trichromat and tetrachromat equations of
+Vorobyev & Osorio (1998).
+*/
+func (vs VisualSystem) DeltaS(a Reflectance, b Reflectance) float64 {
+	n := len(vs.Receptors)
+	if n < 2 {
+		return 0
+	}
+	qa, qb := vs.QuantumCatch(a), vs.QuantumCatch(b)
+	Δf := make([]float64, n)
+	e := make([]float64, n)
+	for i := range vs.Receptors {
+		Δf[i] = math.Log(math.Max(qa[i], minQuantaCatch) / math.Max(qb[i], minQuantaCatch))
+		e[i] = vs.Receptors[i].Noise
+	}
+	// squared product of the noise of every receptor except i and j:
+	product := func(i int, j int) float64 {
+		p := 1.0
+		for k := range e {
+			if k != i && k != j {
+				p *= e[k]
+			}
+		}
+		return p * p
+	}
+	var num, den float64
+	for i := 0; i < n; i++ {
+		den += product(i, -1)
+		for j := i + 1; j < n; j++ {
+			num += product(i, j) * (Δf[i] - Δf[j]) * (Δf[i] - Δf[j])
+		}
+	}
+	if den == 0 {
+		return 0
+	}
+	return math.Sqrt(num / den)
+}
+
+// Difference is DeltaS scaled to a ratio from 0.0 (indistinguishable)
+// towards 1.0, so that it may be used in place of a Metric.
+func (vs VisualSystem) Difference(a Reflectance, b Reflectance) float64 {
+	scale := vs.Scale
+	if scale <= 0 {
+		scale = dVisionScale
+	}
+	ΔS := vs.DeltaS(a, b)
+	return calc.ToFixed(ΔS/(ΔS+scale), 5)
+}
+
+// Metric gives the Difference between colours as seen through vs, for
+// colourations without any UV reflectance.
+func (vs VisualSystem) Metric() Metric {
+	return func(c1 RGB, c2 RGB) float64 {
+		return vs.Difference(c1.Reflectance(0), c2.Reflectance(0))
+	}
+}
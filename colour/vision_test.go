@@ -0,0 +1,53 @@
+package colour
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+func TestVisualSystems(t *testing.T) {
+	for name, vs := range VisualSystems {
+		if err := vs.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		grey, white := RGB{Red: 0.5, Green: 0.5, Blue: 0.5}.Reflectance(0), White.Reflectance(0)
+		if ΔS := vs.DeltaS(grey, white); ΔS > 1e-9 {
+			t.Errorf("%s: ΔS between grey and white == %v, want 0 (achromatic)", name, ΔS)
+		}
+		red, blue := Red.Reflectance(0), Blue.Reflectance(0.5)
+		if ab, ba := vs.DeltaS(red, blue), vs.DeltaS(blue, red); math.Abs(ab-ba) > 1e-9 || ab <= 0 {
+			t.Errorf("%s: ΔS(red, blue) == %v, ΔS(blue, red) == %v", name, ab, ba)
+		}
+		if d := vs.Difference(red, red); d != 0 {
+			t.Errorf("%s: Difference(red, red) == %v, want 0", name, d)
+		}
+	}
+
+	// only a UV-sensitive eye can tell apart colours which differ solely in UV:
+	grey := RGB{Red: 0.5, Green: 0.5, Blue: 0.5}
+	a, b := grey.Reflectance(0.1), grey.Reflectance(0.9)
+	tetra := VisualSystems[VisionUVTetrachromat].DeltaS(a, b)
+	tri := VisualSystems[VisionTrichromat].DeltaS(a, b)
+	if tetra < 1 || tetra < 5*tri {
+		t.Errorf("ΔS for a UV difference: tetrachromat %v, trichromat %v", tetra, tri)
+	}
+
+	if _, err := VisualSystemNamed("compound-eye"); err == nil {
+		t.Error("VisualSystemNamed: expected an error for an unknown visual system")
+	}
+}
+
+func TestDeltaSDichromat(t *testing.T) {
+	vs := VisualSystems[VisionDichromat]
+	a, b := Yellow.Reflectance(0), Blue.Reflectance(0)
+	qa, qb := vs.QuantumCatch(a), vs.QuantumCatch(b)
+	Δf1 := math.Log(qa[0] / qb[0])
+	Δf2 := math.Log(qa[1] / qb[1])
+	e1, e2 := vs.Receptors[0].Noise, vs.Receptors[1].Noise
+	want := math.Abs(Δf1-Δf2) / math.Sqrt((e1*e1)+(e2*e2)) //	Vorobyev & Osorio (1998), eq. 4
+	if got := vs.DeltaS(a, b); calc.ToFixed(got, 6) != calc.ToFixed(want, 6) {
+		t.Errorf("DeltaS == %v, want %v", got, want)
+	}
+}
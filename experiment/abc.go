@@ -0,0 +1,532 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Prior distributions.
+const (
+	PriorUniform = "uniform" //	over [Min, Max] (default)
+	PriorNormal  = "normal"  //	N(Mean, SD²), truncated to [Min, Max] when Max > Min
+)
+
+// Defaults of a Calibration, when none (or zero) is given.
+const (
+	dDrawsPerParticle   = 10  //	Draws = 10 × Particles
+	dQuantile           = 0.5 //	of the previous generation's distances
+	dMaxRunsPerParticle = 100 //	MaxRuns = 100 × Particles
+	maxPerturbations    = 1000
+)
+
+// PosteriorFile is the CSV of the posterior sample of a Calibration, and
+// CalibrationFile its full report, written into its output directory.
+const (
+	PosteriorFile   = "posterior.csv"
+	CalibrationFile = "calibration.json"
+)
+
+// Prior is the prior distribution of a ConditionParams field (by JSON key).
+type Prior struct {
+	Key          string  `json:"key"`
+	Distribution string  `json:"distribution"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	Mean         float64 `json:"mean"`
+	SD           float64 `json:"sd"`
+	Integer      bool    `json:"integer"` //	rounded to the nearest whole number, for integer fields
+}
+
+func (p Prior) check() error {
+	switch {
+	case p.Key == "":
+		return errors.New("prior without a key")
+	case p.Key == "abm-morph-bins":
+		return errors.New("prior on abm-morph-bins: the morph classes must match the observed data")
+	}
+	switch p.Distribution {
+	case "", PriorUniform:
+		if !(p.Max > p.Min) {
+			return fmt.Errorf("prior %s: uniform needs max > min", p.Key)
+		}
+	case PriorNormal:
+		if !(p.SD > 0) {
+			return fmt.Errorf("prior %s: normal needs sd > 0", p.Key)
+		}
+	default:
+		return fmt.Errorf("prior %s: unknown distribution %q", p.Key, p.Distribution)
+	}
+	return nil
+}
+
+func (p Prior) round(x float64) float64 {
+	if p.Integer {
+		return math.Floor(x + 0.5)
+	}
+	return x
+}
+
+func (p Prior) bounded() bool {
+	return p.Max > p.Min
+}
+
+// draw a value at random from the prior.
+func (p Prior) draw(rng *rand.Rand) float64 {
+	if p.Distribution == PriorNormal {
+		for i := 0; i < maxPerturbations; i++ {
+			x := p.round(p.Mean + p.SD*rng.NormFloat64())
+			if p.density(x) > 0 {
+				return x
+			}
+		}
+		return p.round(calc.ClampFloatIn(p.Mean, p.Min, p.Max))
+	}
+	if p.Integer { //	so that both ends are as likely as any other whole number.
+		return math.Min(math.Floor(p.Min+rng.Float64()*(math.Floor(p.Max-p.Min)+1)), p.Max)
+	}
+	return p.Min + rng.Float64()*(p.Max-p.Min)
+}
+
+// density of the prior at x, up to a constant.
+func (p Prior) density(x float64) float64 {
+	if p.bounded() && (x < p.Min || x > p.Max) {
+		return 0
+	}
+	if p.Distribution == PriorNormal {
+		z := (x - p.Mean) / p.SD
+		return math.Exp(-z * z / 2)
+	}
+	return 1
+}
+
+/*
+Calibration fits the Priors to Observed morph frequencies by Approximate
+Bayesian Computation: each parameter set drawn is run once (each run with
+its own RNG seed, from Seed up), and its distance from the observed data is
+the Distance between the observed frequencies and the morphs of the final
+CP Prey population (see abm.BatchSummary.MorphCounts).
+
+ABC rejection draws Draws parameter sets from the priors, and accepts those
+within Tolerance of the observed data or, without a Tolerance, the
+Particles closest. Then each of Generations of ABC-SMC (Beaumont et al.
+2009) narrows the tolerance to the Quantile of the previous generation's
+distances, and perturbs parameter sets resampled from it by a Gaussian
+kernel (of twice their weighted variance) until Particles of them are
+accepted, weighting each by its prior density over its chance of being
+proposed. A generation that takes more than MaxRuns runs is abandoned, and
+the calibration stops with the generation before it as the posterior.
+*/
+type Calibration struct {
+	Priors      []Prior `json:"priors"`
+	Distance    string  `json:"distance"` //	Default = "" (DistanceTotalVariation)
+	Particles   int     `json:"particles"`
+	Draws       int     `json:"draws"`     //	Default = 0 (dDrawsPerParticle × Particles)
+	Tolerance   float64 `json:"tolerance"` //	Default = 0 (accept the Particles closest draws)
+	Generations int     `json:"generations"`
+	Quantile    float64 `json:"quantile"` //	Default = 0 (dQuantile)
+	MaxRuns     int     `json:"max-runs"` //	per generation. Default = 0 (dMaxRunsPerParticle × Particles)
+	Seed        int64   `json:"seed"`
+}
+
+// LoadCalibration reads a JSON-formatted Calibration.
+func LoadCalibration(r io.Reader) (Calibration, error) {
+	var c Calibration
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&c)
+	if err != nil {
+		return c, fmt.Errorf("calibration: %s", err)
+	}
+	return c, c.check()
+}
+
+func (c Calibration) check() error {
+	if len(c.Priors) == 0 {
+		return errors.New("calibration: no priors")
+	}
+	for _, p := range c.Priors {
+		if err := p.check(); err != nil {
+			return fmt.Errorf("calibration: %s", err)
+		}
+	}
+	if err := checkDistance(c.Distance); err != nil {
+		return fmt.Errorf("calibration: %s", err)
+	}
+	switch {
+	case c.Particles < 2:
+		return errors.New("calibration: at least 2 particles are needed")
+	case c.Draws != 0 && c.Draws < c.Particles:
+		return errors.New("calibration: fewer draws than particles")
+	case c.Tolerance < 0 || c.Generations < 0 || c.MaxRuns < 0:
+		return errors.New("calibration: tolerance, generations and max-runs must be ≥ 0")
+	case c.Quantile < 0 || c.Quantile >= 1:
+		return fmt.Errorf("calibration: quantile must be in (0, 1), not %v", c.Quantile)
+	}
+	return nil
+}
+
+func (c Calibration) draws() int {
+	if c.Draws == 0 {
+		return dDrawsPerParticle * c.Particles
+	}
+	return c.Draws
+}
+
+func (c Calibration) quantile() float64 {
+	if c.Quantile == 0 {
+		return dQuantile
+	}
+	return c.Quantile
+}
+
+func (c Calibration) maxRuns() int {
+	if c.MaxRuns == 0 {
+		return dMaxRunsPerParticle * c.Particles
+	}
+	return c.MaxRuns
+}
+
+// Particle is a parameter set accepted by a Calibration.
+type Particle struct {
+	RunID    string    `json:"run-id"`
+	Values   []float64 `json:"values"` //	of each prior, in order
+	Weight   float64   `json:"weight"` //	normalised over its generation
+	Distance float64   `json:"distance"`
+}
+
+// Generation is the population of particles accepted within a tolerance.
+type Generation struct {
+	Tolerance float64    `json:"tolerance"`
+	Runs      int        `json:"runs"`
+	Particles []Particle `json:"particles"`
+}
+
+// Estimate summarises the posterior distribution of a parameter: its
+// weighted mean, standard deviation, median and 95% credible interval.
+type Estimate struct {
+	Key    string  `json:"key"`
+	Mean   float64 `json:"mean"`
+	SD     float64 `json:"sd"`
+	Median float64 `json:"median"`
+	Lower  float64 `json:"lower"` //	2.5% quantile
+	Upper  float64 `json:"upper"` //	97.5% quantile
+}
+
+// Posterior is the result of a Calibration: the particles of its last
+// generation are the posterior sample.
+type Posterior struct {
+	Calibration Calibration  `json:"calibration"`
+	Observed    Observed     `json:"observed"`
+	Generations []Generation `json:"generations"`
+	Estimates   []Estimate   `json:"estimates"`
+	Stopped     string       `json:"stopped,omitempty"` //	why ABC-SMC stopped short of all its Generations
+}
+
+// Final gives the last generation, the posterior sample.
+func (p Posterior) Final() Generation {
+	return p.Generations[len(p.Generations)-1]
+}
+
+// Runner runs every run to completion, giving the results in order (see RunAll).
+type Runner func(runs []Run) []Result
+
+// calibration is the state of a Calibration as it runs.
+type calibration struct {
+	Calibration
+	base     abm.ConditionParams
+	observed Observed
+	runner   Runner
+	rng      *rand.Rand
+	results  []Result
+}
+
+// run the parameter sets θs, giving the distance of each from the observed data.
+func (c *calibration) run(θs [][]float64) ([]Result, []float64, error) {
+	runs := make([]Run, len(θs))
+	for i, θ := range θs {
+		values := make([]Value, len(θ))
+		for k, x := range θ {
+			values[k] = Value{Key: c.Priors[k].Key, Value: number(x)}
+		}
+		conditions, err := Conditions(c.base, values...)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := len(c.results) + i
+		runs[i] = Run{
+			ID:         fmt.Sprintf("run-%05d", n),
+			Values:     values,
+			Conditions: Seeded(conditions, c.Seed+int64(n)),
+		}
+	}
+	results := c.runner(runs)
+	c.results = append(c.results, results...)
+	distances := make([]float64, len(results))
+	for i, r := range results {
+		distances[i] = math.Inf(1)
+		if r.Err == nil {
+			distances[i] = c.observed.Distance(c.Distance, r.Summary.MorphCounts)
+		}
+	}
+	return results, distances, nil
+}
+
+/*
+Calibrate fits the priors to the observed morph frequencies, the runs of
+each step run by runner over base. It gives the posterior, and the result
+of every run in the order they were made.
+*/
+func (c Calibration) Calibrate(base abm.ConditionParams, observed Observed, runner Runner) (Posterior, []Result, error) {
+	posterior := Posterior{Calibration: c, Observed: observed}
+	if err := c.check(); err != nil {
+		return posterior, nil, err
+	}
+	state := &calibration{Calibration: c, base: base, observed: observed, runner: runner, rng: calc.NewRNG(c.Seed)}
+	g, err := state.reject()
+	if err != nil {
+		return posterior, state.results, err
+	}
+	posterior.Generations = append(posterior.Generations, g)
+	for t := 1; t <= c.Generations; t++ {
+		next, err := state.smc(g)
+		if err != nil {
+			posterior.Stopped = fmt.Sprintf("generation %d: %s", t, err)
+			break
+		}
+		g = next
+		posterior.Generations = append(posterior.Generations, g)
+	}
+	posterior.Estimates = estimates(c.Priors, posterior.Final())
+	return posterior, state.results, nil
+}
+
+// reject gives the first generation, by ABC rejection from the priors.
+func (c *calibration) reject() (Generation, error) {
+	θs := make([][]float64, c.draws())
+	for i := range θs {
+		θs[i] = make([]float64, len(c.Priors))
+		for k, p := range c.Priors {
+			θs[i][k] = p.draw(c.rng)
+		}
+	}
+	results, distances, err := c.run(θs)
+	if err != nil {
+		return Generation{}, err
+	}
+	order := make([]int, len(θs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return distances[order[a]] < distances[order[b]] })
+	g := Generation{Tolerance: c.Tolerance, Runs: len(θs)}
+	for _, i := range order {
+		d := distances[i]
+		if math.IsInf(d, 1) || (c.Tolerance > 0 && d > c.Tolerance) || (c.Tolerance == 0 && len(g.Particles) == c.Particles) {
+			break
+		}
+		g.Particles = append(g.Particles, Particle{RunID: results[i].ID, Values: θs[i], Distance: d})
+		if c.Tolerance == 0 {
+			g.Tolerance = d
+		}
+	}
+	if len(g.Particles) == 0 {
+		return g, errors.New("calibration: no draw was accepted")
+	}
+	for i := range g.Particles {
+		g.Particles[i].Weight = 1 / float64(len(g.Particles))
+	}
+	return g, nil
+}
+
+// smc gives the generation after prev, by ABC-SMC.
+func (c *calibration) smc(prev Generation) (Generation, error) {
+	var distances []float64
+	for _, p := range prev.Particles {
+		distances = append(distances, p.Distance)
+	}
+	g := Generation{Tolerance: weightedQuantile(distances, nil, c.quantile())}
+	σ := make([]float64, len(c.Priors))
+	for k := range σ {
+		_, v := weightedMeanVariance(prev.Particles, k)
+		σ[k] = math.Sqrt(2 * v)
+	}
+	for len(g.Particles) < c.Particles {
+		if g.Runs >= c.maxRuns() {
+			return g, fmt.Errorf("%d particles within %v after %d runs", len(g.Particles), g.Tolerance, g.Runs)
+		}
+		θs := make([][]float64, c.Particles)
+		for i := range θs {
+			θ, err := c.perturb(prev, σ)
+			if err != nil {
+				return g, err
+			}
+			θs[i] = θ
+		}
+		results, distances, err := c.run(θs)
+		if err != nil {
+			return g, err
+		}
+		g.Runs += len(θs)
+		for i, d := range distances {
+			if d <= g.Tolerance && len(g.Particles) < c.Particles {
+				g.Particles = append(g.Particles, Particle{RunID: results[i].ID, Values: θs[i], Distance: d})
+			}
+		}
+	}
+	total := 0.0
+	for i, p := range g.Particles {
+		w := 1.0
+		for k, prior := range c.Priors {
+			w *= prior.density(p.Values[k])
+		}
+		proposal := 0.0
+		for _, q := range prev.Particles {
+			proposal += q.Weight * kernel(p.Values, q.Values, σ)
+		}
+		g.Particles[i].Weight = w / proposal
+		total += g.Particles[i].Weight
+	}
+	for i := range g.Particles {
+		g.Particles[i].Weight /= total
+	}
+	return g, nil
+}
+
+// perturb a parameter set resampled from prev, until it has prior density.
+func (c *calibration) perturb(prev Generation, σ []float64) ([]float64, error) {
+	for try := 0; try < maxPerturbations; try++ {
+		u, j := c.rng.Float64(), 0
+		for ; j < len(prev.Particles)-1 && u >= prev.Particles[j].Weight; j++ {
+			u -= prev.Particles[j].Weight
+		}
+		θ := make([]float64, len(c.Priors))
+		density := 1.0
+		for k, p := range c.Priors {
+			θ[k] = p.round(prev.Particles[j].Values[k] + σ[k]*c.rng.NormFloat64())
+			density *= p.density(θ[k])
+		}
+		if density > 0 {
+			return θ, nil
+		}
+	}
+	return nil, errors.New("perturbed particles keep falling outside the priors")
+}
+
+// kernel is the (unnormalised) density of perturbing θ from θʹ.
+func kernel(θ []float64, θʹ []float64, σ []float64) float64 {
+	k := 1.0
+	for i := range θ {
+		if σ[i] == 0 {
+			if θ[i] != θʹ[i] {
+				return 0
+			}
+			continue
+		}
+		z := (θ[i] - θʹ[i]) / σ[i]
+		k *= math.Exp(-z * z / 2)
+	}
+	return k
+}
+
+// weightedMeanVariance of parameter k over the particles.
+func weightedMeanVariance(particles []Particle, k int) (float64, float64) {
+	mean, variance := 0.0, 0.0
+	for _, p := range particles {
+		mean += p.Weight * p.Values[k]
+	}
+	for _, p := range particles {
+		variance += p.Weight * (p.Values[k] - mean) * (p.Values[k] - mean)
+	}
+	return mean, variance
+}
+
+// weightedQuantile gives the q quantile of xs, with the weights (equal if nil).
+func weightedQuantile(xs []float64, weights []float64, q float64) float64 {
+	order := make([]int, len(xs))
+	total := 0.0
+	for i := range order {
+		order[i] = i
+		if weights == nil {
+			total++
+		} else {
+			total += weights[i]
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return xs[order[a]] < xs[order[b]] })
+	cumulative := 0.0
+	for _, i := range order {
+		if weights == nil {
+			cumulative++
+		} else {
+			cumulative += weights[i]
+		}
+		if cumulative >= q*total {
+			return xs[i]
+		}
+	}
+	return xs[order[len(order)-1]]
+}
+
+func estimates(priors []Prior, g Generation) []Estimate {
+	var es []Estimate
+	weights := make([]float64, len(g.Particles))
+	for i, p := range g.Particles {
+		weights[i] = p.Weight
+	}
+	for k, p := range priors {
+		e := Estimate{Key: p.Key}
+		var v float64
+		e.Mean, v = weightedMeanVariance(g.Particles, k)
+		e.SD = math.Sqrt(v)
+		xs := make([]float64, len(g.Particles))
+		for i, particle := range g.Particles {
+			xs[i] = particle.Values[k]
+		}
+		e.Median = weightedQuantile(xs, weights, 0.5)
+		e.Lower = weightedQuantile(xs, weights, 0.025)
+		e.Upper = weightedQuantile(xs, weights, 0.975)
+		es = append(es, e)
+	}
+	return es
+}
+
+// WritePosterior writes the posterior sample as CSV, one row per particle:
+// its run ID, weight and distance, and the value of each parameter.
+func (p Posterior) WritePosterior(w io.Writer) error {
+	header := []string{"run_id", "weight", "distance"}
+	for _, prior := range p.Calibration.Priors {
+		header = append(header, prior.Key)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, particle := range p.Final().Particles {
+		row := []string{particle.RunID, ftoa(particle.Weight), ftoa(particle.Distance)}
+		for _, x := range particle.Values {
+			row = append(row, ftoa(x))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// String gives the estimates of the posterior, one parameter per line.
+func (p Posterior) String() string {
+	s := ""
+	for _, e := range p.Estimates {
+		s += fmt.Sprintf("%s: mean %s, sd %s, 95%% interval [%s, %s]\n", e.Key,
+			strconv.FormatFloat(e.Mean, 'g', 4, 64), strconv.FormatFloat(e.SD, 'g', 4, 64),
+			strconv.FormatFloat(e.Lower, 'g', 4, 64), strconv.FormatFloat(e.Upper, 'g', 4, 64))
+	}
+	return s
+}
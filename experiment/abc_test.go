@@ -0,0 +1,133 @@
+package experiment
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+func TestObserved(t *testing.T) {
+	morphs := stats.NewMorphs(2)
+	observed, err := LoadObserved(strings.NewReader(`morph,red,green,blue,count
+pale,0.9,0.9,0.9,30
+dark,0.1,0.1,0.1,60
+pale-2,0.8,0.7,0.6,10
+`), morphs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if observed[0] != 0.6 || observed[7] != 0.4 {
+		t.Errorf("observed %v", observed)
+	}
+	counts := make([]int, morphs.Len())
+	counts[0], counts[7] = 6, 4
+	if d := observed.Distance("", counts); d > 1e-9 {
+		t.Errorf("distance to itself %v", d)
+	}
+	counts[0], counts[7], counts[1] = 0, 0, 5
+	for _, name := range []string{DistanceTotalVariation, DistanceEuclidean, DistanceHellinger} {
+		if d := observed.Distance(name, counts); math.Abs(d-map[string]float64{
+			DistanceTotalVariation: 1, DistanceEuclidean: math.Sqrt(0.36 + 0.16 + 1), DistanceHellinger: 1,
+		}[name]) > 1e-9 {
+			t.Errorf("%s distance to a disjoint population %v", name, d)
+		}
+	}
+	if d := observed.Distance("", make([]int, morphs.Len())); !math.IsInf(d, 1) {
+		t.Errorf("distance to an extinct population %v", d)
+	}
+
+	for _, csv := range []string{
+		"red,green,blue\n0.1,0.1,0.1\n",
+		"red,green,blue,count\n1.5,0.1,0.1,3\n",
+		"red,green,blue,frequency\n0.5,0.1,0.1,0\n",
+	} {
+		if _, err := LoadObserved(strings.NewReader(csv), morphs); err == nil {
+			t.Errorf("%q: no error", csv)
+		}
+	}
+}
+
+// binomialRunner simulates a final population of 100 prey, each of morph 0
+// with the chance given by the mutation factor and otherwise of morph 1.
+func binomialRunner(runs []Run) []Result {
+	results := make([]Result, len(runs))
+	for i, r := range runs {
+		rng := calc.NewRNG(r.Conditions.RNGSeedVal)
+		counts := make([]int, stats.NewMorphs(r.Conditions.MorphBins).Len())
+		for j := 0; j < 100; j++ {
+			if rng.Float64() < r.Conditions.CpPreyMutationFactor {
+				counts[0]++
+			} else {
+				counts[1]++
+			}
+		}
+		results[i] = Result{Run: r, Summary: abm.BatchSummary{MorphCounts: counts}}
+	}
+	return results
+}
+
+func TestCalibrate(t *testing.T) {
+	c, err := LoadCalibration(strings.NewReader(`{
+		"priors": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}],
+		"particles": 50,
+		"generations": 3,
+		"seed": 2
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := make(Observed, stats.NewMorphs(abm.TestConditionParams.MorphBins).Len())
+	observed[0], observed[1] = 0.3, 0.7
+	posterior, results, err := c.Calibrate(abm.TestConditionParams, observed, binomialRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posterior.Generations) != 4 || posterior.Stopped != "" {
+		t.Fatalf("%d generations, stopped: %s", len(posterior.Generations), posterior.Stopped)
+	}
+	runs := 0
+	for i, g := range posterior.Generations {
+		runs += g.Runs
+		total := 0.0
+		for _, p := range g.Particles {
+			total += p.Weight
+			if p.Distance > g.Tolerance {
+				t.Errorf("generation %d: particle %s at %v beyond the tolerance %v", i, p.RunID, p.Distance, g.Tolerance)
+			}
+		}
+		if len(g.Particles) != 50 || math.Abs(total-1) > 1e-9 {
+			t.Errorf("generation %d: %d particles, weights summing to %v", i, len(g.Particles), total)
+		}
+		if i > 0 && g.Tolerance > posterior.Generations[i-1].Tolerance {
+			t.Errorf("generation %d: tolerance rose to %v", i, g.Tolerance)
+		}
+	}
+	if runs != len(results) || results[0].Conditions.RNGSeedVal != 2 || results[1].Conditions.RNGSeedVal != 3 {
+		t.Errorf("%d runs, %d results", runs, len(results))
+	}
+	e := posterior.Estimates[0]
+	if math.Abs(e.Mean-0.3) > 0.05 || e.SD > 0.1 || e.Lower > 0.3 || e.Upper < 0.3 {
+		t.Errorf("estimate %+v", e)
+	}
+
+	again, _, _ := c.Calibrate(abm.TestConditionParams, observed, binomialRunner)
+	if again.Estimates[0] != e {
+		t.Errorf("estimate %+v, then %+v from the same seed", e, again.Estimates[0])
+	}
+
+	for _, spec := range []string{
+		`{"priors": [{"key": "abm-cp-prey-mf", "min": 1, "max": 0}], "particles": 10}`,
+		`{"priors": [{"key": "abm-cp-prey-mf", "distribution": "normal", "mean": 0.1}], "particles": 10}`,
+		`{"priors": [{"key": "abm-morph-bins", "min": 2, "max": 4}], "particles": 10}`,
+		`{"priors": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}], "particles": 10, "distance": "kl"}`,
+		`{"priors": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}], "particles": 10, "quantile": 1}`,
+	} {
+		if _, err := LoadCalibration(strings.NewReader(spec)); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+	}
+}
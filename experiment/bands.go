@@ -0,0 +1,193 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Files of the aggregated series of replicate runs, written into the output
+// directory of an experiment: BandsJSONFile can be opened in the web UI.
+const (
+	BandsFile     = "bands.csv"
+	BandsJSONFile = "bands.json"
+)
+
+// DefaultQuantiles bound the band of each metric when no others are given.
+var DefaultQuantiles = []float64{0.05, 0.95}
+
+// Band is the distribution of a metric over the replicates still running at a turn.
+type Band struct {
+	Turn      int       `json:"turn"`
+	Metric    string    `json:"metric"`
+	Runs      int       `json:"runs"`
+	Mean      float64   `json:"mean"`
+	Median    float64   `json:"median"`
+	Quantiles []float64 `json:"quantiles"` //	of the Bands, in order
+}
+
+/*
+Bands aggregates the per-turn summary series of replicate runs of the same
+parameter Values: at every turn, for every metric of summary.csv, the mean,
+median and Quantiles over the runs that reached it. A run ending early (as
+when a population becomes extinct) drops out of the turns after it.
+*/
+type Bands struct {
+	Values    []Value   `json:"values"`
+	Quantiles []float64 `json:"quantiles"`
+	Bands     []Band    `json:"bands"` //	by turn, then metric in the order of summary.csv
+}
+
+/*
+Aggregate gives the Bands of the series of each replicate run, at the
+quantiles (DefaultQuantiles when none are given). Quantiles interpolate
+linearly between the ordered values.
+*/
+func Aggregate(series [][]abm.TurnSummary, quantiles []float64) Bands {
+	if len(quantiles) == 0 {
+		quantiles = DefaultQuantiles
+	}
+	b := Bands{Quantiles: quantiles}
+	names, _ := abm.TurnSummary{}.Columns()
+	byTurn := make(map[int][][]float64) //	values of each metric at the turn
+	for _, s := range series {
+		for _, summary := range s {
+			values, ok := byTurn[summary.Turn]
+			if !ok {
+				values = make([][]float64, len(names))
+			}
+			_, row := summary.Columns()
+			for i := range names {
+				f, _ := strconv.ParseFloat(row[i], 64)
+				values[i] = append(values[i], f)
+			}
+			byTurn[summary.Turn] = values
+		}
+	}
+	var turns []int
+	for turn := range byTurn {
+		turns = append(turns, turn)
+	}
+	sort.Ints(turns)
+	for _, turn := range turns {
+		for i, name := range names {
+			if name == "turn" {
+				continue
+			}
+			xs := byTurn[turn][i]
+			sort.Float64s(xs)
+			band := Band{Turn: turn, Metric: name, Runs: len(xs), Median: quantile(xs, 0.5)}
+			band.Mean, _ = calc.MeanVariance(xs)
+			for _, q := range quantiles {
+				band.Quantiles = append(band.Quantiles, quantile(xs, q))
+			}
+			b.Bands = append(b.Bands, band)
+		}
+	}
+	return b
+}
+
+/*
+AggregateResults gives the Bands of each group of replicate results with the
+same parameter values, in the order each group first appears, over the
+series of the runs that succeeded.
+*/
+func AggregateResults(results []Result, quantiles []float64) []Bands {
+	var groups [][]Result
+	index := make(map[string]int)
+	for _, r := range results {
+		key := ""
+		for _, v := range r.Values {
+			key += v.Key + "=" + string(v.Value) + ";"
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	var bands []Bands
+	for _, group := range groups {
+		var series [][]abm.TurnSummary
+		for _, r := range group {
+			if r.Err == nil {
+				series = append(series, r.Summary.Series)
+			}
+		}
+		b := Aggregate(series, quantiles)
+		b.Values = group[0].Values
+		bands = append(bands, b)
+	}
+	return bands
+}
+
+// quantile q of the sorted xs, interpolating linearly between them.
+func quantile(xs []float64, q float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	h := q * float64(len(xs)-1)
+	lo := int(math.Floor(h))
+	if lo+1 >= len(xs) {
+		return xs[len(xs)-1]
+	}
+	return xs[lo] + (h-float64(lo))*(xs[lo+1]-xs[lo])
+}
+
+// quantileColumn names the column of quantile q, e.g. "q5" for 0.05.
+func quantileColumn(q float64) string {
+	return "q" + strconv.FormatFloat(q*100, 'g', -1, 64)
+}
+
+/*
+WriteBands writes a CSV of the bands, one row per group, turn and metric:
+the value of each parameter varied, the turn, metric and number of runs,
+and the mean, median and each quantile (e.g. "q5" and "q95").
+*/
+func WriteBands(w io.Writer, bands []Bands) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	if len(bands) > 0 {
+		for _, v := range bands[0].Values {
+			header = append(header, v.Key)
+		}
+		header = append(header, "turn", "metric", "runs", "mean", "median")
+		for _, q := range bands[0].Quantiles {
+			header = append(header, quantileColumn(q))
+		}
+	}
+	cw.Write(header)
+	for _, b := range bands {
+		var values []string
+		for _, v := range b.Values {
+			values = append(values, v.String())
+		}
+		for _, band := range b.Bands {
+			row := append(values[:len(values):len(values)], strconv.Itoa(band.Turn), band.Metric, strconv.Itoa(band.Runs), ftoa(band.Mean), ftoa(band.Median))
+			for _, q := range band.Quantiles {
+				row = append(row, ftoa(q))
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CheckQuantiles verifies every quantile is in [0, 1].
+func CheckQuantiles(quantiles []float64) error {
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			return fmt.Errorf("quantile %v is not in [0, 1]", q)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+)
+
+func TestAggregate(t *testing.T) {
+	series := func(pops ...int) []abm.TurnSummary {
+		var s []abm.TurnSummary
+		for turn, pop := range pops {
+			s = append(s, abm.TurnSummary{Turn: turn, CpPreyPopulation: pop})
+		}
+		return s
+	}
+	var results []Result
+	for r, pops := range [][]int{{10, 20, 30}, {10, 40}, {10, 60, 90}, {10, 80, 10}, {10, 100}} {
+		result := Result{Run: Run{Values: []Value{{Key: "abm-cp-prey-mf", Value: []byte("0.1")}}}}
+		result.Summary.Series = series(pops...)
+		if r == 4 {
+			result.Values = []Value{{Key: "abm-cp-prey-mf", Value: []byte("0.2")}}
+		}
+		results = append(results, result)
+	}
+	bands := AggregateResults(results, []float64{0.25, 0.75})
+	if len(bands) != 2 {
+		t.Fatalf("%d groups, want 2", len(bands))
+	}
+	var pop []Band
+	for _, b := range bands[0].Bands {
+		if b.Metric == "cp_prey_pop" {
+			pop = append(pop, b)
+		}
+	}
+	if len(pop) != 3 {
+		t.Fatalf("%d turns, want 3", len(pop))
+	}
+	// turn 1: 20, 40, 60, 80
+	if b := pop[1]; b.Runs != 4 || b.Mean != 50 || b.Median != 50 || b.Quantiles[0] != 35 || b.Quantiles[1] != 65 {
+		t.Errorf("turn 1: %+v", b)
+	}
+	// turn 2: 10, 30, 90, the second run having ended.
+	if b := pop[2]; b.Runs != 3 || b.Median != 30 || b.Quantiles[0] != 20 || b.Quantiles[1] != 60 {
+		t.Errorf("turn 2: %+v", b)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBands(&buf, bands); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"abm-cp-prey-mf", "turn", "metric", "runs", "mean", "median", "q25", "q75"}
+	for i, h := range header {
+		if rows[0][i] != h {
+			t.Errorf("header %v, want %v", rows[0], header)
+			break
+		}
+	}
+	if got := len(rows) - 1; got != len(bands[0].Bands)+len(bands[1].Bands) {
+		t.Errorf("%d rows", got)
+	}
+	if rows[len(rows)-1][0] != "0.2" {
+		t.Errorf("last row %v", rows[len(rows)-1])
+	}
+}
@@ -0,0 +1,49 @@
+package experiment
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+)
+
+// MetricApostatic is the mean coefficient of apostatic selection over a run.
+const MetricApostatic = "apostatic_coefficient"
+
+/*
+Metrics gives the outputs of a run by name: the columns of its final
+summary, as in summary.csv (e.g. "cp_prey_shannon" for the diversity of
+prey colouration), and MetricApostatic for the frequency dependence of
+predation. MetricApostatic is NaN when undefined, as when there was no
+predation, or only one morph: Analyse leaves such a run out.
+*/
+func Metrics(s abm.BatchSummary) map[string]float64 {
+	names, values := s.Final.Columns()
+	metrics := make(map[string]float64, len(names)+1)
+	for i, name := range names {
+		metrics[name], _ = strconv.ParseFloat(values[i], 64)
+	}
+	metrics[MetricApostatic] = math.NaN()
+	if s.Apostatic != nil {
+		metrics[MetricApostatic] = *s.Apostatic
+	}
+	return metrics
+}
+
+// checkMetrics verifies that every name is one of the Metrics.
+func checkMetrics(names []string) error {
+	known := Metrics(abm.BatchSummary{})
+	for _, name := range names {
+		if _, ok := known[name]; !ok {
+			var all []string
+			for n := range known {
+				all = append(all, n)
+			}
+			sort.Strings(all)
+			return fmt.Errorf("unknown output %q: must be one of %q", name, all)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+	"github.com/benjamin-rood/abm-cp/stats"
+)
+
+// Distances between observed and simulated morph frequencies.
+const (
+	DistanceTotalVariation = "total-variation" //	½ Σ |pᵢ - qᵢ|, in [0, 1] (default)
+	DistanceEuclidean      = "euclidean"       //	√Σ (pᵢ - qᵢ)²
+	DistanceHellinger      = "hellinger"       //	√(½ Σ (√pᵢ - √qᵢ)²), in [0, 1]
+)
+
+// Observed is the frequency of each morph class (see stats.Morphs) in an observed population.
+type Observed []float64
+
+/*
+LoadObserved reads observed morph frequencies from CSV. The header names
+the columns "red", "green" and "blue", giving a morph's colouration (each
+channel in [0, 1], as colour.RGB), and either "count" or "frequency"; any
+other columns (e.g. a morph's name) are ignored. Each row is counted into
+the morph class of its colouration, and the classes normalised to
+frequencies summing to 1.
+*/
+func LoadObserved(r io.Reader, morphs stats.Morphs) (Observed, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("observed: %s", err)
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("observed: no morphs")
+	}
+	column := make(map[string]int)
+	for i, name := range rows[0] {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var cols []int
+	for _, name := range []string{"red", "green", "blue"} {
+		i, ok := column[name]
+		if !ok {
+			return nil, fmt.Errorf("observed: no %q column", name)
+		}
+		cols = append(cols, i)
+	}
+	n, ok := column["count"]
+	if !ok {
+		n, ok = column["frequency"]
+	}
+	if !ok {
+		return nil, errors.New(`observed: no "count" or "frequency" column`)
+	}
+	cols = append(cols, n)
+
+	observed := make(Observed, morphs.Len())
+	total := 0.0
+	for line, row := range rows[1:] {
+		var v [4]float64
+		for j, i := range cols {
+			if i >= len(row) {
+				return nil, fmt.Errorf("observed: line %d: too few columns", line+2)
+			}
+			v[j], err = strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("observed: line %d: %s", line+2, err)
+			}
+			if v[j] < 0 || (j < 3 && v[j] > 1) {
+				return nil, fmt.Errorf("observed: line %d: %s = %v out of range", line+2, rows[0][i], v[j])
+			}
+		}
+		observed[morphs.Of(colour.RGB{Red: v[0], Green: v[1], Blue: v[2]})] += v[3]
+		total += v[3]
+	}
+	if total == 0 {
+		return nil, errors.New("observed: no individuals")
+	}
+	for i := range observed {
+		observed[i] /= total
+	}
+	return observed, nil
+}
+
+// checkDistance verifies the name of a distance.
+func checkDistance(name string) error {
+	switch name {
+	case "", DistanceTotalVariation, DistanceEuclidean, DistanceHellinger:
+		return nil
+	}
+	return fmt.Errorf("unknown distance %q", name)
+}
+
+/*
+Distance gives the named distance between the observed frequencies and the
+simulated counts of each morph class. With no simulated population (or a
+different number of morph classes) the distance is +Inf.
+*/
+func (o Observed) Distance(name string, counts []int) float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 || len(counts) != len(o) {
+		return math.Inf(1)
+	}
+	d := 0.0
+	for i, n := range counts {
+		p, q := o[i], float64(n)/float64(total)
+		switch name {
+		case DistanceEuclidean:
+			d += (p - q) * (p - q)
+		case DistanceHellinger:
+			d += (math.Sqrt(p) - math.Sqrt(q)) * (math.Sqrt(p) - math.Sqrt(q))
+		default:
+			d += math.Abs(p - q)
+		}
+	}
+	switch name {
+	case DistanceEuclidean:
+		return math.Sqrt(d)
+	case DistanceHellinger:
+		return math.Sqrt(d / 2)
+	}
+	return d / 2
+}
@@ -0,0 +1,117 @@
+/*
+Package experiment runs many independent, headless abm.Models over
+variations of a set of ConditionParams: parameter sweeps, sampling designs
+and replicates.
+*/
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+)
+
+// Value sets the ConditionParams field with the JSON key Key.
+type Value struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Run is one headless model run of an experiment.
+type Run struct {
+	ID         string              `json:"id"`        //	also names its output subdirectory
+	Replicate  int                 `json:"replicate"` //	of the same Values, each with its own seed
+	Values     []Value             `json:"values"`    //	the parameters varied, in the order of the experiment
+	Conditions abm.ConditionParams `json:"-"`
+	Series     bool                `json:"-"` //	keep its per-turn summaries (see abm.Model.CollectSeries), for Bands
+}
+
+// Result is the outcome of a Run.
+type Result struct {
+	Run
+	Summary abm.BatchSummary
+	Err     error
+}
+
+/*
+Conditions gives a copy of base with each of the values set. The copy shares
+nothing with base, and is validated (see abm.ConditionParams.Validate).
+*/
+func Conditions(base abm.ConditionParams, values ...Value) (abm.ConditionParams, error) {
+	var c abm.ConditionParams
+	raw, err := json.Marshal(base)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return c, err
+	}
+	for _, v := range values {
+		patch, err := json.Marshal(map[string]json.RawMessage{v.Key: v.Value})
+		if err != nil {
+			return c, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(patch))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+		if err != nil {
+			return c, fmt.Errorf("%s = %s: %s", v.Key, v.Value, err)
+		}
+	}
+	return c, c.Validate()
+}
+
+// Seeded sets the RNG seed of c, so that its run can be reproduced.
+func Seeded(c abm.ConditionParams, seed int64) abm.ConditionParams {
+	c.RNGRandomSeed = false
+	c.RNGSeedVal = seed
+	return c
+}
+
+/*
+RunAll runs every run to completion, at most workers of them at once (at
+least one), each with its own Model logging into the subdirectory of dir
+named by its ID. done, if not nil, is called as each run finishes (never
+concurrently). The results are in the order of runs.
+*/
+func RunAll(runs []Run, dir string, workers int, done func(Result)) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(runs))
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := Result{Run: runs[i]}
+				m := abm.NewModel()
+				m.ConditionParams = runs[i].Conditions
+				m.SessionIdentifier = runs[i].ID
+				m.OutputDir = filepath.Join(dir, runs[i].ID)
+				m.CollectSeries = runs[i].Series
+				r.Summary, r.Err = m.RunBatch()
+				mu.Lock()
+				results[i] = r
+				if done != nil {
+					done(r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range runs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
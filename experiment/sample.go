@@ -0,0 +1,106 @@
+package experiment
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+/*
+LatinHypercube gives n points in the unit hypercube of d dimensions, such
+that along every dimension each of the n equal strata of [0, 1) holds
+exactly one point.
+*/
+func LatinHypercube(n int, d int, rng *rand.Rand) [][]float64 {
+	points := make([][]float64, n)
+	for i := range points {
+		points[i] = make([]float64, d)
+	}
+	for j := 0; j < d; j++ {
+		for i, stratum := range rng.Perm(n) {
+			points[i][j] = (float64(stratum) + rng.Float64()) / float64(n)
+		}
+	}
+	return points
+}
+
+// sobolPolynomial is the primitive polynomial (of degree s, with the
+// coefficients a) and initial direction numbers m of a Sobol dimension.
+type sobolPolynomial struct {
+	s uint
+	a uint32
+	m []uint32
+}
+
+// sobolPolynomials of the dimensions after the first, from Joe & Kuo (2008).
+var sobolPolynomials = []sobolPolynomial{
+	{1, 0, []uint32{1}},
+	{2, 1, []uint32{1, 3}},
+	{3, 1, []uint32{1, 3, 1}},
+	{3, 2, []uint32{1, 1, 1}},
+	{4, 1, []uint32{1, 1, 3, 3}},
+	{4, 4, []uint32{1, 3, 5, 13}},
+	{5, 2, []uint32{1, 1, 5, 5, 17}},
+	{5, 4, []uint32{1, 1, 5, 5, 5}},
+	{5, 7, []uint32{1, 1, 7, 11, 19}},
+	{5, 11, []uint32{1, 1, 5, 1, 1}},
+	{5, 13, []uint32{1, 1, 1, 3, 11}},
+	{5, 14, []uint32{1, 3, 5, 5, 31}},
+	{6, 1, []uint32{1, 3, 3, 9, 7, 49}},
+	{6, 13, []uint32{1, 1, 1, 15, 21, 21}},
+	{6, 16, []uint32{1, 3, 1, 13, 27, 49}},
+	{6, 19, []uint32{1, 1, 1, 15, 7, 5}},
+	{6, 22, []uint32{1, 3, 1, 15, 13, 25}},
+	{6, 25, []uint32{1, 1, 5, 5, 19, 61}},
+	{7, 1, []uint32{1, 3, 7, 11, 23, 15, 103}},
+	{7, 4, []uint32{1, 3, 7, 13, 13, 15, 69}},
+}
+
+// MaxSobolDimensions is the most dimensions Sobol can give.
+var MaxSobolDimensions = len(sobolPolynomials) + 1
+
+const sobolBits = 32
+
+/*
+Sobol gives the first n points of the Sobol low-discrepancy sequence in d
+dimensions, beginning with the origin: for every k, the first 2^k points
+divide each dimension into 2^k equal strata holding one point each.
+*/
+func Sobol(n int, d int) ([][]float64, error) {
+	if d > MaxSobolDimensions {
+		return nil, fmt.Errorf("Sobol sequence of %d dimensions: at most %d are available", d, MaxSobolDimensions)
+	}
+	v := make([][sobolBits]uint32, d) //	direction numbers
+	for k := uint(0); k < sobolBits; k++ {
+		v[0][k] = 1 << (sobolBits - 1 - k)
+	}
+	for j := 1; j < d; j++ {
+		p := sobolPolynomials[j-1]
+		for k := uint(0); k < p.s; k++ {
+			v[j][k] = p.m[k] << (sobolBits - 1 - k)
+		}
+		for k := p.s; k < sobolBits; k++ {
+			v[j][k] = v[j][k-p.s] ^ (v[j][k-p.s] >> p.s)
+			for l := uint(1); l < p.s; l++ {
+				v[j][k] ^= ((p.a >> (p.s - 1 - l)) & 1) * v[j][k-l]
+			}
+		}
+	}
+	points := make([][]float64, n)
+	x := make([]uint32, d)
+	for i := range points {
+		if i > 0 {
+			c := uint(0) //	the lowest zero bit of i-1 (Gray code order)
+			for b := i - 1; b&1 == 1; b >>= 1 {
+				c++
+			}
+			for j := range x {
+				x[j] ^= v[j][c]
+			}
+		}
+		points[i] = make([]float64, d)
+		for j := range x {
+			points[i][j] = float64(x[j]) / (1 << sobolBits)
+		}
+	}
+	return points, nil
+}
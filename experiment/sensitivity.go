@@ -0,0 +1,424 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Methods and samplers of a Sensitivity analysis.
+const (
+	MethodSobol  = "sobol"  //	first- and total-order Sobol indices, by Saltelli's scheme
+	MethodMorris = "morris" //	Morris elementary effects, along random one-at-a-time trajectories
+	SamplerSobol = "sobol"  //	Sobol low-discrepancy sequence (default)
+	SamplerLHS   = "lhs"    //	Latin hypercube
+
+	dMorrisLevels = 4
+)
+
+// Files of the report of a Sensitivity analysis, written into its output directory.
+const (
+	SensitivityFile    = "sensitivity.json"
+	SensitivityCSVFile = "sensitivity.csv"
+)
+
+// Range is a ConditionParams field (by JSON key) sampled uniformly from [Min, Max].
+type Range struct {
+	Key     string  `json:"key"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Integer bool    `json:"integer"` //	rounded to the nearest whole number, for integer fields
+}
+
+// value gives the parameter value at u of its range, for u in [0, 1].
+func (r Range) value(u float64) Value {
+	f := r.Min + u*(r.Max-r.Min)
+	if r.Integer {
+		f = math.Floor(f + 0.5)
+	}
+	return Value{Key: r.Key, Value: number(f)}
+}
+
+/*
+Sensitivity is a global sensitivity analysis of the Outputs (see Metrics)
+to the Parameters, each varied over its Range.
+
+With MethodSobol, Samples base points are drawn by the Sampler for each of
+two matrices A and B, and each output is found at every point of A, of B,
+and of A with each parameter in turn taken from B: Samples × (parameters+2)
+design points. The first-order index of a parameter is the share of the
+variance of an output due to it alone (Saltelli 2010), and the total-order
+index the share due to it including all its interactions (Jansen 1999).
+
+With MethodMorris, Samples trajectories through a grid of Levels values per
+parameter each step one parameter at a time, from a random point:
+Samples × (parameters+1) design points. The mean (μ), mean absolute (μ*) and
+standard deviation (σ) of each parameter's elementary effects, in units of
+its range, rank its influence, and its non-linearity or interactions.
+
+Each design point is run Replicates times (at least once), replicate r with
+the RNG seed Seed+r, and its outputs averaged over them. Seed also draws
+the design.
+*/
+type Sensitivity struct {
+	Method     string   `json:"method"`
+	Sampler    string   `json:"sampler"`
+	Samples    int      `json:"samples"`
+	Levels     int      `json:"levels"` //	of MethodMorris. Default = 0 (dMorrisLevels)
+	Parameters []Range  `json:"parameters"`
+	Outputs    []string `json:"outputs"`
+	Replicates int      `json:"replicates"`
+	Seed       int64    `json:"seed"`
+}
+
+// LoadSensitivity reads a JSON-formatted Sensitivity analysis.
+func LoadSensitivity(r io.Reader) (Sensitivity, error) {
+	var s Sensitivity
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&s)
+	if err != nil {
+		return s, fmt.Errorf("sensitivity: %s", err)
+	}
+	return s, s.check()
+}
+
+func (s Sensitivity) check() error {
+	if len(s.Parameters) == 0 {
+		return errors.New("sensitivity: no parameters")
+	}
+	for _, p := range s.Parameters {
+		if p.Key == "" || !(p.Max > p.Min) {
+			return fmt.Errorf("sensitivity: parameter %q needs a key and max > min", p.Key)
+		}
+	}
+	if len(s.Outputs) == 0 {
+		return errors.New("sensitivity: no outputs")
+	}
+	if err := checkMetrics(s.Outputs); err != nil {
+		return fmt.Errorf("sensitivity: %s", err)
+	}
+	switch s.Method {
+	case MethodSobol:
+		if s.Samples < 2 {
+			return errors.New("sensitivity: at least 2 samples are needed")
+		}
+		switch s.Sampler {
+		case "", SamplerSobol:
+			if 2*len(s.Parameters) > MaxSobolDimensions {
+				return fmt.Errorf("sensitivity: at most %d parameters can be sampled from a Sobol sequence; use the %q sampler", MaxSobolDimensions/2, SamplerLHS)
+			}
+		case SamplerLHS:
+		default:
+			return fmt.Errorf("sensitivity: unknown sampler %q", s.Sampler)
+		}
+	case MethodMorris:
+		if s.Samples < 2 {
+			return errors.New("sensitivity: at least 2 trajectories are needed")
+		}
+		if s.Levels != 0 && (s.Levels < 2 || s.Levels%2 != 0) {
+			return fmt.Errorf("sensitivity: levels must be even, not %d", s.Levels)
+		}
+	default:
+		return fmt.Errorf("sensitivity: unknown method %q", s.Method)
+	}
+	return nil
+}
+
+func (s Sensitivity) levels() int {
+	if s.Levels == 0 {
+		return dMorrisLevels
+	}
+	return s.Levels
+}
+
+// Δ is the step of a Morris trajectory, in units of the parameter ranges.
+func (s Sensitivity) Δ() float64 {
+	p := float64(s.levels())
+	return p / (2 * (p - 1))
+}
+
+func (s Sensitivity) replicates() int {
+	if s.Replicates < 1 {
+		return 1
+	}
+	return s.Replicates
+}
+
+/*
+design gives the design points in the unit hypercube, in the order they
+are run, and for MethodMorris the order in which each trajectory steps the
+parameters.
+*/
+func (s Sensitivity) design() ([][]float64, [][]int, error) {
+	d := len(s.Parameters)
+	rng := calc.NewRNG(s.Seed)
+	switch s.Method {
+	case MethodSobol:
+		var base [][]float64
+		if s.Sampler == SamplerLHS {
+			base = LatinHypercube(s.Samples, 2*d, rng)
+		} else {
+			seq, err := Sobol(s.Samples+1, 2*d)
+			if err != nil {
+				return nil, nil, err
+			}
+			base = seq[1:] //	the origin would be the same point in A and B.
+		}
+		var points [][]float64
+		for _, ab := range base {
+			a, b := ab[:d], ab[d:]
+			points = append(points, a, b)
+			for i := 0; i < d; i++ {
+				abi := append([]float64(nil), a...)
+				abi[i] = b[i]
+				points = append(points, abi)
+			}
+		}
+		return points, nil, nil
+	case MethodMorris:
+		p, Δ := s.levels(), s.Δ()
+		var points [][]float64
+		var orders [][]int
+		for t := 0; t < s.Samples; t++ {
+			x := make([]float64, d)
+			for i := range x {
+				x[i] = float64(rng.Intn(p/2)) / float64(p-1) //	so that x+Δ is still on the grid.
+			}
+			points = append(points, append([]float64(nil), x...))
+			order := rng.Perm(d)
+			for _, i := range order {
+				x[i] += Δ
+				points = append(points, append([]float64(nil), x...))
+			}
+			orders = append(orders, order)
+		}
+		return points, orders, nil
+	}
+	return nil, nil, s.check()
+}
+
+// Runs gives every run of the analysis over base, replicates of a design point together.
+func (s Sensitivity) Runs(base abm.ConditionParams) ([]Run, error) {
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+	points, _, err := s.design()
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, u := range points {
+		values := make([]Value, len(u))
+		for j := range u {
+			values[j] = s.Parameters[j].value(u[j])
+		}
+		for r := 0; r < s.replicates(); r++ {
+			c, err := Conditions(base, values...)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, Run{
+				ID:         fmt.Sprintf("run-%05d", len(runs)),
+				Replicate:  r,
+				Values:     values,
+				Conditions: Seeded(c, s.Seed+int64(r)),
+			})
+		}
+	}
+	return runs, nil
+}
+
+// SobolIndices are the share of the variance of an output due to a parameter.
+type SobolIndices struct {
+	First float64 `json:"first-order"`
+	Total float64 `json:"total-order"`
+}
+
+// ElementaryEffects summarise the effects on an output of a parameter's steps.
+type ElementaryEffects struct {
+	Mu     float64 `json:"mu"`
+	MuStar float64 `json:"mu-star"`
+	Sigma  float64 `json:"sigma"`
+}
+
+// ParameterSensitivity is the sensitivity of an output to a parameter, by the method of the analysis.
+type ParameterSensitivity struct {
+	Key    string             `json:"key"`
+	Sobol  *SobolIndices      `json:"sobol,omitempty"`
+	Morris *ElementaryEffects `json:"morris,omitempty"`
+}
+
+// OutputSensitivity is the sensitivity of an output to each parameter,
+// with the mean and variance of the output over the design.
+type OutputSensitivity struct {
+	Output     string                 `json:"output"`
+	Mean       float64                `json:"mean"`
+	Variance   float64                `json:"variance"`
+	Missing    int                    `json:"missing"` //	design points where the output was undefined in every replicate
+	Parameters []ParameterSensitivity `json:"parameters"`
+}
+
+// Report is the result of a Sensitivity analysis.
+type Report struct {
+	Analysis Sensitivity         `json:"analysis"`
+	Runs     int                 `json:"runs"`
+	Outputs  []OutputSensitivity `json:"outputs"`
+}
+
+/*
+Analyse computes the sensitivity of each output from the results of the
+Runs of the analysis, in order. A design point with no successful replicate
+is an error. An output undefined in a replicate (NaN, see Metrics) is left
+out of the mean over the replicates of its point, and a point where it is
+undefined in all of them is missing: the estimates only use the base
+samples (Sobol) or steps (Morris) without a missing point.
+*/
+func (s Sensitivity) Analyse(results []Result) (Report, error) {
+	report := Report{Analysis: s, Runs: len(results)}
+	points, orders, err := s.design()
+	if err != nil {
+		return report, err
+	}
+	reps := s.replicates()
+	if len(results) != len(points)*reps {
+		return report, fmt.Errorf("sensitivity: %d results for %d runs", len(results), len(points)*reps)
+	}
+	ys := make([][]float64, len(s.Outputs)) //	of each output at each point, NaN where missing
+	for o := range ys {
+		ys[o] = make([]float64, len(points))
+	}
+	for p := range points {
+		ok := false
+		defined := make([]int, len(s.Outputs))
+		for _, r := range results[p*reps : (p+1)*reps] {
+			if r.Err != nil {
+				continue
+			}
+			ok = true
+			metrics := Metrics(r.Summary)
+			for o, name := range s.Outputs {
+				if !math.IsNaN(metrics[name]) {
+					ys[o][p] += metrics[name]
+					defined[o]++
+				}
+			}
+		}
+		if !ok {
+			r := results[p*reps]
+			return report, fmt.Errorf("sensitivity: every replicate of %s failed: %s", r.ID, r.Err)
+		}
+		for o := range ys {
+			ys[o][p] /= float64(defined[o]) //	NaN when none.
+		}
+	}
+	d := len(s.Parameters)
+	for o, name := range s.Outputs {
+		out := OutputSensitivity{Output: name}
+		var defined []float64
+		for _, y := range ys[o] {
+			if math.IsNaN(y) {
+				out.Missing++
+				continue
+			}
+			defined = append(defined, y)
+		}
+		out.Mean, out.Variance = calc.MeanVariance(defined)
+		for i, p := range s.Parameters {
+			ps := ParameterSensitivity{Key: p.Key}
+			switch s.Method {
+			case MethodSobol:
+				ps.Sobol = sobolIndices(ys[o], d, i)
+			case MethodMorris:
+				ps.Morris = elementaryEffects(ys[o], orders, i, s.Δ())
+			}
+			out.Parameters = append(out.Parameters, ps)
+		}
+		report.Outputs = append(report.Outputs, out)
+	}
+	return report, nil
+}
+
+/*
+sobolIndices estimates the indices of parameter i from the outputs y of the
+Saltelli design of d parameters: for each base sample, f(A), f(B), then
+f(AB) with each parameter from B. Base samples with any of the three missing
+(NaN) are left out. Without variance, both are 0.
+*/
+func sobolIndices(y []float64, d int, i int) *SobolIndices {
+	stride := d + 2
+	n := 0
+	var ab []float64
+	first, total := 0.0, 0.0
+	for k := 0; k < len(y)/stride; k++ {
+		fA, fB, fABi := y[k*stride], y[k*stride+1], y[k*stride+2+i]
+		if math.IsNaN(fA) || math.IsNaN(fB) || math.IsNaN(fABi) {
+			continue
+		}
+		n++
+		ab = append(ab, fA, fB)
+		first += fB * (fABi - fA)
+		total += (fA - fABi) * (fA - fABi) / 2
+	}
+	_, v := calc.MeanVariance(ab)
+	if n == 0 || v == 0 {
+		return &SobolIndices{}
+	}
+	return &SobolIndices{First: first / float64(n) / v, Total: total / float64(n) / v}
+}
+
+// elementaryEffects of parameter i, from the outputs y along trajectories
+// stepping the parameters in orders, leaving out steps to or from a missing (NaN) output.
+func elementaryEffects(y []float64, orders [][]int, i int, Δ float64) *ElementaryEffects {
+	var effects, magnitudes []float64
+	for t, order := range orders {
+		start := t * (len(order) + 1)
+		for step, j := range order {
+			if j == i && !math.IsNaN(y[start+step]) && !math.IsNaN(y[start+step+1]) {
+				ee := (y[start+step+1] - y[start+step]) / Δ
+				effects = append(effects, ee)
+				magnitudes = append(magnitudes, math.Abs(ee))
+			}
+		}
+	}
+	e := &ElementaryEffects{}
+	var v float64
+	e.Mu, v = calc.MeanVariance(effects)
+	e.MuStar, _ = calc.MeanVariance(magnitudes)
+	e.Sigma = math.Sqrt(v)
+	return e
+}
+
+// WriteCSV writes the report as a table, one row per output and parameter.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"output", "parameter"}
+	switch r.Analysis.Method {
+	case MethodSobol:
+		header = append(header, "first_order", "total_order")
+	case MethodMorris:
+		header = append(header, "mu", "mu_star", "sigma")
+	}
+	cw.Write(header)
+	for _, out := range r.Outputs {
+		for _, p := range out.Parameters {
+			row := []string{out.Output, p.Key}
+			if p.Sobol != nil {
+				row = append(row, ftoa(p.Sobol.First), ftoa(p.Sobol.Total))
+			}
+			if p.Morris != nil {
+				row = append(row, ftoa(p.Morris.Mu), ftoa(p.Morris.MuStar), ftoa(p.Morris.Sigma))
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
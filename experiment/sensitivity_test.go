@@ -0,0 +1,166 @@
+package experiment
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// stratified reports whether the first n points hold one point in each of n strata of every dimension.
+func stratified(points [][]float64, n int) bool {
+	for j := range points[0] {
+		seen := make(map[int]bool)
+		for _, p := range points[:n] {
+			seen[int(p[j]*float64(n))] = true
+		}
+		if len(seen) != n {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSamplers(t *testing.T) {
+	seq, err := Sobol(16, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{0, 0}, {0.5, 0.5}, {0.75, 0.25}, {0.25, 0.75}}
+	for i, w := range want {
+		if seq[i][0] != w[0] || seq[i][1] != w[1] {
+			t.Errorf("Sobol point %d = %v, want %v", i, seq[i][:2], w)
+		}
+	}
+	for _, n := range []int{2, 4, 8, 16} {
+		if !stratified(seq, n) {
+			t.Errorf("first %d Sobol points not stratified", n)
+		}
+	}
+	if _, err := Sobol(1, MaxSobolDimensions+1); err == nil {
+		t.Error("no error beyond MaxSobolDimensions")
+	}
+	if lhs := LatinHypercube(10, 3, calc.NewRNG(1)); !stratified(lhs, 10) {
+		t.Error("Latin hypercube not stratified")
+	}
+}
+
+func TestSobolIndices(t *testing.T) {
+	// y = x0 + 2·x1: S = ST = 1/5 and 4/5, and nothing for x2.
+	f := func(x []float64) float64 { return x[0] + 2*x[1] }
+	s := Sensitivity{Method: MethodSobol, Samples: 1023, Parameters: make([]Range, 3)}
+	points, _, err := s.design()
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := make([]float64, len(points))
+	for i, p := range points {
+		y[i] = f(p)
+	}
+	for i, want := range []float64{0.2, 0.8, 0} {
+		got := sobolIndices(y, 3, i)
+		if math.Abs(got.First-want) > 0.02 || math.Abs(got.Total-want) > 0.02 {
+			t.Errorf("x%d: %+v, want %v", i, *got, want)
+		}
+	}
+}
+
+func TestElementaryEffects(t *testing.T) {
+	// y = x0 + x1², x2 inert: every effect of x0 is 1, those of x1 vary.
+	s := Sensitivity{Method: MethodMorris, Samples: 20, Parameters: make([]Range, 3), Seed: 3}
+	points, orders, err := s.design()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 20*4 {
+		t.Fatalf("%d points, want 80", len(points))
+	}
+	y := make([]float64, len(points))
+	for i, p := range points {
+		y[i] = p[0] + p[1]*p[1]
+	}
+	e0, e1, e2 := elementaryEffects(y, orders, 0, s.Δ()), elementaryEffects(y, orders, 1, s.Δ()), elementaryEffects(y, orders, 2, s.Δ())
+	if math.Abs(e0.Mu-1) > 1e-9 || math.Abs(e0.MuStar-1) > 1e-9 || e0.Sigma > 1e-9 {
+		t.Errorf("x0: %+v", *e0)
+	}
+	if e1.MuStar <= 0 || e1.Sigma <= 0 {
+		t.Errorf("x1: %+v", *e1)
+	}
+	if e2.MuStar != 0 {
+		t.Errorf("x2: %+v", *e2)
+	}
+}
+
+func TestSensitivityRuns(t *testing.T) {
+	s, err := LoadSensitivity(strings.NewReader(`{
+		"method": "sobol",
+		"samples": 4,
+		"parameters": [
+			{"key": "abm-vp-col-adaptation-factor", "min": 0.1, "max": 0.5},
+			{"key": "abm-cp-prey-spawn-size", "min": 1, "max": 4, "integer": true}
+		],
+		"outputs": ["cp_prey_pop", "apostatic_coefficient"],
+		"replicates": 2,
+		"seed": 5
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := s.Runs(abm.TestConditionParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 4*(2+2)*2 {
+		t.Fatalf("%d runs, want 32", len(runs))
+	}
+	for _, r := range runs {
+		if c := r.Conditions; c.VpCaf < 0.1 || c.VpCaf > 0.5 || c.CpPreySpawnSize < 1 || c.CpPreySpawnSize > 4 {
+			t.Errorf("%s: caf %v, spawn size %d", r.ID, c.VpCaf, c.CpPreySpawnSize)
+		}
+	}
+
+	results := make([]Result, len(runs))
+	for i, r := range runs {
+		results[i].Run = r
+		if i%2 == 1 { //	undefined in one replicate of each point
+			caf := r.Conditions.VpCaf
+			results[i].Summary.Apostatic = &caf
+		}
+	}
+	report, err := s.Analyse(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apostatic := report.Outputs[1]
+	if a := apostatic.Parameters[0].Sobol; a.Total < 0.9 || apostatic.Parameters[1].Sobol.Total != 0 || apostatic.Missing != 0 {
+		t.Errorf("apostatic: %+v", apostatic)
+	}
+	results[1].Summary.Apostatic = nil
+	report, err = s.Analyse(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apostatic := report.Outputs[1]; apostatic.Missing != 1 || math.IsNaN(apostatic.Mean) || apostatic.Parameters[0].Sobol.Total < 0.9 {
+		t.Errorf("apostatic, undefined at a point: %+v", apostatic)
+	}
+	if _, err := json.Marshal(report); err != nil {
+		t.Error(err)
+	}
+	if _, err := s.Analyse(results[1:]); err == nil {
+		t.Error("no error analysing missing results")
+	}
+
+	for _, spec := range []string{
+		`{"method": "fast", "samples": 4, "parameters": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}], "outputs": ["cp_prey_pop"]}`,
+		`{"method": "sobol", "samples": 4, "parameters": [{"key": "abm-cp-prey-mf", "min": 1, "max": 0}], "outputs": ["cp_prey_pop"]}`,
+		`{"method": "morris", "samples": 4, "levels": 3, "parameters": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}], "outputs": ["cp_prey_pop"]}`,
+		`{"method": "sobol", "samples": 4, "parameters": [{"key": "abm-cp-prey-mf", "min": 0, "max": 1}], "outputs": ["fitness"]}`,
+	} {
+		if _, err := LoadSensitivity(strings.NewReader(spec)); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+)
+
+// IndexFile is the CSV of every run of an experiment, written into its output directory.
+const IndexFile = "index.csv"
+
+/*
+Parameter is a ConditionParams field (by JSON key) varied by a sweep:
+either over a list of Values, or over Steps evenly spaced numbers from From
+to To inclusive.
+*/
+type Parameter struct {
+	Key    string            `json:"key"`
+	Values []json.RawMessage `json:"values,omitempty"`
+	From   float64           `json:"from,omitempty"`
+	To     float64           `json:"to,omitempty"`
+	Steps  int               `json:"steps,omitempty"`
+}
+
+// values lists the values the parameter takes, in order.
+func (p Parameter) values() ([]json.RawMessage, error) {
+	switch {
+	case p.Key == "":
+		return nil, errors.New("sweep parameter without a key")
+	case len(p.Values) > 0 && p.Steps > 0:
+		return nil, fmt.Errorf("sweep parameter %s: give either values or a range, not both", p.Key)
+	case len(p.Values) > 0:
+		return p.Values, nil
+	case p.Steps == 1:
+		return []json.RawMessage{number(p.From)}, nil
+	case p.Steps > 1:
+		vs := make([]json.RawMessage, p.Steps)
+		for i := range vs {
+			vs[i] = number(p.From + (p.To-p.From)*float64(i)/float64(p.Steps-1))
+		}
+		return vs, nil
+	}
+	return nil, fmt.Errorf("sweep parameter %s: no values, and no steps over a range", p.Key)
+}
+
+// String gives the value as it reads in a CSV: strings unquoted, anything else as JSON.
+func (v Value) String() string {
+	var s string
+	if json.Unmarshal(v.Value, &s) == nil {
+		return s
+	}
+	return string(v.Value)
+}
+
+// number encodes f as JSON, as an integer when it is one (so integer fields can take it).
+func number(f float64) json.RawMessage {
+	return json.RawMessage(ftoa(f))
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+/*
+Sweep is a Cartesian grid of parameter values. Each combination of values
+is run Replicates times (at least once), replicate r with the RNG seed
+Seed+r: every combination sees the same seeds, so that differences between
+them are down to the parameters rather than chance.
+*/
+type Sweep struct {
+	Parameters []Parameter `json:"parameters"`
+	Replicates int         `json:"replicates"`
+	Seed       int64       `json:"seed"`
+}
+
+// LoadSweep reads a JSON-formatted Sweep.
+func LoadSweep(r io.Reader) (Sweep, error) {
+	var s Sweep
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&s)
+	if err != nil {
+		return s, fmt.Errorf("sweep: %s", err)
+	}
+	return s, nil
+}
+
+// Runs gives every run of the sweep over base, with the first parameter
+// varying slowest. Any combination giving invalid conditions is an error.
+func (s Sweep) Runs(base abm.ConditionParams) ([]Run, error) {
+	grid := [][]Value{nil}
+	for _, p := range s.Parameters {
+		vs, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		var next [][]Value
+		for _, combination := range grid {
+			for _, v := range vs {
+				next = append(next, append(combination[:len(combination):len(combination)], Value{Key: p.Key, Value: v}))
+			}
+		}
+		grid = next
+	}
+	replicates := s.Replicates
+	if replicates < 1 {
+		replicates = 1
+	}
+	var runs []Run
+	for _, values := range grid {
+		for r := 0; r < replicates; r++ {
+			c, err := Conditions(base, values...) //	a copy of its own for every model.
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, Run{
+				ID:         fmt.Sprintf("run-%04d", len(runs)),
+				Replicate:  r,
+				Values:     values,
+				Conditions: Seeded(c, s.Seed+int64(r)),
+				Series:     true,
+			})
+		}
+	}
+	return runs, nil
+}
+
+/*
+WriteIndex writes a CSV of the results, one row per run: its ID, replicate
+and seed, the value of each parameter varied, how the run ended (with any
+error), and the final summary of its populations, as in summary.csv.
+*/
+func WriteIndex(w io.Writer, results []Result) error {
+	header := []string{"run_id", "replicate", "seed"}
+	if len(results) > 0 {
+		for _, v := range results[0].Values {
+			header = append(header, v.Key)
+		}
+	}
+	summaryHeader, _ := abm.TurnSummary{}.Columns()
+	header = append(header, "outcome", "error")
+	header = append(header, summaryHeader...)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, r := range results {
+		row := []string{r.ID, strconv.Itoa(r.Replicate), strconv.FormatInt(r.Conditions.RNGSeedVal, 10)}
+		for _, v := range r.Values {
+			row = append(row, v.String())
+		}
+		errString := ""
+		if r.Err != nil {
+			errString = r.Err.Error()
+		}
+		_, final := r.Summary.Final.Columns()
+		row = append(row, r.Summary.Outcome, errString)
+		row = append(row, final...)
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,96 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/abm"
+)
+
+func TestSweepRuns(t *testing.T) {
+	s, err := LoadSweep(strings.NewReader(`{
+		"parameters": [
+			{"key": "abm-vp-col-adaptation-factor", "values": [0.1, 0.4]},
+			{"key": "abm-cp-prey-spawn-size", "from": 1, "to": 3, "steps": 3}
+		],
+		"replicates": 2,
+		"seed": 7
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := s.Runs(abm.TestConditionParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2*3*2 {
+		t.Fatalf("%d runs, want 12", len(runs))
+	}
+	last := runs[len(runs)-1]
+	if c := last.Conditions; c.VpCaf != 0.4 || c.CpPreySpawnSize != 3 || c.RNGRandomSeed || c.RNGSeedVal != 8 || last.Replicate != 1 {
+		t.Errorf("last run %s: caf %v, spawn size %d, seed %d", last.ID, c.VpCaf, c.CpPreySpawnSize, c.RNGSeedVal)
+	}
+	if runs[0].Conditions.VpCaf != 0.1 || runs[0].Conditions.CpPreySpawnSize != 1 || runs[0].Conditions.RNGSeedVal != 7 {
+		t.Errorf("first run %+v", runs[0].Values)
+	}
+
+	for _, spec := range []string{
+		`{"parameters": [{"key": "abm-vp-attack-chance", "values": [0.5, 2]}]}`,
+		`{"parameters": [{"key": "abm-vp-atack-chance", "values": [0.5]}]}`,
+		`{"parameters": [{"key": "abm-vp-attack-chance"}]}`,
+	} {
+		s, err := LoadSweep(strings.NewReader(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Runs(abm.TestConditionParams); err == nil {
+			t.Errorf("%s: no error", spec)
+		}
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abm-sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := abm.TestConditionParams
+	base.Logging = false
+	s := Sweep{Parameters: []Parameter{{Key: "abm-cp-prey-mf", From: 0.05, To: 0.1, Steps: 2}}, Replicates: 2}
+	runs, err := s.Runs(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := 0
+	results := RunAll(runs, dir, 3, func(Result) { finished++ })
+	if finished != len(runs) {
+		t.Errorf("%d runs reported finished, want %d", finished, len(runs))
+	}
+	for i, r := range results {
+		if r.Err != nil || r.ID != runs[i].ID || r.Summary.Turns == 0 {
+			t.Errorf("result %d: %s after %d turns, %v", i, r.ID, r.Summary.Turns, r.Err)
+		}
+	}
+	again := RunAll(runs[:1], dir, 1, nil)
+	if again[0].Summary.Final != results[0].Summary.Final {
+		t.Errorf("%s not reproduced when run alone:\n%+v\n%+v", runs[0].ID, again[0].Summary.Final, results[0].Summary.Final)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteIndex(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(runs)+1 || rows[0][3] != "abm-cp-prey-mf" || rows[2][3] != "0.05" || rows[3][3] != "0.1" {
+		t.Errorf("index:\n%v", rows)
+	}
+}
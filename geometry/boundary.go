@@ -0,0 +1,65 @@
+package geometry
+
+import (
+	"math"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+// Boundary modes, determining what happens to anything which moves beyond the bounds of an environment.
+const (
+	Wrap    = "wrap"    //	toroidal: leaving one edge means entering at the opposite edge
+	Reflect = "reflect" //	bounce back off the edge, mirroring the heading
+	Wall    = "wall"    //	stopped (clamped) at the edge
+)
+
+/*
+Confine returns position v, travelling with heading 𝚯, brought back within
+bounds according to the boundary mode, along with its resulting heading.
+Only Reflect ever changes the heading. An empty or unknown mode is taken to
+be Wrap, and an axis with a bound ≤ 0 (or beyond the length of bounds) is
+left unconfined.
+*/
+func Confine(v Vector, 𝚯 float64, bounds []float64, mode string) (Vector, float64) {
+	c := make(Vector, len(v))
+	copy(c, v)
+	for i := range c {
+		if i >= len(bounds) || bounds[i] <= 0 {
+			continue
+		}
+		d := bounds[i]
+		switch mode {
+		case Reflect:
+			flipped := false
+			for c[i] > d || c[i] < -d {
+				if c[i] > d {
+					c[i] = (2 * d) - c[i]
+				} else {
+					c[i] = (-2 * d) - c[i]
+				}
+				flipped = !flipped
+			}
+			if flipped {
+				𝚯 = mirrorHeading(𝚯, i)
+			}
+		case Wall:
+			c[i] = calc.ClampFloatIn(c[i], -d, d)
+		default:
+			if c[i] < -d || c[i] >= d {
+				c[i] -= (2 * d) * math.Floor((c[i]+d)/(2*d))
+			}
+		}
+	}
+	return c, 𝚯
+}
+
+// mirrorHeading reflects heading 𝚯 off a boundary perpendicular to the given axis.
+func mirrorHeading(𝚯 float64, axis int) float64 {
+	switch axis {
+	case x:
+		return UnitAngle(math.Pi - 𝚯)
+	case y:
+		return UnitAngle(-𝚯)
+	}
+	return 𝚯
+}
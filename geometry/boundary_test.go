@@ -0,0 +1,41 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+func TestConfine(t *testing.T) {
+	bounds := []float64{1.0, 0.5}
+	var tests = []struct {
+		mode  string
+		v     Vector
+		𝚯     float64
+		want  Vector
+		want𝚯 float64
+	}{
+		{Wrap, Vector{1.1, 0}, 0, Vector{-0.9, 0}, 0},
+		{Wrap, Vector{0, -0.6}, 0, Vector{0, 0.4}, 0},
+		{"", Vector{-1.25, 0.2}, 1, Vector{0.75, 0.2}, 1},
+		{Reflect, Vector{1.1, 0}, 0, Vector{0.9, 0}, calc.ToFixed(math.Pi, 5)},
+		{Reflect, Vector{0, -0.6}, calc.ToFixed(3*math.Pi/2, 5), Vector{0, -0.4}, calc.ToFixed(math.Pi/2, 5)},
+		{Reflect, Vector{0.5, 0.25}, 2, Vector{0.5, 0.25}, 2},
+		{Wall, Vector{1.1, -0.6}, 1, Vector{1.0, -0.5}, 1},
+	}
+	for _, test := range tests {
+		got, got𝚯 := Confine(test.v, test.𝚯, bounds, test.mode)
+		for i := range got {
+			got[i] = calc.ToFixed(got[i], 5)
+		}
+		if !got.Equal(test.want) || calc.ToFixed(got𝚯, 4) != calc.ToFixed(test.want𝚯, 4) {
+			t.Errorf("Confine(%v, %v, %q) == %v, %v, want %v, %v", test.v, test.𝚯, test.mode, got, got𝚯, test.want, test.want𝚯)
+		}
+	}
+	v := Vector{2, 2}
+	Confine(v, 0, bounds, Wall)
+	if v[x] != 2 || v[y] != 2 {
+		t.Errorf("Confine modified its input")
+	}
+}
@@ -3,6 +3,7 @@ package geometry
 import (
 	"errors"
 	"math"
+	"math/rand"
 
 	"github.com/benjamin-rood/abm-cp/calc"
 )
@@ -205,24 +206,44 @@ func TranslatePositionToSector2D(ed float64, n int, v Vector) (int, int) {
 }
 
 // FuzzifyVector will return a a 'fuzzy', slightly randomised version of v, at a random variance in range (-ε, +ε) offset from each existing element of v.
-func FuzzifyVector(v Vector, ε float64) (Vector, error) {
+// v itself is left unchanged. The result is not confined to any bounds: see FuzzifyVectorWithin.
+func FuzzifyVector(v Vector, ε float64, rng *rand.Rand) (Vector, error) {
 	if len(v) == 0 {
 		return nil, errors.New("v is an empty vector")
 	}
-	vf := v
+	vf := make(Vector, len(v))
+	copy(vf, v)
 	for i := 0; i < len(vf); i++ {
-		vf[i] = vf[i] + calc.RandFloatIn(-ε, ε)
+		vf[i] = vf[i] + calc.RandFloatIn(-ε, ε, rng)
 	}
 	return vf, nil
 }
 
+// FuzzifyVectorWithin is FuzzifyVector with the result brought back within bounds according to the boundary mode (see Confine).
+func FuzzifyVectorWithin(v Vector, ε float64, bounds []float64, mode string, rng *rand.Rand) (Vector, error) {
+	vf, err := FuzzifyVector(v, ε, rng)
+	if err != nil {
+		return nil, err
+	}
+	vf, _ = Confine(vf, 0, bounds, mode)
+	return vf, nil
+}
+
 // RandVector will give a random vector within boundaries the axes of len(bounds) dimensions
-func RandVector(bounds []float64) Vector {
+func RandVector(bounds []float64, rng *rand.Rand) Vector {
 	var v Vector
 	for i := 0; i < len(bounds); i++ {
 		d := bounds[i]
-		val := calc.RandFloatIn(-d, d)
+		val := calc.RandFloatIn(-d, d, rng)
 		v = append(v, val)
 	}
 	return v
 }
+
+// RandVectorWithin is RandVector for an environment with the given boundary mode.
+// Every mode shares the same interior, but with Wrap the edges at -d and d are
+// the same place, so values are only drawn from [-d, d).
+func RandVectorWithin(bounds []float64, mode string, rng *rand.Rand) Vector {
+	v, _ := Confine(RandVector(bounds, rng), 0, bounds, mode)
+	return v
+}
@@ -0,0 +1,210 @@
+package geometry
+
+import (
+	"math"
+	"sort"
+)
+
+const maxGridSectors = 512 //	per axis, limits memory use for very small cell sizes
+
+/*
+Grid is a uniform-grid spatial index over a 2D environment with half-widths
+`bounds`, partitioned into n*n sectors (in the same layout as
+TranslatePositionToSector2D). It stores the indices of a slice of positions,
+bucketed by sector, so that neighbour queries only need to consider the
+sectors around a point rather than every position. It is cheap to build and
+is meant to be rebuilt whenever the indexed positions change.
+A torus Grid wraps its queries around the boundaries, and measures distances
+with TorusDistance.
+*/
+type Grid struct {
+	bounds []float64 //	half-width of the indexed area along each axis
+	n      int       //	sectors per axis
+	width  Vector    //	size of a single sector along each axis
+	torus  bool      //	queries wrap around the boundaries
+	start  []int     //	offset into idx for the first entry of each sector; sector s spans idx[start[s]:start[s+1]]
+	idx    []int     //	position indices, bucketed by sector
+	points []Vector  //	the indexed positions
+}
+
+// NewGrid indexes positions within an environment of the given bounds,
+// using sectors of (at least) size cellSize.
+func NewGrid(bounds []float64, cellSize float64, positions []Vector) *Grid {
+	return newGrid(bounds, cellSize, positions, false)
+}
+
+// NewTorusGrid is NewGrid for an environment which wraps around at its bounds.
+func NewTorusGrid(bounds []float64, cellSize float64, positions []Vector) *Grid {
+	return newGrid(bounds, cellSize, positions, true)
+}
+
+func newGrid(bounds []float64, cellSize float64, positions []Vector, torus bool) *Grid {
+	b := []float64{1.0, 1.0}
+	for i := 0; i < len(bounds) && i < len(b); i++ {
+		if bounds[i] > 0 {
+			b[i] = bounds[i]
+		}
+	}
+	n := 1
+	if cellSize > 0 {
+		n = int(math.Floor((2 * math.Min(b[x], b[y])) / cellSize))
+	}
+	if n < 1 {
+		n = 1
+	}
+	if n > maxGridSectors {
+		n = maxGridSectors
+	}
+	g := &Grid{
+		bounds: b,
+		n:      n,
+		width:  Vector{(2 * b[x]) / float64(n), (2 * b[y]) / float64(n)},
+		torus:  torus,
+		points: positions,
+	}
+
+	// counting sort of the position indices by sector:
+	sectors := make([]int, len(positions))
+	g.start = make([]int, (n*n)+1)
+	for i, v := range positions {
+		sectors[i] = g.sector(v)
+		g.start[sectors[i]+1]++
+	}
+	for s := 1; s < len(g.start); s++ {
+		g.start[s] += g.start[s-1]
+	}
+	g.idx = make([]int, len(positions))
+	next := append([]int(nil), g.start[:n*n]...)
+	for i, s := range sectors {
+		g.idx[next[s]] = i
+		next[s]++
+	}
+	return g
+}
+
+// Len returns the number of indexed positions.
+func (g *Grid) Len() int {
+	return len(g.points)
+}
+
+// rawRowCol gives the sector co-ordinates of v, which may lie outside the grid.
+func (g *Grid) rawRowCol(v Vector) (int, int) {
+	col := int(math.Floor((v[x] + g.bounds[x]) / g.width[x]))
+	row := int(math.Floor((g.bounds[y] - v[y]) / g.width[y]))
+	return row, col
+}
+
+// rowCol gives the sector co-ordinates of v, folded back onto the grid.
+func (g *Grid) rowCol(v Vector) (int, int) {
+	row, col := g.rawRowCol(v)
+	return g.fold(row), g.fold(col)
+}
+
+// fold maps a sector co-ordinate onto the grid, wrapping for a torus and clamping otherwise.
+func (g *Grid) fold(i int) int {
+	if g.torus {
+		i %= g.n
+		if i < 0 {
+			i += g.n
+		}
+		return i
+	}
+	if i < 0 {
+		return 0
+	}
+	if i >= g.n {
+		return g.n - 1
+	}
+	return i
+}
+
+func (g *Grid) sector(v Vector) int {
+	row, col := g.rowCol(v)
+	return (row * g.n) + col
+}
+
+// span gives the folded sector co-ordinates covering the raw range [a, b].
+func (g *Grid) span(a int, b int) []int {
+	if !g.torus {
+		a, b = g.fold(a), g.fold(b)
+	} else if b-a >= g.n {
+		a, b = 0, g.n-1
+	}
+	var s []int
+	for i := a; i <= b; i++ {
+		s = append(s, g.fold(i))
+	}
+	return s
+}
+
+// distance between two positions, as measured in the indexed environment.
+func (g *Grid) distance(v Vector, u Vector) (float64, error) {
+	if g.torus {
+		return TorusDistance(v, u, g.bounds)
+	}
+	return VectorDistance(v, u)
+}
+
+// Within returns, in ascending order, the indices of all positions in the
+// sectors overlapping the square of half-width r centred on v. This is a
+// superset of the positions within distance r of v: callers still need to
+// check the actual distance, but can skip everything else.
+func (g *Grid) Within(v Vector, r float64) []int {
+	rowA, colA := g.rawRowCol(Vector{v[x] - r, v[y] + r}) //	top-left
+	rowB, colB := g.rawRowCol(Vector{v[x] + r, v[y] - r}) //	bottom-right
+	var found []int
+	cols := g.span(colA, colB)
+	for _, row := range g.span(rowA, rowB) {
+		for _, col := range cols {
+			s := (row * g.n) + col
+			found = append(found, g.idx[g.start[s]:g.start[s+1]]...)
+		}
+	}
+	sort.Ints(found) //	preserve the order of an exhaustive scan.
+	return found
+}
+
+// Nearest returns the index of the position closest to v, ignoring the
+// index skip (use -1 to consider all). Distance ties go to the lowest index.
+// The search expands outwards ring by ring from the sector containing v,
+// and stops once no unvisited sector could hold anything closer.
+func (g *Grid) Nearest(v Vector, skip int) (int, bool) {
+	best, bestDist := -1, math.Inf(1)
+	row, col := g.rowCol(v)
+	w := math.Min(g.width[x], g.width[y])
+	for ring := 0; ring < g.n; ring++ {
+		if best >= 0 && bestDist < float64(ring-1)*w {
+			break
+		}
+		for dr := -ring; dr <= ring; dr++ {
+			for dc := -ring; dc <= ring; dc++ {
+				if dr != -ring && dr != ring && dc != -ring && dc != ring {
+					continue //	interior, already visited.
+				}
+				r, c := row+dr, col+dc
+				if g.torus {
+					if 2*dr <= -g.n || 2*dr > g.n || 2*dc <= -g.n || 2*dc > g.n {
+						continue //	the same sector is reached by a shorter way round.
+					}
+					r, c = g.fold(r), g.fold(c)
+				} else if r < 0 || r >= g.n || c < 0 || c >= g.n {
+					continue
+				}
+				s := (r * g.n) + c
+				for _, i := range g.idx[g.start[s]:g.start[s+1]] {
+					if i == skip {
+						continue
+					}
+					δ, err := g.distance(v, g.points[i])
+					if err != nil {
+						continue
+					}
+					if δ < bestDist || (δ == bestDist && i < best) {
+						best, bestDist = i, δ
+					}
+				}
+			}
+		}
+	}
+	return best, best >= 0
+}
@@ -0,0 +1,81 @@
+package geometry
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+)
+
+func TestGridWithin(t *testing.T) {
+	rng := calc.NewRNG(0)
+	bounds := []float64{1.0, 1.0}
+	var points []Vector
+	for i := 0; i < 2000; i++ {
+		points = append(points, RandVector(bounds, rng))
+	}
+	for _, torus := range []bool{false, true} {
+		for _, cell := range []float64{0.05, 0.2, 0.7, 3.0} {
+			g := NewGrid(bounds, cell, points)
+			if torus {
+				g = NewTorusGrid(bounds, cell, points)
+			}
+			for q := 0; q < 200; q++ {
+				v := RandVector(bounds, rng)
+				r := calc.RandFloatIn(0, 0.5, rng)
+				found := map[int]bool{}
+				last := -1
+				for _, i := range g.Within(v, r) {
+					if i <= last {
+						t.Fatalf("Within(%v, %v) not in ascending order", v, r)
+					}
+					last = i
+					found[i] = true
+				}
+				for i, p := range points {
+					δ, _ := g.distance(v, p)
+					if δ <= r && !found[i] {
+						t.Fatalf("torus %v, cell %v: Within(%v, %v) missed point %d at %v (distance %v)", torus, cell, v, r, i, p, δ)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGridNearest(t *testing.T) {
+	rng := calc.NewRNG(1)
+	bounds := []float64{1.0, 1.0}
+	var points []Vector
+	for i := 0; i < 500; i++ {
+		points = append(points, RandVector(bounds, rng))
+	}
+	for _, torus := range []bool{false, true} {
+		for _, cell := range []float64{0.01, 0.1, 0.5, 0.6, 3.0} {
+			g := NewGrid(bounds, cell, points)
+			if torus {
+				g = NewTorusGrid(bounds, cell, points)
+			}
+			for q := 0; q < 200; q++ {
+				skip := q % len(points)
+				v := points[skip]
+				want, wantDist := -1, 0.0
+				for i, p := range points {
+					if i == skip {
+						continue
+					}
+					δ, _ := g.distance(v, p)
+					if want < 0 || δ < wantDist {
+						want, wantDist = i, δ
+					}
+				}
+				got, ok := g.Nearest(v, skip)
+				if !ok || got != want {
+					t.Fatalf("torus %v, cell %v: Nearest(%v, %d) = %d, want %d", torus, cell, v, skip, got, want)
+				}
+			}
+		}
+	}
+	if _, ok := NewGrid(bounds, 0.1, nil).Nearest(Vector{0, 0}, -1); ok {
+		t.Errorf("Nearest found a position in an empty grid")
+	}
+}
@@ -0,0 +1,65 @@
+package geometry
+
+import "errors"
+
+/*
+The model environment wraps around at its boundaries, i.e. it is a torus:
+an axis with bound d spans [-d, d], with -d and d being the same place.
+The functions below take that into account by working with whichever
+periodic image of a position is closest, so that agents near opposite
+edges are correctly considered to be near each other. An axis with a
+bound ≤ 0 (or beyond the length of bounds) is treated as unbounded.
+*/
+
+// NearestImage returns the periodic image of u which lies closest to v
+// on a torus with half-widths bounds. The result may lie outside bounds.
+func NearestImage(v Vector, u Vector, bounds []float64) (Vector, error) {
+	if len(v) != len(u) {
+		return nil, errors.New("vector dimensions do not coincide")
+	}
+	image := make(Vector, len(u))
+	for i := range u {
+		image[i] = u[i]
+		if i >= len(bounds) || bounds[i] <= 0 {
+			continue
+		}
+		span := 2 * bounds[i]
+		diff := u[i] - v[i]
+		for diff > bounds[i] {
+			diff -= span
+		}
+		for diff < -bounds[i] {
+			diff += span
+		}
+		image[i] = v[i] + diff
+	}
+	return image, nil
+}
+
+// TorusDistance calculates the shortest distance between two positions on a torus.
+func TorusDistance(v Vector, u Vector, bounds []float64) (float64, error) {
+	image, err := NearestImage(v, u, bounds)
+	if err != nil {
+		return 0, err
+	}
+	return VectorDistance(v, image)
+}
+
+// TorusRelativeAngle gives the angle of the shortest path from v to u on a torus.
+func TorusRelativeAngle(v Vector, u Vector, bounds []float64) (float64, error) {
+	image, err := NearestImage(v, u, bounds)
+	if err != nil {
+		return 0, err
+	}
+	return RelativeAngle(v, image)
+}
+
+// TorusAngleToIntercept calculates the change in angle required from the
+// current heading to point along the shortest path to target on a torus.
+func TorusAngleToIntercept(pos Vector, dir𝚹 float64, target Vector, bounds []float64) (float64, error) {
+	image, err := NearestImage(pos, target, bounds)
+	if err != nil {
+		return 0, err
+	}
+	return AngleToIntercept(pos, dir𝚹, image)
+}
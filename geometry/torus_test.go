@@ -0,0 +1,48 @@
+package geometry
+
+import "testing"
+
+func TestTorusDistance(t *testing.T) {
+	bounds := []float64{1.0, 1.0}
+	var tests = []struct {
+		v, u Vector
+		want float64
+	}{
+		{Vector{0, 0}, Vector{0.5, 0}, 0.5},
+		{Vector{0.99, 0}, Vector{-0.99, 0}, 0.02},
+		{Vector{0, -0.95}, Vector{0, 0.95}, 0.1},
+		{Vector{0.9, 0.9}, Vector{-0.9, -0.9}, 0.28284},
+		{Vector{1.0, 0}, Vector{-1.0, 0}, 0},
+	}
+	for _, test := range tests {
+		got, err := TorusDistance(test.v, test.u, bounds)
+		if err != nil {
+			t.Error(err)
+		}
+		if got != test.want {
+			t.Errorf("TorusDistance(%v, %v) == %v, want %v", test.v, test.u, got, test.want)
+		}
+	}
+	// unbounded axes fall back to plain distance:
+	got, _ := TorusDistance(Vector{0.99, 0}, Vector{-0.99, 0}, nil)
+	if got != 1.98 {
+		t.Errorf("TorusDistance without bounds == %v, want %v", got, 1.98)
+	}
+}
+
+func TestTorusAngleToIntercept(t *testing.T) {
+	bounds := []float64{1.0, 1.0}
+	// heading straight up (+y), target just across the right-hand edge, i.e. directly to the right.
+	Ψ, err := TorusAngleToIntercept(Vector{0.95, 0}, 1.5708, Vector{-0.95, 0}, bounds)
+	if err != nil {
+		t.Error(err)
+	}
+	want := -1.5708
+	if Ψ != want {
+		t.Errorf("TorusAngleToIntercept == %v, want %v", Ψ, want)
+	}
+	Φ, _ := TorusRelativeAngle(Vector{0, 0.95}, Vector{0, -0.95}, bounds)
+	if Φ != 1.5708 {
+		t.Errorf("TorusRelativeAngle == %v, want %v", Φ, 1.5708)
+	}
+}
@@ -0,0 +1,81 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// BackgroundMaxSize is the largest width or height of a Background image sent to the front-end.
+const BackgroundMaxSize = 128
+
+// Background holds an image of the environment substrate, to be stretched
+// across the front-end viewport and drawn beneath the agents.
+// An empty Image means a plain background of the DrawList BG colour.
+type Background struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Image  string `json:"image"` //	PNG data URL
+}
+
+// NewBackground encodes img, downsampled to at most maxSize pixels along either side.
+func NewBackground(img image.Image, maxSize int) (Background, error) {
+	small := Downsample(img, maxSize)
+	var buf bytes.Buffer
+	err := png.Encode(&buf, small)
+	if err != nil {
+		return Background{}, err
+	}
+	b := small.Bounds()
+	return Background{
+		Width:  b.Dx(),
+		Height: b.Dy(),
+		Image:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+/*
+Downsample shrinks img, keeping its aspect ratio, so that neither side is
+longer than maxSize pixels. Every pixel of the result is the average of the
+block of source pixels it covers. Images that are already small enough
+(or a maxSize ≤ 0) are only copied.
+*/
+func Downsample(img image.Image, maxSize int) *image.RGBA {
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if maxSize > 0 && (w > maxSize || h > maxSize) {
+		if w >= h {
+			w, h = maxSize, maxInt(1, (h*maxSize)/src.Dx())
+		} else {
+			w, h = maxInt(1, (w*maxSize)/src.Dy()), maxSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for j := 0; j < h; j++ {
+		y0 := src.Min.Y + (j*src.Dy())/h
+		y1 := src.Min.Y + ((j+1)*src.Dy())/h
+		for i := 0; i < w; i++ {
+			x0 := src.Min.X + (i*src.Dx())/w
+			x1 := src.Min.X + ((i+1)*src.Dx())/w
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(i, j, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
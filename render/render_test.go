@@ -1,6 +1,11 @@
 package render
 
-import "testing"
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
 
 func TestTranslation(t *testing.T) {
 	var absToViewTests = []struct {
@@ -19,3 +24,36 @@ func TestTranslation(t *testing.T) {
 		}
 	}
 }
+
+func TestDownsample(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for px := 0; px < 4; px++ {
+		for py := 0; py < 2; py++ {
+			c := color.RGBA{A: 255}
+			if px >= 2 {
+				c.R = 200
+			}
+			if py == 1 {
+				c.B = 100
+			}
+			img.SetRGBA(px, py, c)
+		}
+	}
+	small := Downsample(img, 2)
+	if b := small.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("Downsample to %v, want 2x1", b)
+	}
+	want := []color.RGBA{{R: 0, B: 50, A: 255}, {R: 200, B: 50, A: 255}}
+	for px, c := range want {
+		if got := small.RGBAAt(px, 0); got != c {
+			t.Errorf("pixel %d == %v, want %v", px, got, c)
+		}
+	}
+	bg, err := NewBackground(img, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bg.Width != 2 || bg.Height != 1 || !strings.HasPrefix(bg.Image, "data:image/png;base64,") {
+		t.Errorf("NewBackground == %+v", bg)
+	}
+}
@@ -0,0 +1,170 @@
+package stats
+
+import (
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// DefaultWindow is the number of turns of an apostatic selection window
+// when no (positive) number is given.
+const DefaultWindow = 10
+
+/*
+Apostatic measures frequency-dependent selection on the prey morphs over
+successive windows of turns. Each turn it observes the prey population
+exposed to predators, and which of them were eaten. At the end of a
+window it relates each morph's relative frequency to the rate at which it
+was preyed upon: under apostatic (negative frequency-dependent) selection,
+predators focus on the common morphs, so per-capita predation rises with
+frequency.
+
+Its fields are exported only so that it can be checkpointed.
+*/
+type Apostatic struct {
+	Morphs   Morphs  `json:"morphs"`
+	Window   int     `json:"window"`          //	turns per window
+	Start    int     `json:"start"`           //	first turn of the current window
+	Turns    int     `json:"turns"`           //	turns observed in the current window
+	Exposure []int   `json:"exposure"`        //	prey-turns of each morph in the current window
+	Eaten    []int   `json:"eaten"`           //	prey of each morph eaten in the current window
+	Defined  int     `json:"defined"`         //	completed windows with a Defined coefficient
+	Sum      float64 `json:"coefficient-sum"` //	of their coefficients
+}
+
+// MorphSelection is the predation on a single morph over a window.
+type MorphSelection struct {
+	Morph       int        `json:"morph"`
+	Colour      colour.RGB `json:"colour"`      //	centre of the morph class
+	Frequency   float64    `json:"frequency"`   //	mean share of the prey population
+	Exposure    int        `json:"exposure"`    //	prey-turns exposed to predation
+	Eaten       int        `json:"eaten"`       //	number eaten
+	Rate        float64    `json:"rate"`        //	per-capita predation rate, per turn
+	Selectivity float64    `json:"selectivity"` //	share of predation / share of population: > 1 when preyed upon more than its frequency alone would give
+}
+
+/*
+ApostaticWindow is the selection on the prey morphs over the turns
+[Start, End]. Coefficient is the least-squares slope of per-capita
+predation rate against relative frequency across the morphs present, so
+it is positive under apostatic selection and negative under positive
+frequency-dependent selection. It is only Defined when at least two
+morphs with different frequencies were present.
+*/
+type ApostaticWindow struct {
+	Start       int              `json:"start"`
+	End         int              `json:"end"`
+	Eaten       int              `json:"eaten"`
+	Rate        float64          `json:"rate"` //	per-capita predation rate over all morphs, per turn
+	Coefficient float64          `json:"coefficient"`
+	Defined     bool             `json:"defined"`
+	Morphs      []MorphSelection `json:"morphs"` //	only those present in the window
+}
+
+// NewApostatic tracks selection on morph classes of the given number of bins
+// per RGB channel, over windows of the given number of turns. Non-positive
+// values give DefaultMorphBins and DefaultWindow.
+func NewApostatic(bins int, window int) *Apostatic {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	a := &Apostatic{Morphs: NewMorphs(bins), Window: window}
+	a.reset(0)
+	return a
+}
+
+func (a *Apostatic) reset(start int) {
+	a.Start, a.Turns = start, 0
+	a.Exposure = make([]int, a.Morphs.Len())
+	a.Eaten = make([]int, a.Morphs.Len())
+}
+
+/*
+Observe records the prey population exposed to predators on turn, by
+colouration, where eaten[i] marks that prey i was eaten. When the turn
+completes a window, Observe returns the selection over that window and
+begins the next.
+*/
+func (a *Apostatic) Observe(turn int, prey []colour.RGB, eaten []bool) (ApostaticWindow, bool) {
+	if a.Turns == 0 {
+		a.Start = turn
+	}
+	for i, c := range prey {
+		m := a.Morphs.Of(c)
+		a.Exposure[m]++
+		if i < len(eaten) && eaten[i] {
+			a.Eaten[m]++
+		}
+	}
+	a.Turns++
+	if a.Turns < a.Window {
+		return ApostaticWindow{}, false
+	}
+	w := a.window(turn)
+	if w.Defined {
+		a.Defined++
+		a.Sum += w.Coefficient
+	}
+	a.reset(turn + 1)
+	return w, true
+}
+
+// MeanCoefficient gives the mean Coefficient of the Defined windows
+// completed so far, if there were any.
+func (a *Apostatic) MeanCoefficient() (float64, bool) {
+	if a == nil || a.Defined == 0 {
+		return 0, false
+	}
+	return a.Sum / float64(a.Defined), true
+}
+
+// window computes the selection over the current window, ending on turn end.
+func (a *Apostatic) window(end int) ApostaticWindow {
+	w := ApostaticWindow{Start: a.Start, End: end}
+	exposure := 0
+	for m := range a.Exposure {
+		exposure += a.Exposure[m]
+		w.Eaten += a.Eaten[m]
+	}
+	if exposure == 0 {
+		return w
+	}
+	w.Rate = float64(w.Eaten) / float64(exposure)
+	var f, r []float64
+	for m := range a.Exposure {
+		if a.Exposure[m] == 0 {
+			continue
+		}
+		s := MorphSelection{
+			Morph:     m,
+			Colour:    a.Morphs.Colour(m),
+			Frequency: float64(a.Exposure[m]) / float64(exposure),
+			Exposure:  a.Exposure[m],
+			Eaten:     a.Eaten[m],
+			Rate:      float64(a.Eaten[m]) / float64(a.Exposure[m]),
+		}
+		if w.Eaten > 0 {
+			s.Selectivity = (float64(s.Eaten) / float64(w.Eaten)) / s.Frequency
+		}
+		w.Morphs = append(w.Morphs, s)
+		f = append(f, s.Frequency)
+		r = append(r, s.Rate)
+	}
+	w.Coefficient, w.Defined = slope(f, r)
+	w.Coefficient = calc.ToFixed(w.Coefficient, 8)
+	return w
+}
+
+// slope gives the least-squares slope of ys against xs, if the xs vary.
+func slope(xs []float64, ys []float64) (float64, bool) {
+	mx, vx := calc.MeanVariance(xs)
+	if len(xs) < 2 || vx == 0 {
+		return 0, false
+	}
+	my, _ := calc.MeanVariance(ys)
+	cov := 0.0
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+	}
+	cov /= float64(len(xs))
+	return cov / vx, true
+}
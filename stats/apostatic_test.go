@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+func TestMorphs(t *testing.T) {
+	m := NewMorphs(0)
+	if m.Len() != 27 {
+		t.Errorf("Len() == %d, want 27", m.Len())
+	}
+	for i := 0; i < m.Len(); i++ {
+		if got := m.Of(m.Colour(i)); got != i {
+			t.Errorf("Of(Colour(%d)) == %d", i, got)
+		}
+	}
+	if m.Of(colour.Black) != 0 || m.Of(colour.White) != m.Len()-1 {
+		t.Errorf("Of(Black) == %d, Of(White) == %d", m.Of(colour.Black), m.Of(colour.White))
+	}
+}
+
+func TestApostatic(t *testing.T) {
+	// each turn: 6 red prey, 2 green, 2 blue; predators eat 2 reds and
+	// nothing else, i.e. strongly favour the common morph.
+	var prey []colour.RGB
+	var eaten []bool
+	for i := 0; i < 6; i++ {
+		prey = append(prey, colour.Red)
+		eaten = append(eaten, i < 2)
+	}
+	for i := 0; i < 2; i++ {
+		prey = append(prey, colour.Green, colour.Blue)
+		eaten = append(eaten, false, false)
+	}
+
+	a := NewApostatic(2, 5)
+	for turn := 10; turn < 14; turn++ {
+		if _, done := a.Observe(turn, prey, eaten); done {
+			t.Fatalf("window completed early on turn %d", turn)
+		}
+	}
+	w, done := a.Observe(14, prey, eaten)
+	if !done {
+		t.Fatal("window not completed")
+	}
+	if w.Start != 10 || w.End != 14 || w.Eaten != 10 || len(w.Morphs) != 3 {
+		t.Fatalf("window = %+v", w)
+	}
+	if !w.Defined || w.Coefficient <= 0 {
+		t.Errorf("common morph preyed upon: coefficient = %v (defined = %v), want > 0", w.Coefficient, w.Defined)
+	}
+	for _, m := range w.Morphs {
+		if m.Colour.Red > 0.5 && (m.Frequency != 0.6 || m.Selectivity <= 1) {
+			t.Errorf("red morph: %+v", m)
+		}
+	}
+
+	first := w.Coefficient
+
+	// rare morphs preyed upon instead: negative frequency dependence.
+	for i := range eaten {
+		eaten[i] = prey[i] != colour.Red
+	}
+	for turn := 15; turn < 20; turn++ {
+		w, done = a.Observe(turn, prey, eaten)
+	}
+	if !done || w.Start != 15 || w.Coefficient >= 0 {
+		t.Errorf("rare morphs preyed upon: coefficient = %v, want < 0", w.Coefficient)
+	}
+	if mean, ok := a.MeanCoefficient(); !ok || mean != (first+w.Coefficient)/2 {
+		t.Errorf("mean coefficient = %v (%v), want the mean of both windows", mean, ok)
+	}
+
+	// a single morph gives no frequency dependence to measure.
+	a = NewApostatic(2, 1)
+	if w, _ = a.Observe(0, prey[:6], eaten[:6]); w.Defined {
+		t.Errorf("single morph: coefficient %v should not be defined", w.Coefficient)
+	}
+}
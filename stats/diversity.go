@@ -0,0 +1,405 @@
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+// Defaults for counting morph clusters when no (positive) value is given.
+const (
+	DefaultClusterRadius = 0.05 //	as a colour.RGBDistance
+	DefaultClusterMin    = 3
+)
+
+/*
+Diversity describes how varied a population's colourations are. Richness,
+Shannon and Simpson are over the morph classes: the number occupied, the
+Shannon index H = -Σ pᵢ ln pᵢ, and the Gini-Simpson index 1 - Σ pᵢ² (the
+chance two individuals drawn at random are of different morphs). Clusters
+is the number of distinct groups of colouration regardless of the morph
+grid (see Clusters), and MeanDistance the mean colour.RGBDistance between
+every pair of individuals. None takes time quadratic in the population size.
+*/
+type Diversity struct {
+	Richness     int     `json:"richness"`
+	Shannon      float64 `json:"shannon"`
+	Simpson      float64 `json:"simpson"`
+	Clusters     int     `json:"clusters"`
+	MeanDistance float64 `json:"mean-distance"`
+}
+
+// Diversity measures the diversity of colourations, counting clusters of
+// the given radius and minimum size (see Clusters).
+func (m Morphs) Diversity(colourations []colour.RGB, radius float64, min int) Diversity {
+	counts := m.Count(colourations)
+	d := Diversity{
+		Shannon:      Shannon(counts),
+		Simpson:      Simpson(counts),
+		Clusters:     Clusters(colourations, radius, min),
+		MeanDistance: MeanDistance(colourations),
+	}
+	for _, n := range counts {
+		if n > 0 {
+			d.Richness++
+		}
+	}
+	return d
+}
+
+func proportions(counts []int) []float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	var p []float64
+	if total == 0 {
+		return p
+	}
+	for _, n := range counts {
+		if n > 0 {
+			p = append(p, float64(n)/float64(total))
+		}
+	}
+	return p
+}
+
+// Shannon gives the Shannon diversity index of the class counts, in nats.
+func Shannon(counts []int) float64 {
+	h := 0.0
+	for _, p := range proportions(counts) {
+		h -= p * math.Log(p)
+	}
+	return h
+}
+
+// Simpson gives the Gini-Simpson diversity index of the class counts.
+func Simpson(counts []int) float64 {
+	p := proportions(counts)
+	if len(p) == 0 {
+		return 0
+	}
+	d := 1.0
+	for _, pi := range p {
+		d -= pi * pi
+	}
+	return d
+}
+
+// MeanDistance gives the mean colour.RGBDistance over all pairs of colourations
+// (before RGBDistance rounds it). The distance being the mean absolute
+// difference of the channels, the sum over all pairs is found from each
+// channel sorted, without comparing every pair.
+func MeanDistance(colourations []colour.RGB) float64 {
+	n := len(colourations)
+	if n < 2 {
+		return 0
+	}
+	sum := 0.0
+	channel := make([]float64, n)
+	for ch := 0; ch < 3; ch++ {
+		for i, c := range colourations {
+			channel[i] = [3]float64{c.Red, c.Green, c.Blue}[ch]
+		}
+		sort.Float64s(channel)
+		for k, v := range channel { //	v is the greater of each pair with the k before it, the lesser of the rest.
+			sum += v * float64(2*k-(n-1))
+		}
+	}
+	return sum / 3 / float64(n*(n-1)/2)
+}
+
+/*
+Clusters counts the distinct groups of colouration by density-based
+clustering (DBSCAN): a group is formed by colourations with at least min
+others (themselves included) within radius of them, as a
+colour.RGBDistance, together with those in reach of them. Scattered
+colourations belonging to no group are not counted. Non-positive
+arguments give DefaultClusterRadius and DefaultClusterMin. Unlike k-means,
+the number of groups need not be known in advance, and the result doesn't
+depend on random initialisation.
+
+Up to clusterGridSize colourations, every pair is compared, taking time
+quadratic in their number: tens of milliseconds at clusterGridSize. A larger
+population is counted by clustersIndexed, giving the same count in far less
+time: some 20ms for 10,000, where comparing every pair takes seconds (see
+BenchmarkClusters in the tests).
+*/
+func Clusters(colourations []colour.RGB, radius float64, min int) int {
+	if radius <= 0 {
+		radius = DefaultClusterRadius
+	}
+	if min <= 0 {
+		min = DefaultClusterMin
+	}
+	if len(colourations) > clusterGridSize {
+		return clustersIndexed(colourations, radius, min)
+	}
+	return clustersPairwise(colourations, radius, min)
+}
+
+// clustersPairwise counts the groups of Clusters by comparing every pair of colourations.
+func clustersPairwise(colourations []colour.RGB, radius float64, min int) int {
+	const noise = -1
+	label := make([]int, len(colourations)) //	0 = not yet visited
+	neighbours := func(i int) []int {
+		var nb []int
+		for j := range colourations {
+			if colour.RGBDistance(colourations[i], colourations[j]) <= radius {
+				nb = append(nb, j)
+			}
+		}
+		return nb
+	}
+	clusters := 0
+	for i := range colourations {
+		if label[i] != 0 {
+			continue
+		}
+		reach := neighbours(i)
+		if len(reach) < min {
+			label[i] = noise
+			continue
+		}
+		clusters++
+		label[i] = clusters
+		for k := 0; k < len(reach); k++ {
+			j := reach[k]
+			if label[j] == noise {
+				label[j] = clusters //	on the edge of the cluster
+			}
+			if label[j] != 0 {
+				continue
+			}
+			label[j] = clusters
+			if nb := neighbours(j); len(nb) >= min {
+				reach = append(reach, nb...)
+			}
+		}
+	}
+	return clusters
+}
+
+/*
+clustersIndexed counts the groups of Clusters (whose radius and min must be
+positive) without comparing every pair of colourations. It counts the
+connected sets of core colourations (those with min within radius), over a
+grid of the colour space (see colourGrid): only colourations in cells near
+enough to be within radius are compared, and a cell which holds min, all
+within radius of each other, is core and joined as one without comparing
+them.
+*/
+func clustersIndexed(colourations []colour.RGB, radius float64, min int) int {
+	within := func(i, j int) bool {
+		a, b := colourations[i], colourations[j]
+		if math.Abs(a.Red-b.Red)+math.Abs(a.Green-b.Green)+math.Abs(a.Blue-b.Blue) > 3*(radius+rgbRounding) {
+			return false //	too far apart for rounding to bring them within radius.
+		}
+		return colour.RGBDistance(a, b) <= radius
+	}
+	all := make([]int, len(colourations))
+	for i := range all {
+		all[i] = i
+	}
+	g := newColourGrid(colourations, radius, all)
+
+	core := make([]bool, len(colourations))
+	for c := range g.start[1:] {
+		members := g.cell(c)
+		if g.close && len(members) >= min {
+			for _, i := range members {
+				core[i] = true
+			}
+			continue
+		}
+		for _, i := range members {
+			found := 0
+			g.near(c, func(near []int) bool {
+				for _, j := range near {
+					if within(i, j) {
+						found++
+						if found >= min {
+							core[i] = true
+							return false
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	var isCore []int
+	for i := range core {
+		if core[i] {
+			isCore = append(isCore, i)
+		}
+	}
+
+	parent := make([]int, len(colourations))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	cores := newColourGrid(colourations, radius, isCore)
+	for c := range cores.start[1:] {
+		a := cores.cell(c)
+		if len(a) == 0 {
+			continue
+		}
+		if cores.close {
+			for _, i := range a[1:] {
+				parent[find(i)] = find(a[0])
+			}
+		}
+		cores.near(c, func(b []int) bool {
+			if cores.close { //	joining a pair joins the cells.
+				if find(a[0]) == find(b[0]) {
+					return true
+				}
+				for _, i := range a {
+					for _, j := range b {
+						if within(i, j) {
+							parent[find(j)] = find(i)
+							return true
+						}
+					}
+				}
+				return true
+			}
+			for _, i := range a {
+				for _, j := range b {
+					if find(i) != find(j) && within(i, j) {
+						parent[find(j)] = find(i)
+					}
+				}
+			}
+			return true
+		})
+	}
+	clusters := 0
+	for _, i := range isCore {
+		if find(i) == i {
+			clusters++
+		}
+	}
+	return clusters
+}
+
+const (
+	clusterGridSize = 1000   //	the most colourations Clusters compares pairwise
+	rgbRounding     = 0.0005 //	the most colour.RGBDistance rounds a distance up by
+	maxColourCells  = 64     //	along each channel of a colourGrid, limiting its memory use
+)
+
+/*
+colourGrid divides the colour space into cubic cells, holding the indices of
+(some of) the colourations in each. Cells are small enough that any two
+colourations in the same one are within radius of each other, despite
+colour.RGBDistance rounding (their distance, a mean of channel differences,
+is less than the side of the cell), unless that would take more than
+maxColourCells along each channel.
+*/
+type colourGrid struct {
+	n       int      //	cells along each channel
+	close   bool     //	whether colourations sharing a cell are within radius
+	start   []int    //	the members of cell c are idx[start[c]:start[c+1]]
+	idx     []int    //	colouration indices, by cell
+	offsets [][3]int //	from a cell to those which may hold colourations within radius of it, nearest first
+}
+
+// newColourGrid indexes the colourations of the given indices.
+func newColourGrid(colourations []colour.RGB, radius float64, indices []int) colourGrid {
+	side := math.Max(radius-rgbRounding, radius/2)
+	g := colourGrid{close: true}
+	if side < 1.0/maxColourCells {
+		side, g.close = 1.0/maxColourCells, false
+	}
+	g.n = int(1/side) + 1 //	a channel of 1 falls in the last.
+	cells := make([]int, len(indices))
+	g.start = make([]int, g.n*g.n*g.n+1)
+	axis := func(v float64) int {
+		return calc.ClampIntIn(int(v/side), 0, g.n-1)
+	}
+	for k, i := range indices {
+		c := colourations[i]
+		cells[k] = (axis(c.Red)*g.n+axis(c.Green))*g.n + axis(c.Blue)
+		g.start[cells[k]+1]++
+	}
+	for c := 1; c < len(g.start); c++ {
+		g.start[c] += g.start[c-1]
+	}
+	g.idx = make([]int, len(indices))
+	fill := append([]int(nil), g.start[:len(g.start)-1]...)
+	for k, i := range indices {
+		g.idx[fill[cells[k]]] = i
+		fill[cells[k]]++
+	}
+
+	// colourations within radius differ by at most 3·(radius + rgbRounding)
+	// over the channels together, and those in cells k apart along a
+	// channel by over (k-1)·side.
+	reach := 3 * (radius + rgbRounding)
+	k := int(reach/side) + 1
+	for x := -k; x <= k; x++ {
+		for y := -k; y <= k; y++ {
+			for z := -k; z <= k; z++ {
+				o := [3]int{x, y, z}
+				gap := 0
+				for _, d := range o {
+					if d < 0 {
+						d = -d
+					}
+					if d > 1 {
+						gap += d - 1
+					}
+				}
+				if float64(gap)*side <= reach {
+					g.offsets = append(g.offsets, o)
+				}
+			}
+		}
+	}
+	sort.SliceStable(g.offsets, func(i, j int) bool { //	to find enough within radius sooner.
+		return manhattan(g.offsets[i]) < manhattan(g.offsets[j])
+	})
+	return g
+}
+
+func (g colourGrid) cell(c int) []int {
+	return g.idx[g.start[c]:g.start[c+1]]
+}
+
+// near calls f with the members of each occupied cell (including c itself)
+// which may hold colourations within radius of those in cell c, nearest
+// first, until f returns false.
+func (g colourGrid) near(c int, f func([]int) bool) {
+	x, y, z := c/(g.n*g.n), (c/g.n)%g.n, c%g.n
+	for _, o := range g.offsets {
+		i, j, k := x+o[0], y+o[1], z+o[2]
+		if i < 0 || j < 0 || k < 0 || i >= g.n || j >= g.n || k >= g.n {
+			continue
+		}
+		if members := g.cell((i*g.n+j)*g.n + k); len(members) > 0 && !f(members) {
+			return
+		}
+	}
+}
+
+func manhattan(o [3]int) int {
+	d := 0
+	for _, k := range o {
+		if k < 0 {
+			k = -k
+		}
+		d += k
+	}
+	return d
+}
@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/benjamin-rood/abm-cp/calc"
+	"github.com/benjamin-rood/abm-cp/colour"
+)
+
+func TestDiversity(t *testing.T) {
+	if h, d := Shannon([]int{5, 0, 5}), Simpson([]int{5, 0, 5}); math.Abs(h-math.Ln2) > 1e-12 || d != 0.5 {
+		t.Errorf("two equal morphs: Shannon == %v, Simpson == %v, want ln 2, 0.5", h, d)
+	}
+	if h, d := Shannon([]int{0, 7}), Simpson([]int{0, 7}); h != 0 || d != 0 {
+		t.Errorf("monomorphic: Shannon == %v, Simpson == %v, want 0, 0", h, d)
+	}
+
+	// two tight groups of four, and a lone outlier.
+	var pop []colour.RGB
+	for i := 0; i < 4; i++ {
+		v := float64(i) * 0.01
+		pop = append(pop, colour.RGB{Red: 0.9 - v, Green: 0.1, Blue: 0.1}, colour.RGB{Red: 0.1, Green: 0.1, Blue: 0.9 - v})
+	}
+	pop = append(pop, colour.RGB{Red: 0.5, Green: 0.9, Blue: 0.5})
+	d := NewMorphs(3).Diversity(pop, 0, 0)
+	if d.Clusters != 2 {
+		t.Errorf("Clusters == %d, want 2", d.Clusters)
+	}
+	if d.Richness != 3 || d.Shannon <= math.Ln2 || d.MeanDistance <= 0 {
+		t.Errorf("Diversity == %+v", d)
+	}
+
+	if md := MeanDistance([]colour.RGB{colour.Black, colour.White, colour.Black}); math.Abs(md-(2.0/3)) > 1e-12 {
+		t.Errorf("MeanDistance == %v, want 2/3", md)
+	}
+}
+
+func TestDiversityIndexed(t *testing.T) {
+	rng := calc.NewRNG(3)
+	for trial := 0; trial < 20; trial++ {
+		var pop []colour.RGB
+		centres := []colour.RGB{colour.RandRGB(rng), colour.RandRGB(rng), colour.RandRGB(rng)}
+		for i := 0; i < 300; i++ {
+			if i%3 == 0 {
+				pop = append(pop, colour.RandRGB(rng))
+				continue
+			}
+			pop = append(pop, colour.RandRGBClamped(centres[i%len(centres)], 0.05, rng))
+		}
+		for _, radius := range []float64{0.0004, 0.01, 0.05, 0.2} {
+			if got, want := clustersIndexed(pop, radius, 3), clustersPairwise(pop, radius, 3); got != want {
+				t.Fatalf("trial %d, radius %v: clustersIndexed == %d, pairwise %d", trial, radius, got, want)
+			}
+		}
+		sum, pairs := 0.0, 0
+		for i := range pop {
+			for j := i + 1; j < len(pop); j++ {
+				sum += colour.RGBDistance(pop[i], pop[j])
+				pairs++
+			}
+		}
+		if md := MeanDistance(pop); math.Abs(md-sum/float64(pairs)) > rgbRounding {
+			t.Errorf("trial %d: MeanDistance == %v, exhaustively %v", trial, md, sum/float64(pairs))
+		}
+	}
+}
+
+// benchmarkClusters counts the clusters of n colourations, a third scattered
+// and the rest around a few morphs.
+func benchmarkClusters(b *testing.B, n int, indexed bool) {
+	rng := calc.NewRNG(1)
+	centres := []colour.RGB{colour.RandRGB(rng), colour.RandRGB(rng), colour.RandRGB(rng), colour.RandRGB(rng)}
+	pop := make([]colour.RGB, n)
+	for i := range pop {
+		pop[i] = colour.RandRGB(rng)
+		if i%3 != 0 {
+			pop[i] = colour.RandRGBClamped(centres[i%len(centres)], 0.05, rng)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if indexed {
+			clustersIndexed(pop, DefaultClusterRadius, DefaultClusterMin)
+		} else {
+			clustersPairwise(pop, DefaultClusterRadius, DefaultClusterMin)
+		}
+	}
+}
+
+func BenchmarkClustersPairwise1000(b *testing.B)  { benchmarkClusters(b, 1000, false) }
+func BenchmarkClustersIndexed1000(b *testing.B)   { benchmarkClusters(b, 1000, true) }
+func BenchmarkClustersPairwise10000(b *testing.B) { benchmarkClusters(b, 10000, false) }
+func BenchmarkClustersIndexed10000(b *testing.B)  { benchmarkClusters(b, 10000, true) }
@@ -0,0 +1,69 @@
+/*
+Package stats measures the prey population of a model as it runs: the
+distribution of colour morphs, and the selection predators exert on them.
+*/
+package stats
+
+import "github.com/benjamin-rood/abm-cp/colour"
+
+// DefaultMorphBins is the number of bins each RGB channel is divided into
+// when no (positive) number is given, i.e. 3*3*3 = 27 morph classes.
+const DefaultMorphBins = 3
+
+/*
+Morphs divides colour.RGB space into a grid of Bins*Bins*Bins classes of
+equal size, so that a continuous colouration can be counted as one of a
+finite number of colour morphs.
+*/
+type Morphs struct {
+	Bins int `json:"bins"` //	per RGB channel
+}
+
+// NewMorphs divides each RGB channel into bins (or DefaultMorphBins when bins ≤ 0).
+func NewMorphs(bins int) Morphs {
+	if bins <= 0 {
+		bins = DefaultMorphBins
+	}
+	return Morphs{Bins: bins}
+}
+
+// Len gives the total number of morph classes.
+func (m Morphs) Len() int {
+	return m.Bins * m.Bins * m.Bins
+}
+
+func (m Morphs) bin(v float64) int {
+	b := int(v * float64(m.Bins))
+	if b < 0 {
+		return 0
+	}
+	if b >= m.Bins {
+		return m.Bins - 1
+	}
+	return b
+}
+
+// Of gives the morph class of colouration c, in [0, Len()).
+func (m Morphs) Of(c colour.RGB) int {
+	return (m.bin(c.Red) * m.Bins * m.Bins) + (m.bin(c.Green) * m.Bins) + m.bin(c.Blue)
+}
+
+// Colour gives the colour at the centre of morph class i.
+func (m Morphs) Colour(i int) colour.RGB {
+	w := 1 / float64(m.Bins)
+	centre := func(b int) float64 { return (float64(b) + 0.5) * w }
+	return colour.RGB{
+		Red:   centre(i / (m.Bins * m.Bins)),
+		Green: centre((i / m.Bins) % m.Bins),
+		Blue:  centre(i % m.Bins),
+	}
+}
+
+// Count gives the number of colourations in each morph class.
+func (m Morphs) Count(colourations []colour.RGB) []int {
+	counts := make([]int, m.Len())
+	for _, c := range colourations {
+		counts[m.Of(c)]++
+	}
+	return counts
+}
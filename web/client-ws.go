@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"log"
 	"time"
 
 	"github.com/benjamin-rood/abm-cp/abm"
@@ -50,7 +51,10 @@ func (c *SocketClient) Monitor(ch chan struct{}) {
 			// clean up
 			return
 		case <-ch:
-			c.Suspend() //	websocket connection dead, suspend model operation.
+			//	websocket connection dead, suspend model operation and keep it on disk so it can be resumed.
+			if err := c.SaveSession(); err != nil {
+				log.Println("SocketClient: Monitor: failed to save session:", err)
+			}
 			return
 		default:
 			time.Sleep(time.Millisecond * 100)
@@ -24,6 +24,8 @@ var initDrawObj = {
 }
 
 var drawlist = new DrawList(initDrawObj)
+var bgImage = null //  image of the environment substrate, if any
+var apostatic = null //  latest window of apostatic selection statistics, if any
 
 console.log(drawlist)
 
@@ -41,7 +43,10 @@ var sketch = function(p) {
   }
 
   p.draw = function() {
-    p.background(25,18,18)
+    p.background(drawlist.bg.red, drawlist.bg.green, drawlist.bg.blue)
+    if (bgImage) {
+      p.image(bgImage, 0, 0, p.width, p.height)
+    }
     //  draw colour polymorphic prey agents
     if (drawlist.cpPrey) {
       for (var i = 0; i < drawlist.cpPrey.length; i++) {
@@ -90,6 +95,40 @@ var sketch = function(p) {
       p.fill(255)
       p.text(vpPopString + "\n" + cpPreyPopString + "\n" + turnString, txsize*2, txsize*2)
     p.pop()
+    if (apostatic) {
+      drawApostatic(p, txsize)
+    }
+  }
+
+  //  draw the morph frequencies (bar width) and predation rates (bar height)
+  //  of the latest apostatic selection window, with its coefficient.
+  function drawApostatic(p, txsize) {
+    var morphs = apostatic.morphs || []
+    var bw = p.width * 0.25
+    var bh = p.height * 0.18
+    var bx = p.width - bw - (p.width * 0.02)
+    var by = p.height - (p.height * 0.2)
+    var maxRate = 0
+    for (var i = 0; i < morphs.length; i++) {
+      maxRate = Math.max(maxRate, morphs[i].rate)
+    }
+    p.push()
+      p.translate(bx, by)
+      p.noStroke()
+      p.fill(0,0,0,100)
+      p.rect(0, 0, bw, bh + txsize*2, by * 0.015)
+      var x = 0
+      for (var i = 0; i < morphs.length; i++) {
+        var w = morphs[i].frequency * bw
+        var h = maxRate > 0 ? (morphs[i].rate / maxRate) * bh : 0
+        p.fill(morphs[i].colour.red*255, morphs[i].colour.green*255, morphs[i].colour.blue*255)
+        p.rect(x, bh - h, w, h + 2)
+        x += w
+      }
+      var coefficient = apostatic.defined ? apostatic.coefficient.toFixed(3) : "n/a"
+      p.fill(255)
+      p.text("apostatic " + coefficient + "  (turns " + apostatic.start + "–" + apostatic.end + ")", txsize/2, bh + txsize*1.5)
+    p.pop()
   }
 
   p.windowResized = function() {
@@ -130,9 +169,26 @@ vizSocket.onmessage = function(e) {
       drawlist['turncount-string'] = rawmsg.data['turncount-string']
       viz.redraw()
       break
+    case 'background':
+      if (rawmsg.data.image) {
+        viz.loadImage(rawmsg.data.image, function(img) {
+          bgImage = img
+          viz.redraw()
+        })
+      } else {
+        bgImage = null
+      }
+      break
     case 'statistics':
-      // do something
-      console.log("recived statistics")
+      if (rawmsg.data.apostatic) {
+        apostatic = rawmsg.data.apostatic
+      }
+      viz.redraw()
+      break
+    case 'invalid-conditions':
+      alert("The model can't start with these conditions:\n" + rawmsg.data.map(function(e) {
+        return e.key + " = " + JSON.stringify(e.value) + ": must be " + e.constraint
+      }).join("\n"))
       break
     default:
       console.log("Error: don't recognise the received JSON message type!")
@@ -150,6 +206,15 @@ vizSocket.onerror = function(e) {
 $(function () {
   $('#conditionsParamsSend').on('click', function() {
     var conditions = {
+      ['abm-environment']: {
+        ['abm-environment-boundary']: $('#abm-environment-boundary').val(),
+        ['abm-environment-substrate']: {
+          type: $('#abm-environment-substrate-type').val(),
+          size: $('#abm-environment-substrate-type').val() === 'image' ? 0 : parseInt($('#abm-environment-substrate-size').val()), // full image resolution (capped by the server)
+          seed: parseInt($('#abm-rng-seedval').val()),
+          file: $('#abm-environment-substrate-file').val()
+        }
+      },
       ['abm-cp-prey-pop-start']: parseInt($('#abm-cp-prey-pop-start').val()),
       ['abm-cp-prey-pop-cap']: parseInt($('#abm-cp-prey-pop-cap').val()),
       ['abm-cp-prey-ageing']: parseBool($('#abm-cp-prey-ageing').is(':checked')),
@@ -161,6 +226,7 @@ $(function () {
       ['abm-cp-prey-gestation']: parseInt($('#abm-cp-prey-sexual-cost').val()),
       ['abm-cp-prey-spawn-size']: parseInt($('#abm-cp-prey-spawn-size').val()),
       ['abm-cp-prey-mf']: parseFloat($('#abm-cp-prey-mf').val()),
+      ['abm-cp-prey-uv']: parseBool($('#abm-cp-prey-uv').is(':checked')),
       ['abm-vp-pop-start']: parseInt($('#abm-vp-pop-start').val()),
       ['abm-vp-pop-cap']: parseInt($('#abm-vp-pop-cap').val()),
       ['abm-vp-ageing']: parseBool($('#abm-vp-ageing').is(':checked')),
@@ -172,6 +238,9 @@ $(function () {
       ['abm-vp-turn']: parseFloat($('#abm-vp-turn').val()),
       ['abm-vp-vsr']: parseFloat($('#abm-vp-vsr').val()),
       ['abm-vp-attack-chance']: parseFloat($('#abm-vp-attack-chance').val()),
+      ['abm-vp-crypsis-weight']: parseFloat($('#abm-vp-crypsis-weight').val()),
+      ['abm-vp-colour-metric']: $('#abm-vp-colour-metric').val(),
+      ['abm-vp-visual-systems']: $('#abm-vp-visual-systems').val() ? $('#abm-vp-visual-systems').val().split(',') : [],
       ['abm-vp-visual-search-tolerance']: parseFloat($('#abm-vp-visual-search-tolerance').val()),
       ['abm-vp-visual-search-tolerance-bump']: parseFloat($('#abm-vp-visual-search-tolerance-bump').val()),
       ['abm-vp-baseline-attack-gain']: 50,
@@ -181,6 +250,10 @@ $(function () {
       ['abm-vp-reproduction-chance']: parseFloat($('#abm-vp-reproduction-chance').val()),
       ['abm-vp-gestation']: 1,
       ['abm-vp-spawn-size']: parseInt($('#abm-vp-spawn-size').val()),
+      ['abm-morph-bins']: parseInt($('#abm-morph-bins').val()),
+      ['abm-apostatic-window']: parseInt($('#abm-apostatic-window').val()),
+      ['abm-cluster-radius']: parseFloat($('#abm-cluster-radius').val()),
+      ['abm-cluster-min']: parseInt($('#abm-cluster-min').val()),
       ['abm-random-ages']: parseBool($('#abm-random-ages').is(':checked')),
       ['abm-rng-random-seed']: parseBool($('#abm-rng-random-seed').is(':checked')),
       ['abm-rng-seedval']: parseInt($('#abm-rng-seedval').val()),
@@ -204,6 +277,35 @@ $(function () {
     console.log(json)
     vizSocket.send(json)
   })
+
+  $('#updateSend').on('click', function() {
+    var patch
+    try {
+      patch = JSON.parse($('#abm-update-patch').val())
+    } catch (err) {
+      alert("Update must be a JSON object of parameters, e.g. {\"abm-vp-attack-chance\": 0.5}")
+      return
+    }
+    var OutMsg = {
+      type: "update",
+      data: patch
+    }
+    vizSocket.send(JSON.stringify(OutMsg))
+  })
+
+  $('#resumeSessionSend').on('click', function() {
+    var session = $('#abm-resume-session').val().trim()
+    if (session === "") {
+      return
+    }
+    sessionString = session
+    document.getElementById('sessionDetail').innerHTML = ("ABM Colour Polymorphism (CP) – Session Name: " + sessionString)
+    var OutMsg = {
+      type: "resume",
+      data: session
+    }
+    vizSocket.send(JSON.stringify(OutMsg))
+  })
 })
 
 function parseBool(value){
@@ -0,0 +1,159 @@
+var bands = [] //  groups of replicate bands, as in bands.json
+var group = 0
+var metric = ""
+
+//  the bands of the chosen group and metric, in turn order.
+function chosenBands() {
+  if (!bands[group] || !bands[group].bands) {
+    return []
+  }
+  return bands[group].bands.filter(function(b) {
+    return b.metric === metric
+  })
+}
+
+function groupName(g) {
+  if (!g.values || g.values.length === 0) {
+    return "replicates"
+  }
+  return g.values.map(function(v) {
+    return v.key + " = " + JSON.stringify(v.value)
+  }).join(", ")
+}
+
+var sketch = function(p) {
+  var margin = 60
+  p.setup = function() {
+    var w = $('#bands-viewport').innerWidth()
+    var h = w * 0.5
+    p.createCanvas(w, h)
+    p.noLoop()
+  }
+
+  p.draw = function() {
+    p.background(30)
+    var bs = chosenBands()
+    if (bs.length === 0) {
+      p.fill(180)
+      p.textSize(16)
+      p.text("no bands loaded", margin, margin)
+      return
+    }
+    var qs = bands[group].quantiles || []
+    var lo = function(b) { return qs.length > 0 ? b.quantiles[0] : b.mean }
+    var hi = function(b) { return qs.length > 0 ? b.quantiles[qs.length - 1] : b.mean }
+    var minTurn = bs[0].turn
+    var maxTurn = Math.max(bs[bs.length - 1].turn, minTurn + 1)
+    var minY = Infinity
+    var maxY = -Infinity
+    for (var i = 0; i < bs.length; i++) {
+      minY = Math.min(minY, lo(bs[i]), bs[i].mean)
+      maxY = Math.max(maxY, hi(bs[i]), bs[i].mean)
+    }
+    if (maxY === minY) {
+      maxY = minY + 1
+    }
+    var x = function(turn) { return p.map(turn, minTurn, maxTurn, margin, p.width - margin) }
+    var y = function(v) { return p.map(v, minY, maxY, p.height - margin, margin) }
+
+    //  axes, with the range of each.
+    p.stroke(120)
+    p.line(margin, p.height - margin, p.width - margin, p.height - margin)
+    p.line(margin, margin, margin, p.height - margin)
+    p.noStroke()
+    p.fill(180)
+    p.textSize(12)
+    p.text(minTurn, margin, p.height - margin + 20)
+    p.text(maxTurn, p.width - margin - 20, p.height - margin + 20)
+    p.text(minY.toPrecision(3), 5, p.height - margin)
+    p.text(maxY.toPrecision(3), 5, margin)
+    p.text("turn", p.width / 2, p.height - margin + 35)
+
+    //  quantile band
+    p.fill(70, 130, 200, 90)
+    p.beginShape()
+    for (var i = 0; i < bs.length; i++) {
+      p.vertex(x(bs[i].turn), y(hi(bs[i])))
+    }
+    for (var i = bs.length - 1; i >= 0; i--) {
+      p.vertex(x(bs[i].turn), y(lo(bs[i])))
+    }
+    p.endShape(p.CLOSE)
+
+    //  mean (solid) and median (thin) lines
+    p.noFill()
+    p.strokeWeight(2)
+    p.stroke(255)
+    p.beginShape()
+    for (var i = 0; i < bs.length; i++) {
+      p.vertex(x(bs[i].turn), y(bs[i].mean))
+    }
+    p.endShape()
+    p.strokeWeight(1)
+    p.stroke(255, 200, 0)
+    p.beginShape()
+    for (var i = 0; i < bs.length; i++) {
+      p.vertex(x(bs[i].turn), y(bs[i].median))
+    }
+    p.endShape()
+
+    p.noStroke()
+    p.fill(255)
+    var band = qs.length > 0 ? (qs[0] * 100) + "–" + (qs[qs.length - 1] * 100) + "% band" : ""
+    p.text(metric + ": mean (white), median (yellow), " + band + ", over up to " + bs[0].runs + " runs", margin, margin - 20)
+  }
+
+  p.windowResized = function() {
+    var w = $('#bands-viewport').innerWidth()
+    p.resizeCanvas(w, w * 0.5)
+  }
+}
+
+var plot = null
+
+$(function () {
+  plot = new p5(sketch, 'bands-viewport')
+
+  $('#bands-file').on('change', function(e) {
+    var file = e.target.files[0]
+    if (!file) {
+      return
+    }
+    var reader = new FileReader()
+    reader.onload = function() {
+      try {
+        bands = JSON.parse(reader.result)
+      } catch (err) {
+        alert("Not a bands.json file: " + err)
+        return
+      }
+      $('#bands-group').empty()
+      for (var i = 0; i < bands.length; i++) {
+        $('#bands-group').append($('<option>').val(i).text(groupName(bands[i])))
+      }
+      var metrics = []
+      var bs = bands.length > 0 && bands[0].bands ? bands[0].bands : []
+      for (var i = 0; i < bs.length && metrics.indexOf(bs[i].metric) < 0; i++) {
+        metrics.push(bs[i].metric)
+      }
+      $('#bands-metric').empty()
+      for (var i = 0; i < metrics.length; i++) {
+        $('#bands-metric').append($('<option>').val(metrics[i]).text(metrics[i]))
+      }
+      group = 0
+      metric = metrics.length > 0 ? metrics[0] : ""
+      plot.redraw()
+    }
+    reader.readAsText(file)
+  })
+
+  $('#bands-group').on('change', function() {
+    group = parseInt($(this).val())
+    plot.redraw()
+  })
+
+  $('#bands-metric').on('change', function() {
+    metric = $(this).val()
+    plot.redraw()
+  })
+})